@@ -15,6 +15,7 @@
 package riak
 
 import (
+	"context"
 	"sync"
 	"time"
 
@@ -31,6 +32,16 @@ type Async struct {
 	enqueuedAt time.Time
 	executeAt  time.Time
 	qb         *backoff.Backoff // qb - Queue Backoff
+	ctx        context.Context
+	startedAt  time.Time
+	attempts   int
+}
+
+func (a *Async) context() context.Context {
+	if a.ctx == nil {
+		return context.Background()
+	}
+	return a.ctx
 }
 
 func (a *Async) onExecute() {
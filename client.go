@@ -15,9 +15,10 @@
 package riak
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"strconv"
-	"strings"
 )
 
 const ErrClientInvalidRemoteAddress = "[Client] invalid RemoteAddress '%s'"
@@ -25,6 +26,7 @@ const ErrClientInvalidRemoteAddress = "[Client] invalid RemoteAddress '%s'"
 var (
 	ErrClientOptionsRequired     = newClientError("[Client] options are required", nil)
 	ErrClientMissingRequiredData = newClientError("[Client] options must specify either a Cluster or a set of RemoteAddresses", nil)
+	ErrClientSiblings            = newClientError("[Client] value has siblings, use a FetchValueCommand with a ConflictResolver to resolve them", nil)
 )
 
 // Client object contains your cluster object
@@ -36,7 +38,7 @@ type Client struct {
 type NewClientOptions struct {
 	Cluster         *Cluster
 	Port            uint16   // NB: if specified, all connections will use this value if port is not provided
-	RemoteAddresses []string // NB: in the form HOST|IP[:PORT]
+	RemoteAddresses []string // NB: in the form HOST|IP[:PORT] or [IPv6][:PORT]
 }
 
 // NewClient generates a new Client object using the provided options
@@ -62,6 +64,12 @@ func (c *Client) Execute(cmd Command) error {
 	return c.cluster.Execute(cmd)
 }
 
+// ExecuteContext (synchronously) executes the provided Command against the cluster, abandoning it
+// if ctx is cancelled
+func (c *Client) ExecuteContext(ctx context.Context, cmd Command) error {
+	return c.cluster.ExecuteContext(ctx, cmd)
+}
+
 // Execute (asynchronously) the provided Command against the cluster
 func (c *Client) ExecuteAsync(a *Async) error {
 	return c.cluster.ExecuteAsync(a)
@@ -74,6 +82,133 @@ func (c *Client) Ping() (bool, error) {
 	return cmd.Success(), err
 }
 
+// Get fetches the value stored at the given bucket type, bucket and key. A nil Object is returned
+// if there is no value. ErrClientSiblings is returned if the value has siblings
+func (c *Client) Get(bucketType, bucket, key string) (*Object, error) {
+	cmd, err := NewFetchValueCommandBuilder().
+		WithBucketType(bucketType).
+		WithBucket(bucket).
+		WithKey(key).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+	if err = c.cluster.Execute(cmd); err != nil {
+		return nil, err
+	}
+	rsp := cmd.(*FetchValueCommand).Response
+	switch {
+	case rsp.IsNotFound:
+		return nil, nil
+	case len(rsp.Values) > 1:
+		return nil, ErrClientSiblings
+	case rsp.Values[0].IsTombstone:
+		return nil, nil
+	default:
+		return rsp.Values[0], nil
+	}
+}
+
+// Put stores the Object at its bucket type, bucket and key. If the Object has no key, Riak
+// generates one, which is set on the Object
+func (c *Client) Put(obj *Object) error {
+	cmd, err := NewStoreValueCommandBuilder().
+		WithContent(obj).
+		Build()
+	if err != nil {
+		return err
+	}
+	if err = c.cluster.Execute(cmd); err != nil {
+		return err
+	}
+	if rsp := cmd.(*StoreValueCommand).Response; rsp != nil && rsp.GeneratedKey != "" {
+		obj.Key = rsp.GeneratedKey
+	}
+	return nil
+}
+
+// Delete deletes the value stored at the given bucket type, bucket and key
+func (c *Client) Delete(bucketType, bucket, key string) error {
+	cmd, err := NewDeleteValueCommandBuilder().
+		WithBucketType(bucketType).
+		WithBucket(bucket).
+		WithKey(key).
+		Build()
+	if err != nil {
+		return err
+	}
+	return c.cluster.Execute(cmd)
+}
+
+// IncrementCounter increments the counter data type at the given bucket type, bucket and key by
+// the given amount, which may be negative
+func (c *Client) IncrementCounter(bucketType, bucket, key string, increment int64) error {
+	cmd, err := NewUpdateCounterCommandBuilder().
+		WithBucketType(bucketType).
+		WithBucket(bucket).
+		WithKey(key).
+		WithIncrement(increment).
+		Build()
+	if err != nil {
+		return err
+	}
+	return c.cluster.Execute(cmd)
+}
+
+// GetCounter fetches the value of the counter data type at the given bucket type, bucket and key.
+// A counter that does not exist has the value 0
+func (c *Client) GetCounter(bucketType, bucket, key string) (int64, error) {
+	cmd, err := NewFetchCounterCommandBuilder().
+		WithBucketType(bucketType).
+		WithBucket(bucket).
+		WithKey(key).
+		Build()
+	if err != nil {
+		return 0, err
+	}
+	if err = c.cluster.Execute(cmd); err != nil {
+		return 0, err
+	}
+	if rsp := cmd.(*FetchCounterCommand).Response; rsp != nil {
+		return rsp.CounterValue, nil
+	}
+	return 0, nil
+}
+
+// AddToSet adds the values to the set data type at the given bucket type, bucket and key
+func (c *Client) AddToSet(bucketType, bucket, key string, values ...[]byte) error {
+	cmd, err := NewUpdateSetCommandBuilder().
+		WithBucketType(bucketType).
+		WithBucket(bucket).
+		WithKey(key).
+		WithAdditions(values...).
+		Build()
+	if err != nil {
+		return err
+	}
+	return c.cluster.Execute(cmd)
+}
+
+// GetSet fetches the values of the set data type at the given bucket type, bucket and key. A set
+// that does not exist has no values
+func (c *Client) GetSet(bucketType, bucket, key string) ([][]byte, error) {
+	cmd, err := NewFetchSetCommandBuilder().
+		WithBucketType(bucketType).
+		WithBucket(bucket).
+		WithKey(key).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+	if err = c.cluster.Execute(cmd); err != nil {
+		return nil, err
+	}
+	if rsp := cmd.(*FetchSetCommand).Response; rsp != nil {
+		return rsp.SetValue, nil
+	}
+	return nil, nil
+}
+
 // Stop the nodes in the cluster and the cluster itself
 func (c *Client) Stop() error {
 	return c.cluster.Stop()
@@ -98,24 +233,17 @@ func newClientUsingAddresses(port uint16, remoteAddresses []string) (*Client, er
 		nopts := &NodeOptions{
 			MinConnections: 10,
 		}
-		s := strings.SplitN(ra, ":", 2)
-		switch len(s) {
-		case 0:
-			return nil, newClientError(fmt.Sprintf(ErrClientInvalidRemoteAddress, ra), nil)
-		case 1:
-			if port > 0 {
-				nopts.RemoteAddress = fmt.Sprintf("%s:%d", s[0], port)
-			} else {
-				nopts.RemoteAddress = fmt.Sprintf("%s:%d", s[0], defaultRemotePort)
-			}
-		case 2:
-			if p, err := strconv.Atoi(s[1]); err != nil {
-				return nil, newClientError(ErrClientInvalidRemoteAddress, err)
-			} else {
-				nopts.RemoteAddress = fmt.Sprintf("%s:%d", s[0], p)
-			}
-		default:
-			return nil, newClientError(fmt.Sprintf(ErrClientInvalidRemoteAddress, ra), nil)
+		if port > 0 {
+			nopts.RemoteAddress = withDefaultPort(ra, port)
+		} else {
+			nopts.RemoteAddress = withDefaultPort(ra, defaultRemotePort)
+		}
+		_, p, err := net.SplitHostPort(nopts.RemoteAddress)
+		if err != nil {
+			return nil, newClientError(fmt.Sprintf(ErrClientInvalidRemoteAddress, ra), err)
+		}
+		if _, err := strconv.ParseUint(p, 10, 16); err != nil {
+			return nil, newClientError(fmt.Sprintf(ErrClientInvalidRemoteAddress, ra), err)
 		}
 		if node, err := NewNode(nopts); err != nil {
 			return nil, err
@@ -19,7 +19,11 @@ package riak
 import (
 	"net"
 	"reflect"
+	"sync"
 	"testing"
+
+	rpbRiakKV "github.com/basho/riak-go-client/rpb/riak_kv"
+	proto "github.com/golang/protobuf/proto"
 )
 
 func TestNewClientWithPort(t *testing.T) {
@@ -70,3 +74,113 @@ func TestNewClientWithPort(t *testing.T) {
 		t.Errorf("expected %v, actual %v", expected, actual)
 	}
 }
+
+func TestClientGetPutDelete(t *testing.T) {
+	var mu sync.Mutex
+	values := make(map[string]*rpbRiakKV.RpbContent)
+	o := &testListenerOpts{
+		test: t,
+		onConn: func(c net.Conn) bool {
+			code, data, err := readClientMessageWithData(c)
+			if err != nil {
+				return true
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			var resp []byte
+			switch code {
+			case rpbCode_RpbPutReq:
+				req := &rpbRiakKV.RpbPutReq{}
+				if err = proto.Unmarshal(data, req); err != nil {
+					t.Error(err)
+					return true
+				}
+				values[string(req.Bucket)+"/"+string(req.Key)] = req.Content
+				resp = buildRiakMessage(rpbCode_RpbPutResp, nil)
+			case rpbCode_RpbGetReq:
+				req := &rpbRiakKV.RpbGetReq{}
+				if err = proto.Unmarshal(data, req); err != nil {
+					t.Error(err)
+					return true
+				}
+				getResp := &rpbRiakKV.RpbGetResp{}
+				if content, ok := values[string(req.Bucket)+"/"+string(req.Key)]; ok {
+					getResp.Content = []*rpbRiakKV.RpbContent{content}
+					getResp.Vclock = vclockBytes
+				}
+				encoded, err := proto.Marshal(getResp)
+				if err != nil {
+					t.Error(err)
+					return true
+				}
+				resp = buildRiakMessage(rpbCode_RpbGetResp, encoded)
+			case rpbCode_RpbDelReq:
+				req := &rpbRiakKV.RpbDelReq{}
+				if err = proto.Unmarshal(data, req); err != nil {
+					t.Error(err)
+					return true
+				}
+				delete(values, string(req.Bucket)+"/"+string(req.Key))
+				resp = buildRiakMessage(rpbCode_RpbDelResp, nil)
+			default:
+				t.Errorf("unexpected message code %v", code)
+				return true
+			}
+			if _, err = c.Write(resp); err != nil {
+				t.Error(err)
+				return true
+			}
+			return false
+		},
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	c, err := NewClient(&NewClientOptions{
+		RemoteAddresses: []string{tl.addr.String()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	obj, err := c.Get("default", "bucket", "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj != nil {
+		t.Errorf("expected nil object, got %v", obj)
+	}
+
+	if err = c.Put(&Object{
+		Bucket:      "bucket",
+		Key:         "key",
+		ContentType: "text/plain",
+		Value:       []byte("value"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if obj, err = c.Get("default", "bucket", "key"); err != nil {
+		t.Fatal(err)
+	}
+	if obj == nil {
+		t.Fatal("expected non-nil object")
+	}
+	if got, want := string(obj.Value), "value"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := obj.ContentType, "text/plain"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if err = c.Delete("default", "bucket", "key"); err != nil {
+		t.Fatal(err)
+	}
+	if obj, err = c.Get("default", "bucket", "key"); err != nil {
+		t.Fatal(err)
+	}
+	if obj != nil {
+		t.Errorf("expected nil object after delete, got %v", obj)
+	}
+}
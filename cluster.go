@@ -15,7 +15,9 @@
 package riak
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"sync"
 	"time"
 )
@@ -32,12 +34,45 @@ const (
 // ClusterOptions object contains your pool of Node objects and the NodeManager
 // If the NodeManager is not defined, the defaultNodeManager is used
 type ClusterOptions struct {
-	Nodes                  []*Node
-	NoDefaultNode          bool
-	NodeManager            NodeManager
-	ExecutionAttempts      byte
+	Nodes         []*Node
+	NoDefaultNode bool
+	NodeManager   NodeManager
+	// ExecutionAttempts is the number of times a retryable Command is executed before failing.
+	// Each retry is sent to a different Node when there are several. A connection on which Riak
+	// returned an error is still healthy and is returned to its Node's pool for reuse, whereas one
+	// that failed with a network error is closed
+	ExecutionAttempts byte
+	// RetryPolicy, if set, is used instead of ExecutionAttempts to decide whether a retryable
+	// Command that failed is executed again, and how long to wait first. The error that ended
+	// execution is returned as is, so that, for example, a RiakError may be inspected
+	RetryPolicy            RetryPolicy
 	QueueMaxDepth          uint16
 	QueueExecutionInterval time.Duration
+	// MaxAsyncWorkers bounds the number of goroutines executing Commands via ExecuteAsync or the
+	// command queue. When all are busy, ExecuteAsync blocks until one is available, and queued
+	// Commands wait for a later QueueExecutionInterval. The bound is shared by all Nodes, as a
+	// Command is only assigned to a Node once it executes. Cluster.Stats reports busy workers.
+	// If 0, asynchronous execution is unbounded
+	MaxAsyncWorkers uint16
+	// BeforeExecute, if set, is called once before each Command is executed, and AfterExecute
+	// once it has completed, regardless of how many Nodes were tried. They are called for Commands
+	// executed by any means, including ExecuteAsync and the command queue, which makes them the
+	// recommended hooks for tracing and auditing when there are several Nodes. They are called
+	// from the goroutine executing the Command, so must be safe for concurrent use
+	BeforeExecute func(cmd Command)
+	AfterExecute  func(outcome *ExecuteOutcome)
+	// Logger, if set, receives the Cluster's log messages instead of the package loggers. Each
+	// Node's messages go to the Logger in its own NodeOptions
+	Logger Logger
+}
+
+// ExecuteOutcome describes a Command that has completed execution via a Cluster
+type ExecuteOutcome struct {
+	Command  Command
+	Node     *Node // the last Node to execute the Command, nil if none did
+	Attempts int   // the number of times Nodes were asked to execute the Command
+	Duration time.Duration
+	Error    error
 }
 
 // Cluster object contains your pool of Node objects, the NodeManager and the
@@ -47,9 +82,14 @@ type Cluster struct {
 	nodes              []*Node
 	nodeManager        NodeManager
 	executionAttempts  byte
+	retryPolicy        RetryPolicy
 	queueCommands      bool
 	cq                 *queue
 	commandQueueTicker *time.Ticker
+	asyncWorkers       chan struct{}
+	beforeExecute      func(cmd Command)
+	afterExecute       func(outcome *ExecuteOutcome)
+	log                scopedLogger
 	sync.Mutex
 	stateData
 }
@@ -62,9 +102,15 @@ var (
 	ErrClusterEnqueueWhileShuttingDown        = newClientError("[Cluster] will not enqueue command, shutting down", nil)
 	ErrClusterShuttingDown                    = newClientError("[Cluster] will not execute command, shutting down", nil)
 	ErrClusterNodeMustBeNonNil                = newClientError("[Cluster] node argument must be non-nil", nil)
+	ErrClusterSearchPageRowsRequired          = newClientError("[Cluster] search page rows must be greater than zero", nil)
 )
 
 const ErrClusterNoNodesAvailable = "[Cluster] all retries exhausted and/or no nodes available to execute command"
+const ErrClusterDataTypeUpdateRequired = "[Cluster] '%s' is not a data type update command"
+const ErrClusterSecondaryIndexQueryRequired = "[Cluster] '%s' is not a secondary index query command"
+const ErrClusterStoreIndexRequired = "[Cluster] '%s' is not a store index command"
+const ErrClusterNodeAlreadyAdded = "[Cluster] a node with address '%s' is already in the cluster"
+const ErrClusterNodeNotFound = "[Cluster] no node with address '%s' is in the cluster"
 
 var defaultClusterOptions = &ClusterOptions{
 	Nodes:             make([]*Node, 0),
@@ -86,7 +132,14 @@ func NewCluster(options *ClusterOptions) (*Cluster, error) {
 
 	c := &Cluster{
 		executionAttempts: options.ExecutionAttempts,
+		retryPolicy:       options.RetryPolicy,
 		nodeManager:       options.NodeManager,
+		beforeExecute:     options.BeforeExecute,
+		afterExecute:      options.AfterExecute,
+		log:               scopedLogger{logger: options.Logger},
+	}
+	if options.MaxAsyncWorkers > 0 {
+		c.asyncWorkers = make(chan struct{}, options.MaxAsyncWorkers)
 	}
 	c.initStateData("clusterCreated", "clusterRunning", "clusterShuttingDown", "clusterShutdown", "clusterError")
 
@@ -142,18 +195,18 @@ func (c *Cluster) Start() error {
 		return err
 	}
 
-	logDebug("[Cluster]", "starting")
+	c.log.debug("[Cluster]", "starting")
 
 	c.Lock()
 	defer c.Unlock()
 	for _, node := range c.nodes {
-		if err := node.start(); err != nil {
+		if err := c.startNode(node); err != nil {
 			return err
 		}
 	}
 
 	c.setState(clusterRunning)
-	logDebug("[Cluster]", "cluster started")
+	c.log.debug("[Cluster]", "cluster started")
 
 	return nil
 }
@@ -165,7 +218,7 @@ func (c *Cluster) Stop() (err error) {
 		return
 	}
 
-	logDebug("[Cluster]", "shutting down")
+	c.log.debug("[Cluster]", "shutting down")
 
 	c.setState(clusterShuttingDown)
 
@@ -174,7 +227,7 @@ func (c *Cluster) Stop() (err error) {
 		c.commandQueueTicker.Stop()
 		qc := c.cq.count()
 		if qc > 0 {
-			logWarn("[Cluster]", "commands in queue during shutdown: %d", qc)
+			c.log.warn("[Cluster]", "commands in queue during shutdown: %d", qc)
 			var f = func(v interface{}) (bool, bool) {
 				if v == nil {
 					return true, false
@@ -185,7 +238,7 @@ func (c *Cluster) Stop() (err error) {
 				return false, false
 			}
 			if qerr := c.cq.iterate(f); qerr != nil {
-				logErr("[Cluster]", qerr)
+				c.log.err("[Cluster]", qerr)
 			}
 		}
 		c.cq.destroy()
@@ -196,12 +249,12 @@ func (c *Cluster) Stop() (err error) {
 	for _, node := range c.nodes {
 		err = node.stop()
 		if err != nil {
-			logErr("[Cluster]", err)
+			c.log.err("[Cluster]", err)
 		}
 	}
 
 	allStopped := true
-	logDebug("[Cluster]", "checking to see if nodes are shut down")
+	c.log.debug("[Cluster]", "checking to see if nodes are shut down")
 	for _, node := range c.nodes {
 		nodeState := node.getState()
 		if nodeState != nodeShutdown {
@@ -212,7 +265,7 @@ func (c *Cluster) Stop() (err error) {
 
 	if allStopped {
 		c.setState(clusterShutdown)
-		logDebug("[Cluster]", "cluster shut down")
+		c.log.debug("[Cluster]", "cluster shut down")
 	} else {
 		panic("[Cluster] nodes still running when all should be stopped")
 	}
@@ -233,7 +286,7 @@ func (c *Cluster) AddNode(n *Node) error {
 		}
 	}
 	if c.isCurrentState(clusterRunning) {
-		if err := n.start(); err != nil {
+		if err := c.startNode(n); err != nil {
 			return err
 		}
 	}
@@ -241,29 +294,90 @@ func (c *Cluster) AddNode(n *Node) error {
 	return nil
 }
 
-// Stops the node and removes from the cluster
+// startNode starts n. A node none of whose connections could be established is health checking,
+// so is kept, and is used once it recovers
+func (c *Cluster) startNode(n *Node) error {
+	if err := n.start(); err != nil {
+		if err != ErrNodeNoConnections {
+			return err
+		}
+		c.log.warn("[Cluster]", "(%v) %v", n, err)
+	}
+	return nil
+}
+
+// Stops the node and removes from the cluster. The node is removed from selection before it is
+// stopped, and Commands already executing on it are allowed to complete before their
+// connections are closed
 func (c *Cluster) RemoveNode(n *Node) error {
 	if n == nil {
 		return ErrClusterNodeMustBeNonNil
 	}
 	c.Lock()
-	defer c.Unlock()
-	cn := c.nodes
-	for i, node := range c.nodes {
+	removed := false
+	// NB: a new slice is built so that a snapshot taken by getNodes is never modified
+	nodes := make([]*Node, 0, len(c.nodes))
+	for _, node := range c.nodes {
 		if n == node {
-			l := len(cn) - 1
-			cn[i], cn[l], c.nodes = cn[l], nil, cn[:l]
-			if !node.isCurrentState(nodeCreated) {
-				if err := node.stop(); err != nil {
-					return err
-				}
-			}
-			return nil
+			removed = true
+		} else {
+			nodes = append(nodes, node)
+		}
+	}
+	c.nodes = nodes
+	c.Unlock()
+	if removed && !n.isCurrentState(nodeCreated) {
+		return n.stop()
+	}
+	return nil
+}
+
+// AddNodeWithOptions creates a Node from the provided NodeOptions and adds it to the cluster,
+// starting it if the cluster is running. Adding a node whose address is already in the cluster
+// is an error
+func (c *Cluster) AddNodeWithOptions(opts *NodeOptions) error {
+	n, err := NewNode(opts)
+	if err != nil {
+		return err
+	}
+	c.Lock()
+	defer c.Unlock()
+	for _, node := range c.nodes {
+		if node.addr.String() == n.addr.String() {
+			return newClientError(fmt.Sprintf(ErrClusterNodeAlreadyAdded, n.addr), nil)
 		}
 	}
+	if c.isCurrentState(clusterRunning) {
+		if err := c.startNode(n); err != nil {
+			return err
+		}
+	}
+	c.nodes = append(c.nodes, n)
 	return nil
 }
 
+// RemoveNodeByAddress stops the node with the provided address and removes it from the cluster,
+// as RemoveNode does
+func (c *Cluster) RemoveNodeByAddress(remoteAddress string) error {
+	addr, err := net.ResolveTCPAddr("tcp", withDefaultPort(remoteAddress, defaultRemotePort))
+	if err != nil {
+		return err
+	}
+	for _, node := range c.getNodes() {
+		if node.addr.String() == addr.String() {
+			return c.RemoveNode(node)
+		}
+	}
+	return newClientError(fmt.Sprintf(ErrClusterNodeNotFound, remoteAddress), nil)
+}
+
+// getNodes returns the cluster's current nodes, which must not be modified
+func (c *Cluster) getNodes() []*Node {
+	c.Lock()
+	defer c.Unlock()
+	return c.nodes
+}
+
 // Execute (asynchronously) the provided Command against the active pooled Nodes using the NodeManager
 func (c *Cluster) ExecuteAsync(async *Async) error {
 	if async.Command == nil {
@@ -275,10 +389,59 @@ func (c *Cluster) ExecuteAsync(async *Async) error {
 	if async.Wait != nil {
 		async.Wait.Add(1)
 	}
-	go c.execute(async)
+	async.startedAt, async.attempts = time.Time{}, 0
+	if c.asyncWorkers != nil {
+		c.asyncWorkers <- struct{}{}
+	}
+	c.startAsyncWorker(async)
 	return nil
 }
 
+// startAsyncWorker executes async in a new goroutine. When MaxAsyncWorkers is set, the caller
+// must already hold a worker, which is released once the Command completes
+func (c *Cluster) startAsyncWorker(async *Async) {
+	if c.asyncWorkers == nil {
+		go c.execute(async)
+		return
+	}
+	go func() {
+		defer func() { <-c.asyncWorkers }()
+		c.execute(async)
+	}()
+}
+
+// ClusterStats is a snapshot of a Cluster, as returned by Cluster.Stats
+type ClusterStats struct {
+	State string
+	// MaxAsyncWorkers is the ClusterOptions value, 0 when asynchronous execution is unbounded
+	MaxAsyncWorkers uint16
+	// AsyncWorkersBusy is the number of goroutines executing Commands via ExecuteAsync or the
+	// command queue. It is only counted when MaxAsyncWorkers is set
+	AsyncWorkersBusy uint16
+	QueuedCommands   uint16 // Commands waiting in the command queue
+	Nodes            []NodeStats
+}
+
+// Stats returns a snapshot of the Cluster and each of its Nodes. It is safe to call
+// concurrently with Command execution, for example to export metrics
+func (c *Cluster) Stats() ClusterStats {
+	stats := ClusterStats{
+		State:            c.stateData.String(),
+		MaxAsyncWorkers:  uint16(cap(c.asyncWorkers)),
+		AsyncWorkersBusy: uint16(len(c.asyncWorkers)),
+	}
+	if c.cq != nil {
+		stats.QueuedCommands = c.cq.count()
+	}
+	c.Lock()
+	nodes := c.nodes
+	c.Unlock()
+	for _, n := range nodes {
+		stats.Nodes = append(stats.Nodes, n.Stats())
+	}
+	return stats
+}
+
 // Execute (synchronously) the provided Command against the active pooled Nodes using the NodeManager
 func (c *Cluster) Execute(command Command) error {
 	if command == nil {
@@ -297,6 +460,285 @@ func (c *Cluster) Execute(command Command) error {
 	return nil
 }
 
+// ExecuteContext (synchronously) executes the provided Command against the active pooled Nodes
+// using the NodeManager. Should ctx be cancelled or reach its deadline while the Command is in
+// flight, the connection's socket is closed immediately, the connection is discarded and ctx.Err()
+// is returned. The Command will not be re-tried after ctx is done
+func (c *Cluster) ExecuteContext(ctx context.Context, command Command) error {
+	if ctx == nil {
+		panic("[Cluster] nil context")
+	}
+	if command == nil {
+		return ErrClusterCommandRequired
+	}
+	async := &Async{
+		Command: command,
+		ctx:     ctx,
+	}
+	c.execute(async)
+	if async.Error != nil {
+		return async.Error
+	}
+	if cerr := command.Error(); cerr != nil {
+		return cerr
+	}
+	return nil
+}
+
+// DataTypeUpdateResult contains the outcome of a single Command executed via
+// ExecuteDataTypeUpdates. The Command's Response is populated on success
+type DataTypeUpdateResult struct {
+	Command Command
+	Error   error
+}
+
+// ExecuteDataTypeUpdates (synchronously) executes the provided data type update Commands
+// (UpdateCounter, UpdateSet, UpdateGSet, UpdateMap, UpdateHll), with at most maxInFlight of them
+// executing at once. Each update is independent, so a failed update does not affect the others.
+// Results are returned in the same order as commands
+func (c *Cluster) ExecuteDataTypeUpdates(commands []Command, maxInFlight uint16) ([]*DataTypeUpdateResult, error) {
+	if maxInFlight == 0 {
+		maxInFlight = defaultMaxDataTypeUpdatesInFlight
+	}
+	for _, cmd := range commands {
+		if cmd == nil {
+			return nil, ErrClusterCommandRequired
+		}
+		switch cmd.getRequestCode() {
+		case rpbCode_DtUpdateReq, rpbCode_RpbCounterUpdateReq:
+		default:
+			return nil, newClientError(fmt.Sprintf(ErrClusterDataTypeUpdateRequired, cmd.Name()), nil)
+		}
+	}
+
+	results := make([]*DataTypeUpdateResult, len(commands))
+	inFlight := make(chan struct{}, maxInFlight)
+	wg := &sync.WaitGroup{}
+	for i, cmd := range commands {
+		inFlight <- struct{}{}
+		wg.Add(1)
+		go func(i int, cmd Command) {
+			defer func() {
+				<-inFlight
+				wg.Done()
+			}()
+			results[i] = &DataTypeUpdateResult{
+				Command: cmd,
+				Error:   c.Execute(cmd),
+			}
+		}(i, cmd)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// ExecuteBatch (synchronously) executes the provided Commands one after another on a single
+// connection, see Node.ExecuteBatch. Nodes are tried in turn until one is able to execute the
+// batch. Commands are not retried, as those of an aborted batch may have been partially executed
+func (c *Cluster) ExecuteBatch(commands []Command) ([]*BatchResult, error) {
+	if err := c.stateCheck(clusterRunning); err != nil {
+		return nil, err
+	}
+	for _, cmd := range commands {
+		if cmd == nil {
+			return nil, ErrClusterCommandRequired
+		}
+	}
+	if len(commands) == 0 {
+		return []*BatchResult{}, nil
+	}
+
+	var results []*BatchResult
+	var err error
+	for _, n := range c.getNodes() {
+		if results, err = n.ExecuteBatch(commands); results[0].Executed {
+			return results, err
+		}
+		c.log.debug("[Cluster]", "(%v) could not execute batch: %v", n, err)
+	}
+	return results, newClientError(ErrClusterNoNodesAvailable, err)
+}
+
+// ExecuteSearchPages (synchronously) executes the search configured by builder numRows documents
+// at a time, passing each page of documents to callback until all matching documents have been
+// returned or callback returns an error. Paging begins at the builder's Start, if set.
+//
+// Riak does not support Solr cursor marks, so pages are fetched by Start and Rows, which becomes
+// increasingly expensive for Solr as Start grows. A sort field should be set so that the order of
+// documents is stable between pages
+func (c *Cluster) ExecuteSearchPages(builder *SearchCommandBuilder, numRows uint32, callback func(docs []*SearchDoc) error) error {
+	if builder == nil || callback == nil {
+		return ErrClusterCommandRequired
+	}
+	if numRows == 0 {
+		return ErrClusterSearchPageRowsRequired
+	}
+	start := builder.protobuf.GetStart()
+	for {
+		cmd := builder.buildPage(start, numRows)
+		if err := c.Execute(cmd); err != nil {
+			return err
+		}
+		docs := cmd.Response.Docs
+		if len(docs) == 0 {
+			return nil
+		}
+		if err := callback(docs); err != nil {
+			return err
+		}
+		start += uint32(len(docs))
+		if start >= cmd.Response.NumFound {
+			return nil
+		}
+	}
+}
+
+// ExecuteStoreIndexAndWait (synchronously) executes the provided StoreIndexCommand and then, as
+// Riak creates search indexes asynchronously, fetches the index every pollInterval until Riak
+// returns it or ctx is done, in which case ctx.Err() is returned. If pollInterval is 0, one second
+// is used
+func (c *Cluster) ExecuteStoreIndexAndWait(ctx context.Context, cmd Command, pollInterval time.Duration) error {
+	if cmd == nil {
+		return ErrClusterCommandRequired
+	}
+	storeCmd, ok := cmd.(*StoreIndexCommand)
+	if !ok {
+		return newClientError(fmt.Sprintf(ErrClusterStoreIndexRequired, cmd.Name()), nil)
+	}
+	if pollInterval == 0 {
+		pollInterval = defaultIndexPollInterval
+	}
+	if err := c.ExecuteContext(ctx, storeCmd); err != nil {
+		return err
+	}
+	indexName := string(storeCmd.protobuf.Index.GetName())
+	for {
+		fetchCmd, err := NewFetchIndexCommandBuilder().
+			WithIndexName(indexName).
+			Build()
+		if err != nil {
+			return err
+		}
+		err = c.ExecuteContext(ctx, fetchCmd)
+		if err == nil && len(fetchCmd.(*FetchIndexCommand).Response) > 0 {
+			return nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		// NB: Riak returns an error until the index has been created, which is the inner error
+		// once execution attempts are exhausted, or returned as is with a RetryPolicy
+		if err != nil {
+			if _, ok := AsRiakError(err); !ok {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// SecondaryIndexFetchResult contains the outcome of fetching a single object found by
+// ExecuteSecondaryIndexFetch. Response is nil if the fetch failed
+type SecondaryIndexFetchResult struct {
+	Key      string
+	Response *FetchValueResponse
+	Error    error
+}
+
+// ExecuteSecondaryIndexFetch (synchronously) executes the provided SecondaryIndexQueryCommand and
+// then fetches the object for each key it returns, with at most maxInFlight fetches executing at
+// once. If headOnly is true, only object metadata is fetched. Each result is passed to callback,
+// which is never called concurrently, as soon as its fetch completes. A failed fetch is reported
+// in its result and does not stop the others, whereas an error from the query or callback does.
+//
+// If the query was built WithStreaming(true), fetching begins as keys are streamed from Riak and
+// its own callback is still invoked
+func (c *Cluster) ExecuteSecondaryIndexFetch(query Command, headOnly bool, maxInFlight uint16, callback func(*SecondaryIndexFetchResult) error) error {
+	if query == nil || callback == nil {
+		return ErrClusterCommandRequired
+	}
+	sq, ok := query.(*SecondaryIndexQueryCommand)
+	if !ok {
+		return newClientError(fmt.Sprintf(ErrClusterSecondaryIndexQueryRequired, query.Name()), nil)
+	}
+	if maxInFlight == 0 {
+		maxInFlight = defaultMaxIndexFetchesInFlight
+	}
+
+	var callbackErr error
+	callbackMtx := &sync.Mutex{}
+	inFlight := make(chan struct{}, maxInFlight)
+	wg := &sync.WaitGroup{}
+	fetch := func(key string) {
+		defer func() {
+			<-inFlight
+			wg.Done()
+		}()
+		result := &SecondaryIndexFetchResult{Key: key}
+		cmd, err := NewFetchValueCommandBuilder().
+			WithBucketType(string(sq.protobuf.GetType())).
+			WithBucket(string(sq.protobuf.GetBucket())).
+			WithKey(key).
+			WithHeadOnly(headOnly).
+			Build()
+		if err == nil {
+			err = c.Execute(cmd)
+		}
+		if err == nil {
+			result.Response = cmd.(*FetchValueCommand).Response
+		} else {
+			result.Error = err
+		}
+		callbackMtx.Lock()
+		defer callbackMtx.Unlock()
+		if callbackErr == nil {
+			callbackErr = callback(result)
+		}
+	}
+	dispatch := func(results []*SecondaryIndexQueryResult) error {
+		for _, r := range results {
+			inFlight <- struct{}{}
+			callbackMtx.Lock()
+			err := callbackErr
+			callbackMtx.Unlock()
+			if err != nil {
+				<-inFlight
+				return err
+			}
+			wg.Add(1)
+			go fetch(string(r.ObjectKey))
+		}
+		return nil
+	}
+
+	streaming := sq.protobuf.GetStream()
+	if streaming {
+		queryCallback := sq.callback
+		sq.callback = func(results []*SecondaryIndexQueryResult) error {
+			if err := queryCallback(results); err != nil {
+				return err
+			}
+			return dispatch(results)
+		}
+		defer func() {
+			sq.callback = queryCallback
+		}()
+	}
+	err := c.Execute(sq)
+	if err == nil && !streaming {
+		err = dispatch(sq.Response.Results)
+	}
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+	return callbackErr
+}
+
 // NB: will be executed in a goroutine
 func (c *Cluster) execute(async *Async) {
 	if c == nil {
@@ -309,35 +751,51 @@ func (c *Cluster) execute(async *Async) {
 	executed := false
 	enqueued := false
 	cmd := async.Command
+	ctx := async.context()
 
 	tries := byte(1)
 	var lastExeNode *Node
-	if rc, ok := cmd.(retryableCommand); ok {
+	rc, retryable := cmd.(retryableCommand)
+	if retryable {
 		tries = c.executionAttempts
 		lastExeNode = rc.getLastNode()
 	}
 
+	// NB: a Command from the queue has already been seen by BeforeExecute
+	if async.startedAt.IsZero() {
+		async.startedAt = time.Now()
+		if c.beforeExecute != nil {
+			c.beforeExecute(cmd)
+		}
+	}
+
 	async.onExecute()
 	for tries > 0 {
 		if err = c.stateCheck(clusterRunning); err != nil {
 			break
 		}
-		executed, err = c.nodeManager.ExecuteOnNode(c.nodes, cmd, lastExeNode)
+		async.attempts++
+		nodes := c.getNodes()
+		if cnm, ok := c.nodeManager.(ContextNodeManager); ok {
+			executed, err = cnm.ExecuteOnNodeContext(ctx, nodes, cmd, lastExeNode)
+		} else {
+			executed, err = c.nodeManager.ExecuteOnNode(nodes, cmd, lastExeNode)
+		}
 		// NB: do *not* call cmd.onError here as it will have been called in connection
 		if executed {
 			// NB: "executed" means that a node sent the data to Riak and received a response
 			if err == nil {
 				// No need to re-try
-				logDebug("[Cluster]", "successfully executed cmd '%s'", cmd.Name())
+				c.log.debug("[Cluster]", "successfully executed cmd '%s'", cmd.Name())
 				break
 			} else {
 				// NB: retry since error occurred
-				logDebug("[Cluster]", "executed cmd '%s': re-try due to error '%v'", cmd.Name(), err)
+				c.log.debug("[Cluster]", "executed cmd '%s': re-try due to error '%v'", cmd.Name(), err)
 			}
 		} else {
 			// Command did NOT execute
 			if err == nil {
-				logDebug("[Cluster]", "did NOT execute cmd '%s', nil err", cmd.Name())
+				c.log.debug("[Cluster]", "did NOT execute cmd '%s', nil err", cmd.Name())
 				// Command did not execute but there was no error, so enqueue it
 				// TODO FUTURE should this only happen if retries exhausted?
 				if c.queueCommands {
@@ -348,12 +806,35 @@ func (c *Cluster) execute(async *Async) {
 				}
 			} else {
 				// NB: retry since error occurred
-				logDebug("[Cluster]", "did NOT execute cmd '%s': re-try due to error '%v'", cmd.Name(), err)
+				c.log.debug("[Cluster]", "did NOT execute cmd '%s': re-try due to error '%v'", cmd.Name(), err)
 			}
 		}
 
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			// NB: the caller has abandoned this command, do not re-try
+			c.log.debug("[Cluster]", "cmd '%s' abandoned: %v", cmd.Name(), ctxErr)
+			err = ctxErr
+			break
+		}
+
+		if c.retryPolicy != nil {
+			retry, backoff := c.retryPolicy.ShouldRetry(err, async.attempts)
+			if !retry || !retryable {
+				if err == nil {
+					err = newClientError(ErrClusterNoNodesAvailable, nil)
+				}
+				break
+			}
+			if err = sleepContext(ctx, backoff); err != nil {
+				break
+			}
+			cmd.onRetry()
+			async.onRetry()
+			continue
+		}
+
 		tries--
-		logDebug("[Cluster]", "cmd %s tries: %d", cmd.Name(), tries)
+		c.log.debug("[Cluster]", "cmd %s tries: %d", cmd.Name(), tries)
 
 		if tries > 0 {
 			cmd.onRetry()
@@ -363,15 +844,34 @@ func (c *Cluster) execute(async *Async) {
 		}
 	}
 	if !enqueued {
+		if c.afterExecute != nil {
+			c.afterExecute(c.outcome(async, err))
+		}
 		async.done(err)
 	}
 }
 
+func (c *Cluster) outcome(async *Async, err error) *ExecuteOutcome {
+	o := &ExecuteOutcome{
+		Command:  async.Command,
+		Attempts: async.attempts,
+		Duration: time.Since(async.startedAt),
+		Error:    err,
+	}
+	if o.Error == nil {
+		o.Error = async.Command.Error()
+	}
+	if nc, ok := async.Command.(nodeCommand); ok {
+		o.Node = nc.getNode()
+	}
+	return o
+}
+
 func (c *Cluster) enqueueCommand(async *Async) error {
 	var err error
 	if c.isStateLessThan(clusterShuttingDown) {
 		command := async.Command
-		logDebug("[Cluster]", "enqueuing command '%s'", command.Name())
+		c.log.debug("[Cluster]", "enqueuing command '%s'", command.Name())
 		async.onEnqueued()
 		err = c.cq.enqueue(async)
 		if err != nil {
@@ -385,18 +885,18 @@ func (c *Cluster) enqueueCommand(async *Async) error {
 }
 
 func (c *Cluster) executeEnqueuedCommands() {
-	logDebug("[Cluster]", "(%v) command queue routine is starting", c)
+	c.log.debug("[Cluster]", "(%v) command queue routine is starting", c)
 	for {
 		select {
 		case <-c.stopChan:
-			logDebug("[Cluster]", "(%v) command queue routine is quitting", c)
+			c.log.debug("[Cluster]", "(%v) command queue routine is quitting", c)
 			return
 		case t := <-c.commandQueueTicker.C:
 			// NB: ensure we're not already shutting down
 			if c.isStateLessThan(clusterShuttingDown) {
 				var f = func(v interface{}) (bool, bool) {
 					if !c.isStateLessThan(clusterShuttingDown) {
-						logDebug("[Cluster]", "(%v) shutting down, command queue routine is quitting")
+						c.log.debug("[Cluster]", "(%v) shutting down, command queue routine is quitting")
 						return true, false
 					}
 					if v == nil {
@@ -405,20 +905,30 @@ func (c *Cluster) executeEnqueuedCommands() {
 					var re_enqueue bool
 					async := v.(*Async)
 					if t.After(async.executeAt) {
+						if c.asyncWorkers != nil {
+							select {
+							case c.asyncWorkers <- struct{}{}:
+							default:
+								// NB: blocking here, with the queue locked, could deadlock with
+								// a worker re-enqueuing its command, so try again next interval
+								c.log.debug("[Cluster]", "(%v) all async workers busy, keeping queued command '%s'", c, async.Command.Name())
+								return false, true
+							}
+						}
 						re_enqueue = false
-						logDebug("[Cluster]", "(%v) executing queued command '%s' at %v", c, async.Command.Name(), t)
-						go c.execute(async) // NB: *may* re-enqueue, so goroutine required
+						c.log.debug("[Cluster]", "(%v) executing queued command '%s' at %v", c, async.Command.Name(), t)
+						c.startAsyncWorker(async) // NB: *may* re-enqueue, so goroutine required
 					} else {
 						re_enqueue = true
-						logDebug("[Cluster]", "(%v) skipping queued command '%s'", c, async.Command.Name())
+						c.log.debug("[Cluster]", "(%v) skipping queued command '%s'", c, async.Command.Name())
 					}
 					return false, re_enqueue
 				}
 				if qerr := c.cq.iterate(f); qerr != nil {
-					logErr("[Cluster]", qerr)
+					c.log.err("[Cluster]", qerr)
 				}
 			} else {
-				logDebug("[Cluster]", "(%v) shutting down, command queue routine is quitting")
+				c.log.debug("[Cluster]", "(%v) shutting down, command queue routine is quitting")
 				return
 			}
 		}
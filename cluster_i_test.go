@@ -17,11 +17,21 @@
 package riak
 
 import (
+	"context"
+	"fmt"
 	"net"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	rpbRiak "github.com/basho/riak-go-client/rpb/riak"
+	rpbRiakDT "github.com/basho/riak-go-client/rpb/riak_dt"
+	rpbRiakKV "github.com/basho/riak-go-client/rpb/riak_kv"
+	rpbRiakSCH "github.com/basho/riak-go-client/rpb/riak_search"
+	rpbRiakYZ "github.com/basho/riak-go-client/rpb/riak_yokozuna"
+	proto "github.com/golang/protobuf/proto"
 )
 
 func TestExecuteCommandOnCluster(t *testing.T) {
@@ -525,3 +535,1206 @@ func TestRecoverFromReadTimeout(t *testing.T) {
 		}
 	}
 }
+
+func TestExecuteContextClosesSocketOnCancel(t *testing.T) {
+	doneChan := make(chan struct{})
+	defer close(doneChan)
+
+	var onConn = func(c net.Conn) bool {
+		if _, err := readClientMessage(c); err != nil {
+			return true
+		}
+		// NB: never respond, simulating a hung server
+		<-doneChan
+		c.Close()
+		return true
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	nodeOpts := &NodeOptions{
+		MinConnections: 1,
+		RequestTimeout: time.Second * 30,
+		RemoteAddress:  tl.addr.String(),
+	}
+	node, err := NewNode(nodeOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &ClusterOptions{
+		Nodes:             []*Node{node},
+		ExecutionAttempts: 3,
+	}
+	cluster, err := NewCluster(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cluster.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := cluster.Stop(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	if got, want := node.cm.count(), uint16(1); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(time.Millisecond*100, cancel)
+
+	cmd := &PingCommand{}
+	start := time.Now()
+	err = cluster.ExecuteContext(ctx, cmd)
+	if elapsed := time.Since(start); elapsed > time.Second*5 {
+		t.Errorf("expected ExecuteContext to return promptly, took %v", elapsed)
+	}
+	if got, want := err, context.Canceled; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if cmd.Success() {
+		t.Error("expected command to not be successful")
+	}
+	if got, want := node.cm.count(), uint16(0); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := node.getState(), nodeRunning; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestExecuteDataTypeUpdatesIsolatesErrorsAndBoundsInFlight(t *testing.T) {
+	maxInFlight := uint16(4)
+	var inFlight, maxSeen int32
+
+	var onConn = func(c net.Conn) bool {
+		msgCode, data, err := readClientMessageWithData(c)
+		if err != nil {
+			return true
+		}
+		current := atomic.AddInt32(&inFlight, 1)
+		for {
+			seen := atomic.LoadInt32(&maxSeen)
+			if current <= seen || atomic.CompareAndSwapInt32(&maxSeen, seen, current) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond * 10)
+		atomic.AddInt32(&inFlight, -1)
+
+		var resp []byte
+		req := &rpbRiakDT.DtUpdateReq{}
+		if msgCode != rpbCode_DtUpdateReq {
+			resp, err = buildRiakError("unexpected message code")
+		} else if err = proto.Unmarshal(data, req); err != nil {
+			t.Error(err)
+			return true
+		} else if string(req.Key) == "bad" {
+			resp, err = buildRiakError("bad key")
+		} else {
+			encoded, merr := proto.Marshal(&rpbRiakDT.DtUpdateResp{
+				CounterValue: proto.Int64(req.Op.CounterOp.GetIncrement()),
+			})
+			err = merr
+			resp = buildRiakMessage(rpbCode_DtUpdateResp, encoded)
+		}
+		if err != nil {
+			t.Error(err)
+			return true
+		}
+		if _, err = c.Write(resp); err != nil {
+			return true
+		}
+		return false
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		MinConnections: 1,
+		MaxConnections: 16,
+		RemoteAddress:  tl.addr.String(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster, err := NewCluster(&ClusterOptions{
+		Nodes:             []*Node{node},
+		ExecutionAttempts: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cluster.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := cluster.Stop(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	count := 32
+	commands := make([]Command, count)
+	for i := 0; i < count; i++ {
+		key := fmt.Sprintf("key_%d", i)
+		if i == 7 {
+			key = "bad"
+		}
+		cmd, err := NewUpdateCounterCommandBuilder().
+			WithBucketType("counters").
+			WithBucket("bucket").
+			WithKey(key).
+			WithIncrement(int64(i)).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		commands[i] = cmd
+	}
+
+	results, err := cluster.ExecuteDataTypeUpdates(commands, maxInFlight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(results), count; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i, r := range results {
+		if r.Command != commands[i] {
+			t.Errorf("result %d: expected results in command order", i)
+		}
+		if i == 7 {
+			if _, ok := r.Error.(ClientError); !ok {
+				t.Errorf("result %d: expected error, got %v", i, r.Error)
+			}
+			continue
+		}
+		if r.Error != nil {
+			t.Errorf("result %d: unexpected error %v", i, r.Error)
+			continue
+		}
+		uc := r.Command.(*UpdateCounterCommand)
+		if got, want := uc.Response.CounterValue, int64(i); got != want {
+			t.Errorf("result %d: got %v, want %v", i, got, want)
+		}
+	}
+	if got := atomic.LoadInt32(&maxSeen); got > int32(maxInFlight) {
+		t.Errorf("expected at most %d updates in flight, saw %d", maxInFlight, got)
+	}
+}
+
+func TestExecuteBatchTriesNextNode(t *testing.T) {
+	down := newTestListener(&testListenerOpts{test: t})
+	down.start()
+	downAddr := down.addr.String()
+	down.stop() // NB: nothing is listening, so the first node cannot execute the batch
+
+	up := newTestListener(&testListenerOpts{test: t})
+	up.start()
+	defer up.stop()
+
+	var nodes []*Node
+	for _, addr := range []string{downAddr, up.addr.String()} {
+		node, err := NewNode(&NodeOptions{
+			RemoteAddress:       addr,
+			HealthCheckInterval: time.Minute,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, node)
+	}
+	cluster, err := NewCluster(&ClusterOptions{Nodes: nodes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cluster.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Stop()
+
+	results, err := cluster.ExecuteBatch([]Command{&PingCommand{}, &PingCommand{}, &PingCommand{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range results {
+		if !r.Executed || r.Error != nil {
+			t.Errorf("command %d: got executed %v, error %v", i, r.Executed, r.Error)
+		}
+	}
+	if _, err = cluster.ExecuteBatch([]Command{nil}); err != ErrClusterCommandRequired {
+		t.Errorf("got %v, want %v", err, ErrClusterCommandRequired)
+	}
+}
+
+func TestExecuteSearchPagesFetchesAllDocuments(t *testing.T) {
+	numFound := uint32(7)
+	var starts []uint32
+	var startsMtx sync.Mutex
+
+	var onConn = func(c net.Conn) bool {
+		msgCode, data, err := readClientMessageWithData(c)
+		if err != nil {
+			return true
+		}
+		var resp []byte
+		req := &rpbRiakSCH.RpbSearchQueryReq{}
+		if msgCode != rpbCode_RpbSearchQueryReq {
+			resp, err = buildRiakError("unexpected message code")
+		} else if err = proto.Unmarshal(data, req); err != nil {
+			t.Error(err)
+			return true
+		} else {
+			startsMtx.Lock()
+			starts = append(starts, req.GetStart())
+			startsMtx.Unlock()
+			rsp := &rpbRiakSCH.RpbSearchQueryResp{
+				NumFound: proto.Uint32(numFound),
+			}
+			for i := req.GetStart(); i < numFound && i < req.GetStart()+req.GetRows(); i++ {
+				rsp.Docs = append(rsp.Docs, &rpbRiakSCH.RpbSearchDoc{
+					Fields: []*rpbRiak.RpbPair{
+						{Key: []byte("_yz_rk"), Value: []byte(fmt.Sprintf("key_%d", i))},
+					},
+				})
+			}
+			encoded, merr := proto.Marshal(rsp)
+			err = merr
+			resp = buildRiakMessage(rpbCode_RpbSearchQueryResp, encoded)
+		}
+		if err != nil {
+			t.Error(err)
+			return true
+		}
+		if _, err = c.Write(resp); err != nil {
+			return true
+		}
+		return false
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		MinConnections: 1,
+		RemoteAddress:  tl.addr.String(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster, err := NewCluster(&ClusterOptions{
+		Nodes:             []*Node{node},
+		ExecutionAttempts: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cluster.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := cluster.Stop(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	builder := NewSearchCommandBuilder().
+		WithIndexName("index").
+		WithQuery("*:*").
+		WithSortField("_yz_rk asc").
+		WithStart(1)
+	var keys []string
+	err = cluster.ExecuteSearchPages(builder, 3, func(docs []*SearchDoc) error {
+		for _, doc := range docs {
+			keys = append(keys, doc.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(keys), "[key_1 key_2 key_3 key_4 key_5 key_6]"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := fmt.Sprint(starts), "[1 4]"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if err = cluster.ExecuteSearchPages(builder, 0, nil); err != ErrClusterCommandRequired {
+		t.Errorf("got %v, want %v", err, ErrClusterCommandRequired)
+	}
+	if err = cluster.ExecuteSearchPages(builder, 0, func([]*SearchDoc) error { return nil }); err != ErrClusterSearchPageRowsRequired {
+		t.Errorf("got %v, want %v", err, ErrClusterSearchPageRowsRequired)
+	}
+}
+
+func TestExecuteSecondaryIndexFetchReportsEachKey(t *testing.T) {
+	var headOnlyFetches int32
+	var onConn = func(c net.Conn) bool {
+		msgCode, data, err := readClientMessageWithData(c)
+		if err != nil {
+			return true
+		}
+		var resps [][]byte
+		switch msgCode {
+		case rpbCode_RpbIndexReq:
+			req := &rpbRiakKV.RpbIndexReq{}
+			if err = proto.Unmarshal(data, req); err != nil {
+				t.Error(err)
+				return true
+			}
+			batches := []*rpbRiakKV.RpbIndexResp{
+				{Keys: [][]byte{[]byte("k1"), []byte("k2"), []byte("bad")}},
+			}
+			if req.GetStream() {
+				batches = []*rpbRiakKV.RpbIndexResp{
+					{Keys: [][]byte{[]byte("k1"), []byte("k2")}},
+					{Keys: [][]byte{[]byte("bad")}},
+					{Done: proto.Bool(true)},
+				}
+			}
+			for _, b := range batches {
+				encoded, merr := proto.Marshal(b)
+				if merr != nil {
+					t.Error(merr)
+					return true
+				}
+				resps = append(resps, buildRiakMessage(rpbCode_RpbIndexResp, encoded))
+			}
+		case rpbCode_RpbGetReq:
+			req := &rpbRiakKV.RpbGetReq{}
+			if err = proto.Unmarshal(data, req); err != nil {
+				t.Error(err)
+				return true
+			}
+			if req.GetHead() {
+				atomic.AddInt32(&headOnlyFetches, 1)
+			}
+			if string(req.Key) == "bad" {
+				resp, rerr := buildRiakError("bad key")
+				if rerr != nil {
+					t.Error(rerr)
+					return true
+				}
+				resps = append(resps, resp)
+				break
+			}
+			encoded, merr := proto.Marshal(&rpbRiakKV.RpbGetResp{
+				Content: []*rpbRiakKV.RpbContent{
+					{Value: req.Key},
+				},
+			})
+			if merr != nil {
+				t.Error(merr)
+				return true
+			}
+			resps = append(resps, buildRiakMessage(rpbCode_RpbGetResp, encoded))
+		default:
+			resp, _ := buildRiakError("unexpected message code")
+			resps = append(resps, resp)
+		}
+		for _, resp := range resps {
+			if _, err = c.Write(resp); err != nil {
+				return true
+			}
+		}
+		return false
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		MinConnections: 1,
+		RemoteAddress:  tl.addr.String(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster, err := NewCluster(&ClusterOptions{
+		Nodes:             []*Node{node},
+		ExecutionAttempts: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cluster.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := cluster.Stop(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	for _, streaming := range []bool{false, true} {
+		var streamed int32
+		query, err := NewSecondaryIndexQueryCommandBuilder().
+			WithBucket("bucket").
+			WithIndexName("idx_bin").
+			WithIndexKey("value").
+			WithStreaming(streaming).
+			WithCallback(func(results []*SecondaryIndexQueryResult) error {
+				atomic.AddInt32(&streamed, int32(len(results)))
+				return nil
+			}).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var keys, failed []string
+		err = cluster.ExecuteSecondaryIndexFetch(query, streaming, 2, func(r *SecondaryIndexFetchResult) error {
+			if r.Error != nil {
+				failed = append(failed, r.Key)
+				return nil
+			}
+			if got, want := string(r.Response.Values[0].Value), r.Key; got != want {
+				t.Errorf("got %v, want %v", got, want)
+			}
+			keys = append(keys, r.Key)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(keys)
+		if got, want := fmt.Sprint(keys), "[k1 k2]"; got != want {
+			t.Errorf("streaming %v: got %v, want %v", streaming, got, want)
+		}
+		if got, want := fmt.Sprint(failed), "[bad]"; got != want {
+			t.Errorf("streaming %v: got %v, want %v", streaming, got, want)
+		}
+		if streaming {
+			if got, want := atomic.LoadInt32(&streamed), int32(3); got != want {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		}
+	}
+	if got, want := atomic.LoadInt32(&headOnlyFetches), int32(3); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if err = cluster.ExecuteSecondaryIndexFetch(&PingCommand{}, false, 0, func(*SecondaryIndexFetchResult) error { return nil }); err == nil {
+		t.Error("expected non-nil error")
+	}
+}
+
+func TestExecuteSecondaryIndexFetchBoundsFetchesInFlight(t *testing.T) {
+	const numKeys = 10
+	var inFlight, maxInFlight int32
+	var onConn = func(c net.Conn) bool {
+		msgCode, data, err := readClientMessageWithData(c)
+		if err != nil {
+			return true
+		}
+		var resp []byte
+		switch msgCode {
+		case rpbCode_RpbIndexReq:
+			indexResp := &rpbRiakKV.RpbIndexResp{}
+			for i := 0; i < numKeys; i++ {
+				indexResp.Keys = append(indexResp.Keys, []byte(fmt.Sprintf("k%d", i)))
+			}
+			encoded, merr := proto.Marshal(indexResp)
+			if merr != nil {
+				t.Error(merr)
+				return true
+			}
+			resp = buildRiakMessage(rpbCode_RpbIndexResp, encoded)
+		case rpbCode_RpbGetReq:
+			req := &rpbRiakKV.RpbGetReq{}
+			if err = proto.Unmarshal(data, req); err != nil {
+				t.Error(err)
+				return true
+			}
+			n := atomic.AddInt32(&inFlight, 1)
+			for {
+				max := atomic.LoadInt32(&maxInFlight)
+				if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			atomic.AddInt32(&inFlight, -1)
+			encoded, merr := proto.Marshal(&rpbRiakKV.RpbGetResp{
+				Content: []*rpbRiakKV.RpbContent{
+					{Value: req.Key},
+				},
+			})
+			if merr != nil {
+				t.Error(merr)
+				return true
+			}
+			resp = buildRiakMessage(rpbCode_RpbGetResp, encoded)
+		default:
+			resp, _ = buildRiakError("unexpected message code")
+		}
+		if _, err = c.Write(resp); err != nil {
+			return true
+		}
+		return false
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		MinConnections: 1,
+		MaxConnections: 16,
+		RemoteAddress:  tl.addr.String(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster, err := NewCluster(&ClusterOptions{
+		Nodes: []*Node{node},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cluster.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := cluster.Stop(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	query, err := NewSecondaryIndexQueryCommandBuilder().
+		WithBucket("bucket").
+		WithIndexName("idx_bin").
+		WithIndexKey("value").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetched := make(map[string]string)
+	err = cluster.ExecuteSecondaryIndexFetch(query, false, 3, func(r *SecondaryIndexFetchResult) error {
+		if r.Error != nil {
+			t.Errorf("%s: %v", r.Key, r.Error)
+			return nil
+		}
+		fetched[r.Key] = string(r.Response.Values[0].Value)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(fetched), numKeys; got != want {
+		t.Errorf("got %v values, want %v", got, want)
+	}
+	for key, value := range fetched {
+		if key != value {
+			t.Errorf("got %v for key %v", value, key)
+		}
+	}
+	if got := atomic.LoadInt32(&maxInFlight); got > 3 || got < 2 {
+		t.Errorf("got %v fetches in flight, want 2 or 3", got)
+	}
+}
+
+func TestAddAndRemoveNodeWhileRunning(t *testing.T) {
+	pings := make([]int32, 2)
+	listeners := make([]*testListener, 2)
+	for i := range listeners {
+		count := &pings[i]
+		o := &testListenerOpts{
+			test: t,
+			onConn: func(c net.Conn) bool {
+				if readWriteResp(t, c, false) {
+					atomic.AddInt32(count, 1)
+					return false
+				}
+				return true
+			},
+		}
+		listeners[i] = newTestListener(o)
+		listeners[i].start()
+		defer listeners[i].stop()
+	}
+
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:  listeners[0].addr.String(),
+		MinConnections: 0,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster, err := NewCluster(&ClusterOptions{
+		Nodes: []*Node{node},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cluster.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := cluster.Stop(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	addedAddress := listeners[1].addr.String()
+	if err = cluster.AddNodeWithOptions(&NodeOptions{
+		RemoteAddress:  addedAddress,
+		MinConnections: 0,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err = cluster.AddNodeWithOptions(&NodeOptions{RemoteAddress: addedAddress}); err == nil {
+		t.Error("expected error adding a node with the same address")
+	}
+	nodes := cluster.getNodes()
+	if got, want := len(nodes), 2; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	added := nodes[1]
+	if got, want := added.isCurrentState(nodeRunning), true; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	execute := func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := cluster.Execute(&PingCommand{}); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	execute()
+	if atomic.LoadInt32(&pings[1]) == 0 {
+		t.Error("expected commands to be executed on the added node")
+	}
+
+	if err = cluster.RemoveNodeByAddress(addedAddress); err != nil {
+		t.Fatal(err)
+	}
+	if err = cluster.RemoveNodeByAddress(addedAddress); err == nil {
+		t.Error("expected error removing a node that is not in the cluster")
+	}
+	if got, want := len(cluster.getNodes()), 1; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := added.isCurrentState(nodeShutdown), true; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	removedPings := atomic.LoadInt32(&pings[1])
+	execute()
+	if got, want := atomic.LoadInt32(&pings[1]), removedPings; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSecondaryIndexQueryContinuationAcrossPages(t *testing.T) {
+	pair := func(term, key string) *rpbRiak.RpbPair {
+		return &rpbRiak.RpbPair{Key: []byte(term), Value: []byte(key)}
+	}
+	var onConn = func(c net.Conn) bool {
+		msgCode, data, err := readClientMessageWithData(c)
+		if err != nil {
+			return true
+		}
+		if msgCode != rpbCode_RpbIndexReq {
+			resp, _ := buildRiakError("unexpected message code")
+			c.Write(resp)
+			return false
+		}
+		req := &rpbRiakKV.RpbIndexReq{}
+		if err = proto.Unmarshal(data, req); err != nil {
+			t.Error(err)
+			return true
+		}
+		if got, want := req.GetMaxResults(), uint32(2); got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		var page *rpbRiakKV.RpbIndexResp
+		switch string(req.Continuation) {
+		case "":
+			page = &rpbRiakKV.RpbIndexResp{
+				Results:      []*rpbRiak.RpbPair{pair("1", "k1"), pair("2", "k2")},
+				Continuation: []byte("c1"),
+			}
+		case "c1":
+			page = &rpbRiakKV.RpbIndexResp{
+				Results: []*rpbRiak.RpbPair{pair("3", "k3")},
+			}
+		default:
+			t.Errorf("unexpected continuation %q", req.Continuation)
+			return true
+		}
+		batches := []*rpbRiakKV.RpbIndexResp{page}
+		if req.GetStream() {
+			// NB: Riak sends the continuation in its own message when streaming
+			batches = []*rpbRiakKV.RpbIndexResp{
+				{Results: page.Results},
+				{Continuation: page.Continuation},
+				{Done: proto.Bool(true)},
+			}
+		}
+		for _, b := range batches {
+			encoded, merr := proto.Marshal(b)
+			if merr != nil {
+				t.Error(merr)
+				return true
+			}
+			if _, err = c.Write(buildRiakMessage(rpbCode_RpbIndexResp, encoded)); err != nil {
+				return true
+			}
+		}
+		return false
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		MinConnections: 1,
+		RemoteAddress:  tl.addr.String(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster, err := NewCluster(&ClusterOptions{
+		Nodes: []*Node{node},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cluster.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := cluster.Stop(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	for _, streaming := range []bool{false, true} {
+		var pairs []string
+		var continuation []byte
+		for page := 0; page < 2; page++ {
+			var streamed []*SecondaryIndexQueryResult
+			cmd, err := NewSecondaryIndexQueryCommandBuilder().
+				WithBucket("bucket").
+				WithIndexName("idx_int").
+				WithIntRange(1, 10).
+				WithReturnKeyAndIndex(true).
+				WithMaxResults(2).
+				WithContinuation(continuation).
+				WithStreaming(streaming).
+				WithCallback(func(results []*SecondaryIndexQueryResult) error {
+					streamed = append(streamed, results...)
+					return nil
+				}).
+				Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = cluster.Execute(cmd); err != nil {
+				t.Fatal(err)
+			}
+			rsp := cmd.(*SecondaryIndexQueryCommand).Response
+			results := rsp.Results
+			if streaming {
+				results = streamed
+			}
+			for _, r := range results {
+				pairs = append(pairs, string(r.IndexKey)+"="+string(r.ObjectKey))
+			}
+			continuation = rsp.Continuation
+		}
+		if got, want := fmt.Sprint(pairs), "[1=k1 2=k2 3=k3]"; got != want {
+			t.Errorf("streaming %v: got %v, want %v", streaming, got, want)
+		}
+		if continuation != nil {
+			t.Errorf("streaming %v: expected no continuation after the last page, got %q", streaming, continuation)
+		}
+	}
+}
+
+func TestUpdateSetRemovesUsingReturnedContext(t *testing.T) {
+	var mu sync.Mutex
+	members := make(map[string]bool)
+	version := 0
+	setValue := func() [][]byte {
+		var value []string
+		for m := range members {
+			value = append(value, m)
+		}
+		sort.Strings(value)
+		result := make([][]byte, len(value))
+		for i, m := range value {
+			result[i] = []byte(m)
+		}
+		return result
+	}
+	var onConn = func(c net.Conn) bool {
+		msgCode, data, err := readClientMessageWithData(c)
+		if err != nil {
+			return true
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		context := []byte(fmt.Sprintf("ctx-%d", version))
+		var resp []byte
+		switch msgCode {
+		case rpbCode_DtUpdateReq:
+			req := &rpbRiakDT.DtUpdateReq{}
+			if err = proto.Unmarshal(data, req); err != nil {
+				t.Error(err)
+				return true
+			}
+			op := req.GetOp().GetSetOp()
+			if len(op.GetRemoves()) > 0 && string(req.GetContext()) != string(context) {
+				resp, err = buildRiakError("a current context is required to remove")
+				break
+			}
+			for _, a := range op.GetAdds() {
+				members[string(a)] = true
+			}
+			for _, r := range op.GetRemoves() {
+				delete(members, string(r))
+			}
+			version++
+			rsp := &rpbRiakDT.DtUpdateResp{}
+			if req.GetReturnBody() {
+				rsp.Context = []byte(fmt.Sprintf("ctx-%d", version))
+				rsp.SetValue = setValue()
+			}
+			var encoded []byte
+			encoded, err = proto.Marshal(rsp)
+			resp = buildRiakMessage(rpbCode_DtUpdateResp, encoded)
+		case rpbCode_DtFetchReq:
+			var encoded []byte
+			encoded, err = proto.Marshal(&rpbRiakDT.DtFetchResp{
+				Type:    rpbRiakDT.DtFetchResp_SET.Enum(),
+				Context: context,
+				Value:   &rpbRiakDT.DtValue{SetValue: setValue()},
+			})
+			resp = buildRiakMessage(rpbCode_DtFetchResp, encoded)
+		default:
+			resp, err = buildRiakError("unexpected message code")
+		}
+		if err != nil {
+			t.Error(err)
+			return true
+		}
+		if _, err = c.Write(resp); err != nil {
+			return true
+		}
+		return false
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		MinConnections: 1,
+		RemoteAddress:  tl.addr.String(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster, err := NewCluster(&ClusterOptions{
+		Nodes: []*Node{node},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cluster.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := cluster.Stop(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	cmd, err := NewUpdateSetCommandBuilder().
+		WithBucketType("sets").
+		WithBucket("bucket").
+		WithKey("key").
+		WithAdditions([]byte("a"), []byte("b")).
+		WithReturnBody(true).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cluster.Execute(cmd); err != nil {
+		t.Fatal(err)
+	}
+	added := cmd.(*UpdateSetCommand).Response
+	if got, want := fmt.Sprintf("%s", added.SetValue), "[a b]"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if len(added.Context) == 0 {
+		t.Fatal("expected a context to be returned")
+	}
+
+	_, err = NewUpdateSetCommandBuilder().
+		WithBucketType("sets").
+		WithBucket("bucket").
+		WithKey("key").
+		WithRemovals([]byte("a")).
+		Build()
+	if cerr, ok := err.(ClientError); !ok || cerr.InnerError != ErrContextRequired {
+		t.Errorf("got %v, want an error caused by %v", err, ErrContextRequired)
+	}
+
+	cmd, err = NewUpdateSetCommandBuilder().
+		WithBucketType("sets").
+		WithBucket("bucket").
+		WithKey("key").
+		WithContext(added.Context).
+		WithRemovals([]byte("a")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cluster.Execute(cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	fetch, err := NewFetchSetCommandBuilder().
+		WithBucketType("sets").
+		WithBucket("bucket").
+		WithKey("key").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cluster.Execute(fetch); err != nil {
+		t.Fatal(err)
+	}
+	fetched := fetch.(*FetchSetCommand).Response
+	if got, want := fmt.Sprintf("%s", fetched.SetValue), "[b]"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if len(fetched.Context) == 0 {
+		t.Error("expected a context to be fetched")
+	}
+}
+
+func TestExecuteStoreIndexAndWaitPollsUntilIndexExists(t *testing.T) {
+	testExecuteStoreIndexAndWait(t, &ClusterOptions{
+		ExecutionAttempts: 1,
+	})
+}
+
+// NB: with a RetryPolicy, the Cluster returns the RiakError itself rather than wrapping it
+func TestExecuteStoreIndexAndWaitPollsWithRetryPolicy(t *testing.T) {
+	testExecuteStoreIndexAndWait(t, &ClusterOptions{
+		RetryPolicy: &NoRetry{},
+	})
+}
+
+func testExecuteStoreIndexAndWait(t *testing.T, opts *ClusterOptions) {
+	var fetches int32
+	var onConn = func(c net.Conn) bool {
+		msgCode, data, err := readClientMessageWithData(c)
+		if err != nil {
+			return true
+		}
+		var resp []byte
+		switch msgCode {
+		case rpbCode_RpbYokozunaIndexPutReq:
+			resp = buildRiakMessage(rpbCode_RpbPutResp, nil)
+		case rpbCode_RpbYokozunaIndexGetReq:
+			req := &rpbRiakYZ.RpbYokozunaIndexGetReq{}
+			if err = proto.Unmarshal(data, req); err != nil {
+				t.Error(err)
+				return true
+			}
+			// NB: the index is created after it has been fetched twice
+			if atomic.AddInt32(&fetches, 1) <= 2 || string(req.Name) == "missing" {
+				resp, err = buildRiakError("notfound")
+				break
+			}
+			var encoded []byte
+			encoded, err = proto.Marshal(&rpbRiakYZ.RpbYokozunaIndexGetResp{
+				Index: []*rpbRiakYZ.RpbYokozunaIndex{{Name: req.Name}},
+			})
+			resp = buildRiakMessage(rpbCode_RpbYokozunaIndexGetResp, encoded)
+		default:
+			resp, err = buildRiakError("unexpected message code")
+		}
+		if err != nil {
+			t.Error(err)
+			return true
+		}
+		if _, err = c.Write(resp); err != nil {
+			return true
+		}
+		return false
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		MinConnections: 1,
+		RemoteAddress:  tl.addr.String(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.Nodes = []*Node{node}
+	cluster, err := NewCluster(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cluster.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := cluster.Stop(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	cmd, err := NewStoreIndexCommandBuilder().WithIndexName("index").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cluster.ExecuteStoreIndexAndWait(context.Background(), cmd, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := atomic.LoadInt32(&fetches), int32(3); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	cmd, err = NewStoreIndexCommandBuilder().WithIndexName("missing").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if got, want := cluster.ExecuteStoreIndexAndWait(ctx, cmd, 10*time.Millisecond), context.DeadlineExceeded; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if err = cluster.ExecuteStoreIndexAndWait(context.Background(), &PingCommand{}, 0); err == nil {
+		t.Error("expected error for a command other than StoreIndex")
+	}
+}
+
+func TestAfterExecuteReportsNodeOfNonRetryableCommand(t *testing.T) {
+	var onConn = func(c net.Conn) bool {
+		msgCode, err := readClientMessage(c)
+		if err != nil {
+			return true
+		}
+		var resp []byte
+		switch msgCode {
+		case rpbCode_RpbListBucketsReq:
+			var encoded []byte
+			encoded, err = proto.Marshal(&rpbRiakKV.RpbListBucketsResp{
+				Buckets: [][]byte{[]byte("b1")},
+			})
+			resp = buildRiakMessage(rpbCode_RpbListBucketsResp, encoded)
+		default:
+			resp = buildRiakMessage(rpbCode_RpbPingResp, nil)
+		}
+		if err != nil {
+			t.Error(err)
+			return true
+		}
+		if _, err = c.Write(resp); err != nil {
+			return true
+		}
+		return false
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		MinConnections: 1,
+		RemoteAddress:  tl.addr.String(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outcomes := make(chan *ExecuteOutcome, 1)
+	cluster, err := NewCluster(&ClusterOptions{
+		Nodes: []*Node{node},
+		AfterExecute: func(outcome *ExecuteOutcome) {
+			outcomes <- outcome
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cluster.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := cluster.Stop(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	cmd, err := NewListBucketsCommandBuilder().WithAllowListing().Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cmd.(retryableCommand); ok {
+		t.Fatal("expected ListBuckets not to be retryable")
+	}
+	if err = cluster.Execute(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if o := <-outcomes; o.Node != node {
+		t.Errorf("got %v, want %v", o.Node, node)
+	}
+}
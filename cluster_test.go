@@ -17,7 +17,10 @@ package riak
 import (
 	"fmt"
 	"net"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestCreateClusterWithDefaultOptions(t *testing.T) {
@@ -144,6 +147,258 @@ func TestAddAndRemoveNodeFromCluster(t *testing.T) {
 	}
 }
 
+func TestExecuteDataTypeUpdatesRequiresUpdateCommands(t *testing.T) {
+	cluster, err := NewCluster(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	update, err := NewUpdateCounterCommandBuilder().
+		WithBucketType("counters").
+		WithBucket("bucket").
+		WithKey("key").
+		WithIncrement(1).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commands := []Command{update, &PingCommand{}}
+	if _, err = cluster.ExecuteDataTypeUpdates(commands, 2); err == nil {
+		t.Error("expected error")
+	}
+	commands = []Command{update, nil}
+	if _, err = cluster.ExecuteDataTypeUpdates(commands, 2); err != ErrClusterCommandRequired {
+		t.Errorf("got %v, want %v", err, ErrClusterCommandRequired)
+	}
+}
+
+type blockingNodeManager struct {
+	release chan struct{}
+}
+
+func (nm *blockingNodeManager) ExecuteOnNode(nodes []*Node, command Command, previous *Node) (bool, error) {
+	<-nm.release
+	return true, nil
+}
+
+func TestExecuteAsyncIsBoundedByMaxAsyncWorkers(t *testing.T) {
+	nm := &blockingNodeManager{release: make(chan struct{})}
+	cluster, err := NewCluster(&ClusterOptions{
+		NoDefaultNode:   true,
+		NodeManager:     nm,
+		MaxAsyncWorkers: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster.setState(clusterRunning)
+
+	wg := &sync.WaitGroup{}
+	submitted := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			if err := cluster.ExecuteAsync(&Async{Command: &PingCommand{}, Wait: wg}); err != nil {
+				t.Error(err)
+			}
+		}
+		close(submitted)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if got, want := cluster.Stats().AsyncWorkersBusy, uint16(2); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	select {
+	case <-submitted:
+		t.Error("expected ExecuteAsync to block while all workers are busy")
+	default:
+	}
+
+	close(nm.release)
+	<-submitted
+	wg.Wait()
+	time.Sleep(10 * time.Millisecond)
+	if got, want := cluster.Stats().AsyncWorkersBusy, uint16(0); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// queueingNodeManager does not execute a Command the first time it is given, so that the Cluster
+// queues it, then blocks executing it until released
+type queueingNodeManager struct {
+	release   chan struct{}
+	seen      map[Command]bool
+	executing int32
+	most      int32
+	sync.Mutex
+}
+
+func (nm *queueingNodeManager) ExecuteOnNode(nodes []*Node, command Command, previous *Node) (bool, error) {
+	nm.Lock()
+	first := !nm.seen[command]
+	nm.seen[command] = true
+	nm.Unlock()
+	if first {
+		return false, nil
+	}
+	executing := atomic.AddInt32(&nm.executing, 1)
+	defer atomic.AddInt32(&nm.executing, -1)
+	nm.Lock()
+	if executing > nm.most {
+		nm.most = executing
+	}
+	nm.Unlock()
+	<-nm.release
+	return true, nil
+}
+
+func TestQueuedCommandsAreBoundedByMaxAsyncWorkers(t *testing.T) {
+	nm := &queueingNodeManager{
+		release: make(chan struct{}),
+		seen:    make(map[Command]bool),
+	}
+	cluster, err := NewCluster(&ClusterOptions{
+		NoDefaultNode:          true,
+		NodeManager:            nm,
+		MaxAsyncWorkers:        2,
+		QueueMaxDepth:          4,
+		QueueExecutionInterval: 5 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster.setState(clusterRunning)
+
+	wg := &sync.WaitGroup{}
+	go func() {
+		for i := 0; i < 4; i++ {
+			if err := cluster.ExecuteAsync(&Async{Command: &PingCommand{}, Wait: wg}); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	// NB: queued commands are first executed after the queue backoff
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&nm.executing) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	stats := cluster.Stats()
+	if got, want := stats.AsyncWorkersBusy, uint16(2); got != want {
+		t.Errorf("got %v busy workers, want %v", got, want)
+	}
+	if got, want := stats.MaxAsyncWorkers, uint16(2); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := atomic.LoadInt32(&nm.executing), int32(2); got != want {
+		t.Errorf("got %v queued commands executing, want %v", got, want)
+	}
+
+	close(nm.release)
+	wg.Wait()
+	nm.Lock()
+	most := nm.most
+	nm.Unlock()
+	if most > 2 {
+		t.Errorf("expected at most 2 queued commands executing at once, got %v", most)
+	}
+	time.Sleep(10 * time.Millisecond)
+	stats = cluster.Stats()
+	if got, want := stats.AsyncWorkersBusy, uint16(0); got != want {
+		t.Errorf("got %v busy workers, want %v", got, want)
+	}
+	if got, want := stats.QueuedCommands, uint16(0); got != want {
+		t.Errorf("got %v queued commands, want %v", got, want)
+	}
+}
+
+type failingNodeManager struct {
+	node     *Node
+	failures int
+}
+
+func (nm *failingNodeManager) ExecuteOnNode(nodes []*Node, command Command, previous *Node) (bool, error) {
+	if rc, ok := command.(retryableCommand); ok {
+		rc.setLastNode(nm.node)
+	}
+	if nc, ok := command.(nodeCommand); ok {
+		nc.setNode(nm.node)
+	}
+	if nm.failures > 0 {
+		nm.failures--
+		return true, newClientError("failed", nil)
+	}
+	return true, nil
+}
+
+func TestClusterExecuteHooksObserveOutcome(t *testing.T) {
+	node, err := NewNode(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var before []Command
+	var outcomes []*ExecuteOutcome
+	nm := &failingNodeManager{node: node, failures: 1}
+	cluster, err := NewCluster(&ClusterOptions{
+		NoDefaultNode:     true,
+		NodeManager:       nm,
+		ExecutionAttempts: 3,
+		BeforeExecute: func(cmd Command) {
+			before = append(before, cmd)
+		},
+		AfterExecute: func(outcome *ExecuteOutcome) {
+			outcomes = append(outcomes, outcome)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster.setState(clusterRunning)
+
+	cmd, err := NewFetchValueCommandBuilder().WithBucket("b").WithKey("k").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cluster.Execute(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(before), 1; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := len(outcomes), 1; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	o := outcomes[0]
+	if before[0] != cmd || o.Command != cmd {
+		t.Error("expected hooks to observe the executed command")
+	}
+	if got, want := o.Attempts, 2; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if o.Node != node {
+		t.Errorf("got %v, want %v", o.Node, node)
+	}
+	if o.Error != nil {
+		t.Errorf("expected nil error, got %v", o.Error)
+	}
+
+	// non-retryable commands are executed once, and also report their node
+	nm.failures = 1
+	if err = cluster.Execute(&SearchCommand{}); err == nil {
+		t.Fatal("expected non-nil error")
+	}
+	o = outcomes[1]
+	if got, want := o.Attempts, 1; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if o.Node != node {
+		t.Errorf("got %v, want %v", o.Node, node)
+	}
+	if o.Error != err {
+		t.Errorf("got %v, want %v", o.Error, err)
+	}
+}
+
 func TestCreateClusterWithFourNodes(t *testing.T) {
 	nodes := make([]*Node, 0, 4)
 	for port := 10017; port <= 10047; port += 10 {
@@ -181,6 +436,9 @@ func TestCreateClusterWithFourNodes(t *testing.T) {
 	if cluster.nodeManager == nil {
 		t.Error("expected cluster to have a node manager")
 	}
+	if expected, actual := 4, len(cluster.Stats().Nodes); expected != actual {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
 }
 
 func ExampleNewCluster() {
@@ -191,3 +449,68 @@ func ExampleNewCluster() {
 	fmt.Println(cluster.nodes[0].addr.String())
 	// Output: 127.0.0.1:8087
 }
+
+type countingNodeManager struct {
+	errs  []error // NB: returned in turn, then nil
+	calls int
+}
+
+func (nm *countingNodeManager) ExecuteOnNode(nodes []*Node, command Command, previous *Node) (bool, error) {
+	nm.calls++
+	if len(nm.errs) > 0 {
+		err := nm.errs[0]
+		nm.errs = nm.errs[1:]
+		return true, err
+	}
+	return true, nil
+}
+
+func TestClusterRetryPolicyRetriesTransientErrors(t *testing.T) {
+	transient := newClientError("transient", nil)
+	nm := &countingNodeManager{errs: []error{transient, transient}}
+	cluster, err := NewCluster(&ClusterOptions{
+		NoDefaultNode: true,
+		NodeManager:   nm,
+		RetryPolicy:   &ExponentialBackoff{MaxAttempts: 3, MinBackoff: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster.setState(clusterRunning)
+
+	cmd, err := NewFetchValueCommandBuilder().WithBucket("b").WithKey("k").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cluster.Execute(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := nm.calls, 3; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestClusterRetryPolicyDoesNotRetryRiakErrors(t *testing.T) {
+	permanent := RiakError{Errcode: 1, Errmsg: "{w_val_unsatisfied,2,1}"}
+	nm := &countingNodeManager{errs: []error{permanent, permanent}}
+	cluster, err := NewCluster(&ClusterOptions{
+		NoDefaultNode: true,
+		NodeManager:   nm,
+		RetryPolicy:   &ExponentialBackoff{MaxAttempts: 3, MinBackoff: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster.setState(clusterRunning)
+
+	cmd, err := NewFetchValueCommandBuilder().WithBucket("b").WithKey("k").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cluster.Execute(cmd), error(permanent); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := nm.calls, 1; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
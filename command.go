@@ -53,6 +53,7 @@ type commandImpl struct {
 	error   error
 	success bool
 	name    string
+	node    *Node
 }
 
 func (cmd *commandImpl) Success() bool {
@@ -72,6 +73,14 @@ func (cmd *commandImpl) onRetry() {
 	cmd.error = nil
 }
 
+func (cmd *commandImpl) setNode(node *Node) {
+	cmd.node = node
+}
+
+func (cmd *commandImpl) getNode() *Node {
+	return cmd.node
+}
+
 func (cmd *commandImpl) getName(n string) string {
 	if n == "" {
 		panic("getName: n must not be empty")
@@ -86,6 +95,13 @@ func (cmd *commandImpl) getName(n string) string {
 	return cmd.name
 }
 
+// Interface implemented by Command types that record the Node that last executed them, whether
+// or not they can be re-tried
+type nodeCommand interface {
+	setNode(*Node)
+	getNode() *Node
+}
+
 // Interface implemented by Command types that can be streamed
 type streamingCommand interface {
 	isDone() bool
@@ -104,6 +120,11 @@ func (cmd *timeoutImpl) getTimeout() time.Duration {
 	return cmd.timeout
 }
 
+// Interface implemented by Command types that record their round-trip time
+type timedCommand interface {
+	setDuration(time.Duration)
+}
+
 // Interface implemented by Commands that list data from Riak
 type listingCommand interface {
 	getAllowListing() bool
@@ -137,6 +158,18 @@ type Command interface {
 	getResponseProtobufMessage() proto.Message
 }
 
+// GetRequestCode returns the protobuf message code the Command sends to Riak. This allows tooling
+// to route or log Commands by message code without needing a type switch
+func GetRequestCode(cmd Command) byte {
+	return cmd.getRequestCode()
+}
+
+// GetResponseCode returns the protobuf message code the Command expects to receive from Riak. Riak
+// may instead respond with an RpbErrorResp, which has message code 0
+func GetResponseCode(cmd Command) byte {
+	return cmd.getResponseCode()
+}
+
 func getRiakMessage(cmd Command) (msg []byte, err error) {
 	requestCode := cmd.getRequestCode()
 	if requestCode == 0 {
@@ -74,6 +74,17 @@ func TestEnsureCorrectRequestAndResponseCodes(t *testing.T) {
 	if cmd.getResponseProtobufMessage() != nil {
 		t.Error("want nil response protobuf message")
 	}
+	// SetClientId
+	cmd = &setClientIdCommand{}
+	if got, want := cmd.getRequestCode(), rpbCode_RpbSetClientIdReq; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := cmd.getResponseCode(), rpbCode_RpbSetClientIdResp; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if cmd.getResponseProtobufMessage() != nil {
+		t.Error("want nil response protobuf message")
+	}
 	// FetchBucketTypeProps
 	cmd = &FetchBucketTypePropsCommand{}
 	if got, want := cmd.getRequestCode(), rpbCode_RpbGetBucketTypeReq; got != want {
@@ -220,6 +231,18 @@ func TestEnsureCorrectRequestAndResponseCodes(t *testing.T) {
 	if _, ok := msg.(*rpbRiakKV.RpbMapRedResp); !ok {
 		t.Errorf("error casting %v to RpbMapRedResp", reflect.TypeOf(msg))
 	}
+	// CountKeys
+	cmd = &CountKeysCommand{}
+	if got, want := cmd.getRequestCode(), rpbCode_RpbMapRedReq; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := cmd.getResponseCode(), rpbCode_RpbMapRedResp; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	msg = cmd.getResponseProtobufMessage()
+	if _, ok := msg.(*rpbRiakKV.RpbMapRedResp); !ok {
+		t.Errorf("error casting %v to RpbMapRedResp", reflect.TypeOf(msg))
+	}
 
 	// YZ commands
 	// StoreIndex
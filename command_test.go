@@ -79,3 +79,30 @@ func TestEnqueueDequeueCommandsConcurrently(t *testing.T) {
 		t.Error("expected non-nil err")
 	}
 }
+
+func TestGetRequestAndResponseCodes(t *testing.T) {
+	fetch, err := NewFetchValueCommandBuilder().
+		WithBucket("bucket").
+		WithKey("key").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		cmd          Command
+		requestCode  byte
+		responseCode byte
+	}{
+		{&PingCommand{}, rpbCode_RpbPingReq, rpbCode_RpbPingResp},
+		{&GetServerInfoCommand{}, rpbCode_RpbGetServerInfoReq, rpbCode_RpbGetServerInfoResp},
+		{fetch, rpbCode_RpbGetReq, rpbCode_RpbGetResp},
+	}
+	for _, tt := range tests {
+		if got, want := GetRequestCode(tt.cmd), tt.requestCode; got != want {
+			t.Errorf("%s: got %v, want %v", tt.cmd.Name(), got, want)
+		}
+		if got, want := GetResponseCode(tt.cmd), tt.responseCode; got != want {
+			t.Errorf("%s: got %v, want %v", tt.cmd.Name(), got, want)
+		}
+	}
+}
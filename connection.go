@@ -15,12 +15,14 @@
 package riak
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"net"
+	"sync/atomic"
 	"time"
 
 	backoff "github.com/basho/backoff"
@@ -33,19 +35,103 @@ var (
 	ErrCannotWrite = errors.New("Cannot write to a non-active or closed connection")
 )
 
+// ErrConnectionInvalidMessageLength is returned when the length prefix of a message from Riak is
+// zero, as every message has a code, or greater than the maximum message size
+const ErrConnectionInvalidMessageLength = "[Connection] invalid message length %d, must be between 1 and %d"
+
 // AuthOptions object contains the authentication credentials and tls config
+//
+// Each connection is upgraded to TLS via Riak's StartTls message as soon as it is established,
+// then authenticated, before any Command is executed. A connection is not created if either
+// fails. Riak only accepts TLS together with authentication, so there is no TLS-only option.
+//
+// The server certificate is verified against ServerName when set. Otherwise
+// TlsConfig.ServerName is used, falling back to the host portion of the Node's
+// RemoteAddress. Set ServerName when RemoteAddress is an IP address that does
+// not appear in the server certificate.
 type AuthOptions struct {
-	User      string
-	Password  string
-	TlsConfig *tls.Config
+	User       string
+	Password   string
+	ServerName string
+	TlsConfig  *tls.Config
+}
+
+// withServerName returns a copy of the AuthOptions using a copy of TlsConfig whose ServerName has
+// been set. The caller's TlsConfig is never modified
+func (o *AuthOptions) withServerName(remoteAddress string) (*AuthOptions, error) {
+	if o.TlsConfig == nil {
+		// NB: ErrAuthMissingConfig is returned when connecting
+		return o, nil
+	}
+	ao := *o
+	ao.TlsConfig = o.TlsConfig.Clone()
+	switch {
+	case ao.ServerName != "":
+		ao.TlsConfig.ServerName = ao.ServerName
+	case ao.TlsConfig.ServerName != "":
+		ao.ServerName = ao.TlsConfig.ServerName
+	default:
+		host, _, err := net.SplitHostPort(remoteAddress)
+		if err != nil {
+			return nil, newClientError(fmt.Sprintf("[AuthOptions] could not determine TLS server name from '%s'", remoteAddress), err)
+		}
+		ao.ServerName = host
+		ao.TlsConfig.ServerName = host
+	}
+	if ao.TlsConfig.InsecureSkipVerify {
+		logWarn("[AuthOptions]", "InsecureSkipVerify is set, server certificate for '%s' will NOT be verified", ao.ServerName)
+	}
+	return &ao, nil
 }
 
 type connectionOptions struct {
 	remoteAddress       *net.TCPAddr
 	connectTimeout      time.Duration
 	requestTimeout      time.Duration
+	streamFrameTimeout  time.Duration
 	authOptions         *AuthOptions
+	clientID            []byte
 	tempNetErrorRetries uint16
+	adaptiveTimeout     *adaptiveTimeout
+	keepAlive           time.Duration
+	noDelay             *bool
+	dialer              func(network, address string) (net.Conn, error)
+	maxMessageSize      uint32
+	dialHook            func(net.Conn) // NB: called once TCP options are applied, used by tests
+	stats               *connectionStats
+}
+
+// connectionStats counts the traffic and Commands of one or more connections. A Node's
+// connections share a single connectionStats, so the counters are updated atomically
+type connectionStats struct {
+	bytesSent        uint64
+	bytesReceived    uint64
+	commandsExecuted uint64
+	errors           uint64
+}
+
+func (s *connectionStats) sent(n int) {
+	atomic.AddUint64(&s.bytesSent, uint64(n))
+}
+
+func (s *connectionStats) received(n int) {
+	atomic.AddUint64(&s.bytesReceived, uint64(n))
+}
+
+func (s *connectionStats) executed(err error) {
+	atomic.AddUint64(&s.commandsExecuted, 1)
+	if err != nil {
+		atomic.AddUint64(&s.errors, 1)
+	}
+}
+
+func (s *connectionStats) snapshot() connectionStats {
+	return connectionStats{
+		bytesSent:        atomic.LoadUint64(&s.bytesSent),
+		bytesReceived:    atomic.LoadUint64(&s.bytesReceived),
+		commandsExecuted: atomic.LoadUint64(&s.commandsExecuted),
+		errors:           atomic.LoadUint64(&s.errors),
+	}
 }
 
 const (
@@ -60,13 +146,25 @@ type connection struct {
 	conn                net.Conn
 	connectTimeout      time.Duration
 	requestTimeout      time.Duration
+	streamFrameTimeout  time.Duration
 	tempNetErrorRetries uint16
 	authOptions         *AuthOptions
+	clientID            []byte
+	adaptiveTimeout     *adaptiveTimeout
+	keepAlive           time.Duration
+	noDelay             bool
+	dialer              func(network, address string) (net.Conn, error)
+	maxMessageSize      uint32
+	dialHook            func(net.Conn)
+	stats               *connectionStats
 	sizeBuf             []byte
 	dataBuf             []byte
 	active              bool
 	inFlight            bool
+	used                bool // NB: a Command has been executed on the connection
+	peerClosed          bool // NB: Riak closed the connection before responding to the last Command
 	lastUsed            time.Time
+	generation          uint32 // NB: pool generation, see connectionManager.recycle
 	stateData
 }
 
@@ -86,12 +184,35 @@ func newConnection(options *connectionOptions) (*connection, error) {
 	if options.tempNetErrorRetries == 0 {
 		options.tempNetErrorRetries = defaultTempNetErrorRetries
 	}
+	if options.keepAlive == 0 {
+		options.keepAlive = defaultKeepAlive
+	}
+	if options.maxMessageSize == 0 {
+		options.maxMessageSize = defaultMaxMessageSize
+	}
+	noDelay := true
+	if options.noDelay != nil {
+		noDelay = *options.noDelay
+	}
+	stats := options.stats
+	if stats == nil {
+		stats = &connectionStats{}
+	}
 	c := &connection{
 		addr:                options.remoteAddress,
 		connectTimeout:      options.connectTimeout,
 		requestTimeout:      options.requestTimeout,
+		streamFrameTimeout:  options.streamFrameTimeout,
 		tempNetErrorRetries: options.tempNetErrorRetries,
 		authOptions:         options.authOptions,
+		clientID:            options.clientID,
+		adaptiveTimeout:     options.adaptiveTimeout,
+		keepAlive:           options.keepAlive,
+		noDelay:             noDelay,
+		dialer:              options.dialer,
+		maxMessageSize:      options.maxMessageSize,
+		dialHook:            options.dialHook,
+		stats:               stats,
 		sizeBuf:             make([]byte, 4),
 		dataBuf:             make([]byte, defaultInitBuffer),
 		inFlight:            false,
@@ -103,26 +224,81 @@ func newConnection(options *connectionOptions) (*connection, error) {
 }
 
 func (c *connection) connect() (err error) {
-	dialer := &net.Dialer{
-		Timeout:   c.connectTimeout,
-		KeepAlive: time.Second * 30,
+	return c.connectContext(context.Background())
+}
+
+// connectContext is the same as connect, but will give up dialing or starting TLS should ctx be
+// cancelled or reach its deadline
+func (c *connection) connectContext(ctx context.Context) (err error) {
+	if c.dialer != nil {
+		c.conn, err = c.dialer("tcp", c.addr.String())
+	} else {
+		dialer := &net.Dialer{
+			Timeout:   c.connectTimeout,
+			KeepAlive: -1, // NB: keep-alive is configured in setTCPOptions
+		}
+		c.conn, err = dialer.DialContext(ctx, "tcp", c.addr.String())
+	}
+	if err == nil {
+		err = c.setTCPOptions()
 	}
-	c.conn, err = dialer.Dial("tcp", c.addr.String()) // NB: SetNoDelay() is true by default for TCP connections
 	if err != nil {
 		logError("[Connection]", "error when dialing %s: '%s'", c.addr.String(), err.Error())
 		c.close()
 	} else {
 		logDebug("[Connection]", "connected to: %s", c.addr)
-		if err = c.startTls(); err != nil {
+		if c.dialHook != nil {
+			c.dialHook(c.conn)
+		}
+		if ctx.Done() != nil {
+			// NB: closing the socket unblocks the TLS handshake and authentication
+			netConn := c.conn
+			stop := make(chan struct{})
+			defer close(stop)
+			go func() {
+				select {
+				case <-ctx.Done():
+					netConn.Close()
+				case <-stop:
+				}
+			}()
+		}
+		if err = c.startTls(); err == nil {
+			err = c.setClientId()
+		}
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				err = ctxErr
+			}
 			c.close()
 			c.setState(connInactive)
 			return
 		}
+		// NB: Commands executed while establishing the connection do not make it a reused one
+		c.used = false
 		c.setState(connActive)
 	}
 	return
 }
 
+// setTCPOptions applies the keep-alive and Nagle settings to the newly dialed socket. A negative
+// keepAlive disables keep-alive
+func (c *connection) setTCPOptions() error {
+	tcpConn, ok := c.conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+	if err := tcpConn.SetKeepAlive(c.keepAlive > 0); err != nil {
+		return err
+	}
+	if c.keepAlive > 0 {
+		if err := tcpConn.SetKeepAlivePeriod(c.keepAlive); err != nil {
+			return err
+		}
+	}
+	return tcpConn.SetNoDelay(c.noDelay)
+}
+
 func (c *connection) startTls() error {
 	if c.authOptions == nil {
 		return nil
@@ -150,10 +326,27 @@ func (c *connection) startTls() error {
 	return c.execute(authCmd)
 }
 
+// setClientId tags the connection with the configured client id, if any
+func (c *connection) setClientId() error {
+	if c.clientID == nil {
+		return nil
+	}
+	return c.execute(&setClientIdCommand{
+		clientID: c.clientID,
+	})
+}
+
 func (c *connection) available() bool {
 	return (c.conn != nil && c.isStateLessThan(connInactive))
 }
 
+// closedByPeer returns true if the last Command failed, before any response was read, as Riak had
+// closed the connection since an earlier Command, for example as it had been idle. The Command may
+// be sent again on another connection
+func (c *connection) closedByPeer() bool {
+	return c.peerClosed
+}
+
 func (c *connection) close() error {
 	if c.conn != nil {
 		err := c.conn.Close()
@@ -163,11 +356,26 @@ func (c *connection) close() error {
 	return nil
 }
 
+// resetBuffer releases a buffer that grew beyond maxIdleBuffer to hold a large response, before
+// the connection is returned to the pool. Smaller buffers are kept for the next Command, and need
+// no clearing as read slices them to the length of each message
+func (c *connection) resetBuffer() {
+	if cap(c.dataBuf) > maxIdleBuffer {
+		c.dataBuf = make([]byte, defaultInitBuffer)
+	}
+}
+
 func (c *connection) setInFlight(inFlightVal bool) {
 	c.inFlight = inFlightVal
 }
 
-func (c *connection) execute(cmd Command) (err error) {
+func (c *connection) execute(cmd Command) error {
+	return c.executeWithDeadline(cmd, time.Time{})
+}
+
+// executeWithDeadline is the same as execute, but reads and writes will not wait beyond deadline
+// if it is non-zero and earlier than the timeout that would otherwise be used
+func (c *connection) executeWithDeadline(cmd Command, deadline time.Time) (err error) {
 	if c.inFlight == true {
 		err = fmt.Errorf("[Connection] attempted to run '%s' command on in-use connection", cmd.Name())
 		return
@@ -184,6 +392,16 @@ func (c *connection) execute(cmd Command) (err error) {
 
 	c.setInFlight(true)
 	defer c.setInFlight(false)
+	reused := c.used
+	c.used = true
+	c.peerClosed = false
+	received := 0
+	defer func() {
+		if err != nil && reused && received == 0 && isClosedConnectionError(err) {
+			c.peerClosed = true
+		}
+		c.stats.executed(err)
+	}()
 	c.lastUsed = time.Now()
 
 	var message []byte
@@ -193,28 +411,48 @@ func (c *connection) execute(cmd Command) (err error) {
 	}
 
 	// Use the *greater* of the connection's request timeout
-	// or the Command's timeout
+	// (adapted to observed latency if enabled) or the Command's timeout
 	timeout := c.requestTimeout
+	if c.adaptiveTimeout != nil {
+		timeout = c.adaptiveTimeout.timeout(c.requestTimeout)
+	}
 	if tc, ok := cmd.(timeoutCommand); ok {
 		tc := tc.getTimeout()
 		if tc > timeout {
 			timeout = tc
 		}
 	}
+	// NB: a non-streaming Command that times out is observed too, as otherwise the adaptive
+	// timeout could not widen once Riak is slower than it, unless ctx cut the timeout short
+	_, streaming := cmd.(streamingCommand)
+	observeTimeout := c.adaptiveTimeout != nil && !streaming
+	if !deadline.IsZero() {
+		if d := time.Until(deadline); d < timeout {
+			timeout = d
+			observeTimeout = false
+		}
+	}
 
+	start := time.Now()
 	if err = c.write(message, timeout); err != nil {
 		return
 	}
 
 	var response []byte
 	var decoded proto.Message
+	readTimeout := timeout
 	for {
-		response, err = c.read(timeout) // NB: response *will* have entire pb message
+		response, err = c.read(readTimeout) // NB: response *will* have entire pb message
 		if err != nil {
+			if observeTimeout && isTimeoutNetError(err) {
+				c.adaptiveTimeout.observe(time.Since(start))
+			}
 			cmd.onError(err)
 			return
 		}
 
+		received++
+
 		// Maybe translate RpbErrorResp into golang error
 		if err = maybeRiakError(response); err != nil {
 			cmd.onError(err)
@@ -222,12 +460,18 @@ func (c *connection) execute(cmd Command) (err error) {
 		}
 
 		if decoded, err = decodeRiakMessage(cmd, response); err != nil {
+			// NB: an unexpected response may be followed by others, so the connection must not be reused
+			c.setState(connInactive)
 			cmd.onError(err)
 			return
 		}
 
 		err = cmd.onSuccess(decoded)
 		if err != nil {
+			if sc, ok := cmd.(streamingCommand); ok && !sc.isDone() {
+				// NB: the rest of the stream is unread, so the connection must not be reused
+				c.setState(connInactive)
+			}
 			cmd.onError(err)
 			return
 		}
@@ -237,13 +481,72 @@ func (c *connection) execute(cmd Command) (err error) {
 			if sc.isDone() {
 				return
 			}
+			// NB: once Riak has started streaming, a stall is detected by the frame timeout
+			if c.streamFrameTimeout > 0 {
+				readTimeout = c.streamFrameTimeout
+			}
 		} else {
 			// non-streaming command, done at this point
+			elapsed := time.Since(start)
+			if c.adaptiveTimeout != nil {
+				c.adaptiveTimeout.observe(elapsed)
+			}
+			if tc, ok := cmd.(timedCommand); ok {
+				tc.setDuration(elapsed)
+			}
 			return
 		}
 	}
 }
 
+// executeContext executes the Command and, should ctx be cancelled or reach its
+// deadline while the Command is in flight, closes the underlying socket to
+// unblock any pending read or write. The deadline of ctx, if any, also bounds
+// the socket deadlines. An aborted connection must be discarded.
+func (c *connection) executeContext(ctx context.Context, cmd Command) (err error) {
+	if ctx.Done() == nil {
+		return c.execute(cmd)
+	}
+	if err = ctx.Err(); err != nil {
+		cmd.onError(err)
+		return
+	}
+
+	netConn := c.conn
+	stop := make(chan struct{})
+	aborted := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			logDebug("[Connection]", "(%v) aborting '%s', closing socket: %v", c.addr, cmd.Name(), ctx.Err())
+			if netConn != nil {
+				netConn.Close() // NB: discard error, socket is being abandoned
+			}
+			aborted <- true
+		case <-stop:
+			aborted <- false
+		}
+	}()
+
+	deadline, _ := ctx.Deadline()
+	err = c.executeWithDeadline(cmd, deadline)
+	close(stop)
+
+	if <-aborted {
+		// NB: socket has already been closed
+		c.conn = nil
+		c.setState(connInactive)
+		err = ctx.Err()
+		cmd.onError(err)
+	} else if err != nil && !deadline.IsZero() && !time.Now().Before(deadline) {
+		// NB: the socket deadline, taken from ctx, may pass just before ctx is done
+		<-ctx.Done()
+		err = ctx.Err()
+		cmd.onError(err)
+	}
+	return
+}
+
 func (c *connection) setReadDeadline(t time.Duration) {
 	c.conn.SetReadDeadline(time.Now().Add(t))
 }
@@ -266,8 +569,15 @@ func (c *connection) read(timeout time.Duration) ([]byte, error) {
 
 	for {
 		c.setReadDeadline(rt)
-		if count, err = io.ReadFull(c.conn, c.sizeBuf); err == nil && count == 4 {
+		count, err = io.ReadFull(c.conn, c.sizeBuf)
+		c.stats.received(count)
+		if err == nil && count == 4 {
 			messageLength = binary.BigEndian.Uint32(c.sizeBuf)
+			if messageLength == 0 || messageLength > c.maxMessageSize {
+				// NB: the stream can no longer be trusted, so the connection must not be reused
+				c.setState(connInactive)
+				return nil, newClientError(fmt.Sprintf(ErrConnectionInvalidMessageLength, messageLength, c.maxMessageSize), nil)
+			}
 			if messageLength > uint32(cap(c.dataBuf)) {
 				logDebug("[Connection]", "allocating larger dataBuf of size %d", messageLength)
 				c.dataBuf = make([]byte, messageLength)
@@ -279,6 +589,7 @@ func (c *connection) read(timeout time.Duration) ([]byte, error) {
 			// ReadFull call. Currently it's could wait up to 2X the read timout value
 			c.setReadDeadline(rt)
 			count, err = io.ReadFull(c.conn, c.dataBuf)
+			c.stats.received(count)
 		} else {
 			if err == nil && count != 4 {
 				err = newClientError(fmt.Sprintf("[Connection] expected to read 4 bytes, only read: %d", count), nil)
@@ -310,11 +621,13 @@ func (c *connection) write(data []byte, timeout time.Duration) error {
 	}
 	c.conn.SetWriteDeadline(time.Now().Add(timeout))
 	count, err := c.conn.Write(data)
+	c.stats.sent(count)
 	if err != nil {
 		c.setState(connInactive)
 		return err
 	}
 	if count != len(data) {
+		c.setState(connInactive)
 		return newClientError(fmt.Sprintf("[Connection] data length: %d, only wrote: %d", len(data), count), nil)
 	}
 	return nil
@@ -17,11 +17,25 @@
 package riak
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"math/big"
 	"net"
 	"reflect"
 	"testing"
 	"time"
+
+	rpbRiak "github.com/basho/riak-go-client/rpb/riak"
+	rpbRiakKV "github.com/basho/riak-go-client/rpb/riak_kv"
+	proto "github.com/golang/protobuf/proto"
 )
 
 func TestSuccessfulConnection(t *testing.T) {
@@ -162,3 +176,823 @@ func TestConnectionSuccess(t *testing.T) {
 		t.Error("unexpected error:", err)
 	}
 }
+
+// newSelfSignedCertificate returns a certificate for 127.0.0.1 and a pool that trusts it
+func newSelfSignedCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "riak-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func TestConnectionStartTls(t *testing.T) {
+	cert, pool := newSelfSignedCertificate(t)
+	serverConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	authenticated := make(chan bool, 2)
+	var onConn = func(c net.Conn) bool {
+		defer c.Close()
+		if msgCode, err := readClientMessage(c); err != nil || msgCode != rpbCode_RpbStartTls {
+			t.Errorf("expected StartTls, got %v, err: %v", msgCode, err)
+			return true
+		}
+		if _, err := c.Write(buildRiakMessage(rpbCode_RpbStartTls, nil)); err != nil {
+			t.Error(err)
+			return true
+		}
+		tlsConn := tls.Server(c, serverConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			// NB: expected when the client does not trust the certificate
+			authenticated <- false
+			return true
+		}
+		if msgCode, err := readClientMessage(tlsConn); err != nil || msgCode != rpbCode_RpbAuthReq {
+			t.Errorf("expected AuthReq, got %v, err: %v", msgCode, err)
+			return true
+		}
+		if _, err := tlsConn.Write(buildRiakMessage(rpbCode_RpbAuthResp, nil)); err != nil {
+			t.Error(err)
+			return true
+		}
+		authenticated <- true
+		readWriteResp(t, tlsConn, false)
+		return true
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	conn, err := newConnection(&connectionOptions{
+		remoteAddress: tl.addr.(*net.TCPAddr),
+		authOptions: &AuthOptions{
+			User:      "riakuser",
+			Password:  "riakpass",
+			TlsConfig: &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = conn.connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.close()
+	if !<-authenticated {
+		t.Fatal("expected TLS handshake to succeed")
+	}
+	if _, ok := conn.conn.(*tls.Conn); !ok {
+		t.Errorf("expected *tls.Conn, got %v", reflect.TypeOf(conn.conn))
+	}
+	ping := &PingCommand{}
+	if err = conn.execute(ping); err != nil {
+		t.Fatal(err)
+	}
+	if !ping.Success() {
+		t.Error("expected ping to succeed")
+	}
+
+	// an untrusted certificate fails connection creation
+	untrusted, err := newConnection(&connectionOptions{
+		remoteAddress: tl.addr.(*net.TCPAddr),
+		authOptions: &AuthOptions{
+			User:      "riakuser",
+			Password:  "riakpass",
+			TlsConfig: &tls.Config{ServerName: "127.0.0.1"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = untrusted.connect(); err == nil {
+		t.Error("expected non-nil error")
+	}
+	if untrusted.available() {
+		t.Error("expected connection to be unavailable")
+	}
+	<-authenticated
+}
+
+func TestConnectionAuthentication(t *testing.T) {
+	cert, pool := newSelfSignedCertificate(t)
+	serverConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	var onConn = func(c net.Conn) bool {
+		defer c.Close()
+		if _, err := readClientMessage(c); err != nil {
+			return true
+		}
+		if _, err := c.Write(buildRiakMessage(rpbCode_RpbStartTls, nil)); err != nil {
+			return true
+		}
+		tlsConn := tls.Server(c, serverConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			return true
+		}
+		msgCode, data, err := readClientMessageWithData(tlsConn)
+		if err != nil {
+			t.Error(err)
+			return true
+		}
+		if msgCode != rpbCode_RpbAuthReq {
+			t.Errorf("got %v, want %v", msgCode, rpbCode_RpbAuthReq)
+			return true
+		}
+		req := &rpbRiak.RpbAuthReq{}
+		if err = proto.Unmarshal(data, req); err != nil {
+			t.Error(err)
+			return true
+		}
+		if got, want := string(req.User), "riakuser"; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		var resp []byte
+		if string(req.Password) == "riakpass" {
+			resp = buildRiakMessage(rpbCode_RpbAuthResp, nil)
+		} else if resp, err = buildRiakError("Authentication failed"); err != nil {
+			t.Error(err)
+			return true
+		}
+		if _, err = tlsConn.Write(resp); err != nil {
+			return true
+		}
+		// NB: wait for the client to close
+		ioutil.ReadAll(tlsConn)
+		return true
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	tests := []struct {
+		password  string
+		tlsConfig *tls.Config
+		succeeds  bool
+	}{
+		{"riakpass", &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}, true},
+		{"wrongpass", &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}, false},
+		{"riakpass", nil, false},
+	}
+	for i, tt := range tests {
+		conn, err := newConnection(&connectionOptions{
+			remoteAddress: tl.addr.(*net.TCPAddr),
+			authOptions: &AuthOptions{
+				User:      "riakuser",
+				Password:  tt.password,
+				TlsConfig: tt.tlsConfig,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = conn.connect()
+		if got, want := err == nil, tt.succeeds; got != want {
+			t.Errorf("%d: got %v, want %v, err: %v", i, got, want, err)
+		}
+		if got, want := conn.available(), tt.succeeds; got != want {
+			t.Errorf("%d: got %v, want %v", i, got, want)
+		}
+		if tt.tlsConfig == nil && err != ErrAuthMissingConfig {
+			t.Errorf("%d: got %v, want %v", i, err, ErrAuthMissingConfig)
+		}
+		if !tt.succeeds && tt.tlsConfig != nil {
+			if _, ok := err.(RiakError); !ok {
+				t.Errorf("%d: expected RiakError, got %v", i, err)
+			}
+		}
+		conn.close()
+	}
+}
+
+func TestConnectionSetsClientIdWhenConfigured(t *testing.T) {
+	codes := make(chan byte, 2)
+	var onConn = func(c net.Conn) bool {
+		defer c.Close()
+		msgCode, data, err := readClientMessageWithData(c)
+		if err != nil {
+			t.Error(err)
+			return true
+		}
+		codes <- msgCode
+		if msgCode == rpbCode_RpbSetClientIdReq {
+			req := &rpbRiakKV.RpbSetClientIdReq{}
+			if err = proto.Unmarshal(data, req); err != nil {
+				t.Error(err)
+				return true
+			}
+			if got, want := string(req.ClientId), "client-1"; got != want {
+				t.Errorf("got %v, want %v", got, want)
+			}
+			if _, err = c.Write(buildRiakMessage(rpbCode_RpbSetClientIdResp, nil)); err != nil {
+				return true
+			}
+			if msgCode, err = readClientMessage(c); err != nil {
+				t.Error(err)
+				return true
+			}
+			codes <- msgCode
+		}
+		c.Write(buildRiakMessage(rpbCode_RpbPingResp, nil))
+		return true
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	tests := []struct {
+		clientID []byte
+		want     []byte
+	}{
+		{[]byte("client-1"), []byte{rpbCode_RpbSetClientIdReq, rpbCode_RpbPingReq}},
+		{nil, []byte{rpbCode_RpbPingReq}},
+	}
+	for i, tt := range tests {
+		conn, err := newConnection(&connectionOptions{
+			remoteAddress: tl.addr.(*net.TCPAddr),
+			clientID:      tt.clientID,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = conn.connect(); err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if err = conn.execute(&PingCommand{}); err != nil {
+			t.Errorf("%d: %v", i, err)
+		}
+		conn.close()
+		var got []byte
+		for range tt.want {
+			got = append(got, <-codes)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d: got message codes %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestConnectionDeadlineBoundsRequestTimeout(t *testing.T) {
+	doneChan := make(chan struct{})
+	defer close(doneChan)
+	var onConn = func(c net.Conn) bool {
+		if _, err := readClientMessage(c); err != nil {
+			return true
+		}
+		<-doneChan // NB: never respond
+		c.Close()
+		return true
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	conn, err := newConnection(&connectionOptions{
+		remoteAddress:  tl.addr.(*net.TCPAddr),
+		requestTimeout: 30 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = conn.connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.close()
+
+	start := time.Now()
+	err = conn.executeWithDeadline(&PingCommand{}, start.Add(50*time.Millisecond))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected execute to return at the deadline, took %v", elapsed)
+	}
+	if !isTemporaryNetError(err) {
+		t.Errorf("expected a timeout error, got %v", err)
+	}
+}
+
+func TestConnectionRecordsPingDuration(t *testing.T) {
+	delay := 100 * time.Millisecond
+	var onConn = func(c net.Conn) bool {
+		if _, err := readClientMessage(c); err != nil {
+			return true
+		}
+		time.Sleep(delay)
+		if _, err := c.Write(buildRiakMessage(rpbCode_RpbPingResp, nil)); err != nil {
+			return true
+		}
+		return false
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	conn, err := newConnection(&connectionOptions{
+		remoteAddress: tl.addr.(*net.TCPAddr),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = conn.connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.close()
+
+	cmd := &PingCommand{}
+	if got, want := cmd.Duration(), time.Duration(0); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if err = conn.execute(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if d := cmd.Duration(); d < delay || d > delay+500*time.Millisecond {
+		t.Errorf("expected ping duration of about %v, got %v", delay, d)
+	}
+}
+
+func TestConnectionStreamsListKeysUntilDone(t *testing.T) {
+	chunks := [][]string{{"k1", "k2"}, {"k3"}, {"k4", "k5"}}
+	var onConn = func(c net.Conn) bool {
+		if _, err := readClientMessage(c); err != nil {
+			return true
+		}
+		for _, chunk := range chunks {
+			resp := &rpbRiakKV.RpbListKeysResp{}
+			for _, k := range chunk {
+				resp.Keys = append(resp.Keys, []byte(k))
+			}
+			encoded, err := proto.Marshal(resp)
+			if err != nil {
+				t.Error(err)
+				return true
+			}
+			if _, err = c.Write(buildRiakMessage(rpbCode_RpbListKeysResp, encoded)); err != nil {
+				return true
+			}
+		}
+		encoded, err := proto.Marshal(&rpbRiakKV.RpbListKeysResp{Done: proto.Bool(true)})
+		if err != nil {
+			t.Error(err)
+			return true
+		}
+		if _, err = c.Write(buildRiakMessage(rpbCode_RpbListKeysResp, encoded)); err != nil {
+			return true
+		}
+		return false
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	conn, err := newConnection(&connectionOptions{
+		remoteAddress: tl.addr.(*net.TCPAddr),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = conn.connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.close()
+
+	var streamed [][]string
+	cmd, err := NewListKeysCommandBuilder().
+		WithAllowListing().
+		WithBucket("bucket").
+		WithStreaming(true).
+		WithCallback(func(keys []string) error {
+			streamed = append(streamed, keys)
+			return nil
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = conn.execute(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := streamed, chunks; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if !conn.available() {
+		t.Fatal("expected connection to be reusable once the stream is drained")
+	}
+
+	// non-streaming accumulates the keys, using the same connection
+	cmd, err = NewListKeysCommandBuilder().
+		WithAllowListing().
+		WithBucket("bucket").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = conn.execute(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cmd.(*ListKeysCommand).Response.Keys, []string{"k1", "k2", "k3", "k4", "k5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// an error from the callback abandons the stream
+	callbackErr := newClientError("stop", nil)
+	cmd, err = NewListKeysCommandBuilder().
+		WithAllowListing().
+		WithBucket("bucket").
+		WithStreaming(true).
+		WithCallback(func(keys []string) error {
+			return callbackErr
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := conn.execute(cmd), error(callbackErr); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if conn.available() {
+		t.Error("expected connection with an unread stream to not be reusable")
+	}
+}
+
+func TestConnectionStreamFrameTimeoutDetectsStalledStream(t *testing.T) {
+	const firstFrameDelay = 150 * time.Millisecond
+	var onConn = func(c net.Conn) bool {
+		defer c.Close()
+		if _, err := readClientMessage(c); err != nil {
+			return true
+		}
+		// NB: the first frame may take longer than the frame timeout, as Riak starts the listing
+		time.Sleep(firstFrameDelay)
+		encoded, err := proto.Marshal(&rpbRiakKV.RpbListKeysResp{Keys: [][]byte{[]byte("k1")}})
+		if err != nil {
+			t.Error(err)
+			return true
+		}
+		if _, err = c.Write(buildRiakMessage(rpbCode_RpbListKeysResp, encoded)); err != nil {
+			return true
+		}
+		// NB: stall mid-stream until the client gives up
+		ioutil.ReadAll(c)
+		return true
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	conn, err := newConnection(&connectionOptions{
+		remoteAddress:      tl.addr.(*net.TCPAddr),
+		requestTimeout:     5 * time.Second,
+		streamFrameTimeout: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = conn.connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.close()
+
+	var streamed []string
+	cmd, err := NewListKeysCommandBuilder().
+		WithAllowListing().
+		WithBucket("bucket").
+		WithStreaming(true).
+		WithCallback(func(keys []string) error {
+			streamed = append(streamed, keys...)
+			return nil
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	err = conn.execute(cmd)
+	elapsed := time.Since(start)
+	if nerr, ok := err.(net.Error); !ok || !nerr.Timeout() {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if got, want := streamed, []string{"k1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if elapsed < firstFrameDelay+50*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("expected the stream to time out after the frame timeout, took %v", elapsed)
+	}
+	if conn.available() {
+		t.Error("expected connection with a stalled stream to not be reusable")
+	}
+}
+
+func TestConnectionUsesCommandTimeoutOverRequestTimeout(t *testing.T) {
+	delay := 200 * time.Millisecond
+	var onConn = func(c net.Conn) bool {
+		msgCode, err := readClientMessage(c)
+		if err != nil {
+			return true
+		}
+		var data []byte
+		switch msgCode {
+		case rpbCode_RpbMapRedReq:
+			encoded, merr := proto.Marshal(&rpbRiakKV.RpbMapRedResp{
+				Phase:    proto.Uint32(0),
+				Response: []byte("[1]"),
+				Done:     proto.Bool(true),
+			})
+			if merr != nil {
+				t.Error(merr)
+				return true
+			}
+			data = buildRiakMessage(rpbCode_RpbMapRedResp, encoded)
+		case rpbCode_RpbListBucketsReq:
+			encoded, merr := proto.Marshal(&rpbRiakKV.RpbListBucketsResp{
+				Buckets: [][]byte{[]byte("b1")},
+			})
+			if merr != nil {
+				t.Error(merr)
+				return true
+			}
+			data = buildRiakMessage(rpbCode_RpbListBucketsResp, encoded)
+		default:
+			data = buildRiakMessage(rpbCode_RpbPingResp, nil)
+		}
+		time.Sleep(delay)
+		if _, err = c.Write(data); err != nil {
+			return true
+		}
+		return false
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	mapReduce, err := NewMapReduceCommandBuilder().
+		WithQuery(`{"inputs":"bucket","query":[]}`).
+		WithTimeout(5 * time.Second).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	listBuckets, err := NewListBucketsCommandBuilder().
+		WithAllowListing().
+		WithTimeout(5 * time.Second).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		cmd         Command
+		wantTimeout bool
+	}{
+		{mapReduce, false},
+		{listBuckets, false},
+		{&PingCommand{}, true},
+	}
+	for _, tt := range tests {
+		conn, err := newConnection(&connectionOptions{
+			remoteAddress:  tl.addr.(*net.TCPAddr),
+			requestTimeout: delay / 4,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = conn.connect(); err != nil {
+			t.Fatal(err)
+		}
+		err = conn.execute(tt.cmd)
+		if tt.wantTimeout {
+			if !isTemporaryNetError(err) {
+				t.Errorf("%s: expected a timeout error, got %v", tt.cmd.Name(), err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: expected no error, got %v", tt.cmd.Name(), err)
+		}
+		conn.close()
+	}
+	if got, want := mapReduce.(*MapReduceCommand).Response, [][]byte{[]byte("[1]")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if got, want := listBuckets.(*ListBucketsCommand).Response.Buckets, []string{"b1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestConnectionAdaptiveTimeoutWidensAfterTimeouts(t *testing.T) {
+	doneChan := make(chan struct{})
+	defer close(doneChan)
+	var onConn = func(c net.Conn) bool {
+		if _, err := readClientMessage(c); err != nil {
+			return true
+		}
+		<-doneChan // NB: never respond
+		c.Close()
+		return true
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	minTimeout := 20 * time.Millisecond
+	at := newAdaptiveTimeout(&AdaptiveTimeoutOptions{
+		Multiplier: 2,
+		MinTimeout: minTimeout,
+		WindowSize: 32,
+	}, 5*time.Second)
+	for i := 0; i < adaptiveMinSamples; i++ {
+		at.observe(time.Millisecond)
+	}
+	if got, want := at.timeout(5*time.Second), minTimeout; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// NB: each timed out ping is observed, until the timeout is above the min
+	for i := 0; i < 2; i++ {
+		conn, err := newConnection(&connectionOptions{
+			remoteAddress:   tl.addr.(*net.TCPAddr),
+			requestTimeout:  5 * time.Second,
+			adaptiveTimeout: at,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = conn.connect(); err != nil {
+			t.Fatal(err)
+		}
+		if err = conn.execute(&PingCommand{}); !isTimeoutNetError(err) {
+			t.Errorf("expected a timeout error, got %v", err)
+		}
+		conn.close()
+	}
+	if _, count := at.latencies.percentile(0.99); count != adaptiveMinSamples+2 {
+		t.Errorf("expected timeouts to be observed, got %v samples", count)
+	}
+	if got := at.timeout(5 * time.Second); got < 2*minTimeout {
+		t.Errorf("expected the timeout to widen to at least %v, got %v", 2*minTimeout, got)
+	}
+}
+
+func TestConnectionRejectsInvalidMessageLength(t *testing.T) {
+	for _, length := range []uint32{0xFFFFFFFF, 1025, 0} {
+		prefix := make([]byte, 4)
+		binary.BigEndian.PutUint32(prefix, length)
+		var onConn = func(c net.Conn) bool {
+			if _, err := readClientMessage(c); err != nil {
+				return true
+			}
+			// NB: the message body is never sent, reading it would block until the timeout
+			if _, err := c.Write(prefix); err != nil {
+				return true
+			}
+			return false
+		}
+		o := &testListenerOpts{
+			test:   t,
+			onConn: onConn,
+		}
+		tl := newTestListener(o)
+		tl.start()
+
+		conn, err := newConnection(&connectionOptions{
+			remoteAddress:  tl.addr.(*net.TCPAddr),
+			requestTimeout: 30 * time.Second,
+			maxMessageSize: 1024,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = conn.connect(); err != nil {
+			t.Fatal(err)
+		}
+
+		start := time.Now()
+		ping := &PingCommand{}
+		err = conn.execute(ping)
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%d: expected execute to fail immediately, took %v", length, elapsed)
+		}
+		if got, want := err, newClientError(fmt.Sprintf(ErrConnectionInvalidMessageLength, length, 1024), nil); got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if ping.Error() == nil {
+			t.Errorf("%d: expected the command to have failed", length)
+		}
+		if conn.available() {
+			t.Errorf("%d: expected connection to be unavailable", length)
+		}
+		conn.close()
+		tl.stop()
+	}
+}
+
+func TestConnectionValidatesResponseCode(t *testing.T) {
+	errResp, err := proto.Marshal(&rpbRiak.RpbErrorResp{
+		Errcode: proto.Uint32(42),
+		Errmsg:  []byte("overload"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		resp      []byte
+		want      error
+		available bool
+	}{
+		{buildRiakMessage(rpbCode_RpbErrorResp, errResp), RiakError{Errcode: 42, Errmsg: "overload"}, true},
+		{buildRiakMessage(rpbCode_RpbGetResp, nil), newClientError(fmt.Sprintf("expected response code %d, got: %d", rpbCode_RpbPingResp, rpbCode_RpbGetResp), nil), false},
+	}
+	for _, tt := range tests {
+		resp := tt.resp
+		var onConn = func(c net.Conn) bool {
+			if _, err := readClientMessage(c); err != nil {
+				return true
+			}
+			if _, err := c.Write(resp); err != nil {
+				return true
+			}
+			return false
+		}
+		o := &testListenerOpts{
+			test:   t,
+			onConn: onConn,
+		}
+		tl := newTestListener(o)
+		tl.start()
+
+		conn, err := newConnection(&connectionOptions{
+			remoteAddress: tl.addr.(*net.TCPAddr),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = conn.connect(); err != nil {
+			t.Fatal(err)
+		}
+
+		ping := &PingCommand{}
+		if got, want := conn.execute(ping), tt.want; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := ping.Error(), tt.want; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		// NB: a Riak error leaves the stream intact, an unexpected message does not
+		if got, want := conn.available(), tt.available; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		conn.close()
+		tl.stop()
+	}
+}
@@ -15,6 +15,7 @@
 package riak
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"net"
@@ -89,9 +90,19 @@ type connectionManagerOptions struct {
 	tempNetErrorRetries    uint16
 	idleExpirationInterval time.Duration
 	idleTimeout            time.Duration
+	maxIdleExpirations     uint16
 	connectTimeout         time.Duration
 	requestTimeout         time.Duration
+	streamFrameTimeout     time.Duration
 	authOptions            *AuthOptions
+	clientID               []byte
+	adaptiveTimeout        *adaptiveTimeout
+	keepAlive              time.Duration
+	noDelay                *bool
+	dialer                 func(network, address string) (net.Conn, error)
+	maxMessageSize         uint32
+	maxConnectionWait      time.Duration
+	drainTimeout           time.Duration
 }
 
 type connectionManager struct {
@@ -101,13 +112,31 @@ type connectionManager struct {
 	tempNetErrorRetries    uint16
 	idleExpirationInterval time.Duration
 	idleTimeout            time.Duration
+	maxIdleExpirations     uint16
 	connectTimeout         time.Duration
 	requestTimeout         time.Duration
+	streamFrameTimeout     time.Duration
 	authOptions            *AuthOptions
+	clientID               []byte
+	adaptiveTimeout        *adaptiveTimeout
+	keepAlive              time.Duration
+	noDelay                *bool
+	dialer                 func(network, address string) (net.Conn, error)
+	maxMessageSize         uint32
+	maxConnectionWait      time.Duration
+	drainTimeout           time.Duration
+	waitMtx                sync.Mutex
+	waitCond               *sync.Cond // NB: signalled when a connection is returned or removed
+	returned               uint64     // NB: protected by waitMtx
+	stats                  *connectionStats
 	stopChan               chan struct{}
 	q                      *queue
 	expireTicker           *time.Ticker
 	connectionCounter      connectionCounter
+	closeErrs              closeErrors
+	generation             uint32
+	retiring               *retiringConnections
+	recycleMtx             sync.Mutex
 	sync.RWMutex
 	stateData
 }
@@ -117,8 +146,40 @@ var (
 	ErrConnectionManagerRequiresAddress         = newClientError("[connectionManager] new manager requires non-nil address", nil)
 	ErrConnectionManagerMaxMustBeGreaterThanMin = newClientError("[connectionManager] new connection manager maxConnections must be greater than minConnections", nil)
 	ErrConnMgrAllConnectionsInUse               = newClientError("[connectionManager] all connections in use / max connections reached", nil)
+	ErrConnMgrNoConnections                     = newClientError("[connectionManager] could not establish any of minConnections", nil)
 )
 
+const (
+	ErrConnMgrCloseConnections = "[connectionManager] error(s) closing %d connection(s) during shutdown"
+	ErrConnMgrRecycle          = "[connectionManager] %d error(s) recycling connections"
+	ErrConnMgrRecycleTimeout   = "[connectionManager] timed out waiting for %d in-flight connection(s) to drain"
+	ErrConnMgrStopTimeout      = "[connectionManager] timed out waiting for %d in-flight connection(s) during shutdown"
+)
+
+// closeErrors collects errors from closing connections during shutdown, so that
+// they may be reported rather than discarded
+type closeErrors struct {
+	errs []error
+	sync.Mutex
+}
+
+func (ce *closeErrors) add(err error) {
+	ce.Lock()
+	defer ce.Unlock()
+	ce.errs = append(ce.errs, err)
+}
+
+func (ce *closeErrors) error() error {
+	ce.Lock()
+	defer ce.Unlock()
+	if len(ce.errs) == 0 {
+		return nil
+	}
+	errs := make(errorList, len(ce.errs))
+	copy(errs, ce.errs)
+	return newClientError(fmt.Sprintf(ErrConnMgrCloseConnections, len(errs)), errs)
+}
+
 func newConnectionManager(options *connectionManagerOptions) (*connectionManager, error) {
 	if options == nil {
 		return nil, ErrConnectionManagerRequiresOptions
@@ -157,12 +218,24 @@ func newConnectionManager(options *connectionManagerOptions) (*connectionManager
 		tempNetErrorRetries:    options.tempNetErrorRetries,
 		idleExpirationInterval: options.idleExpirationInterval,
 		idleTimeout:            options.idleTimeout,
+		maxIdleExpirations:     options.maxIdleExpirations,
 		connectTimeout:         options.connectTimeout,
 		requestTimeout:         options.requestTimeout,
+		streamFrameTimeout:     options.streamFrameTimeout,
 		authOptions:            options.authOptions,
+		clientID:               options.clientID,
+		adaptiveTimeout:        options.adaptiveTimeout,
+		keepAlive:              options.keepAlive,
+		noDelay:                options.noDelay,
+		dialer:                 options.dialer,
+		maxMessageSize:         options.maxMessageSize,
+		maxConnectionWait:      options.maxConnectionWait,
+		drainTimeout:           options.drainTimeout,
+		stats:                  &connectionStats{},
 		stopChan:               make(chan struct{}),
-		q:                      newQueue(options.maxConnections),
+		q:                      newQueue(options.maxConnections), // NB: allocated for maxConnections up front, never grows
 	}
+	cm.waitCond = sync.NewCond(&cm.waitMtx)
 	cm.initStateData("connMgrError", "connMgrCreated", "connMgrRunning", "connMgrShuttingDown", "connMgrShutdown")
 	cm.setState(cmCreated)
 	return cm, nil
@@ -176,9 +249,11 @@ func (cm *connectionManager) start() error {
 	if err := cm.stateCheck(cmCreated); err != nil {
 		return err
 	}
+	created := uint16(0)
 	for i := uint16(0); i < cm.minConnections; i++ {
 		conn, err := cm.create()
 		if err == nil {
+			created++
 			if perr := cm.put(conn); perr != nil {
 				logErr("[connectionManager]", perr)
 			}
@@ -189,22 +264,38 @@ func (cm *connectionManager) start() error {
 	cm.expireTicker = time.NewTicker(cm.idleExpirationInterval)
 	go cm.manageConnections()
 	cm.setState(cmRunning)
+	// NB: the manager runs regardless, so connections can be created once Riak is reachable
+	if created == 0 && cm.minConnections > 0 {
+		return ErrConnMgrNoConnections
+	}
 	return nil
 }
 
 func (cm *connectionManager) stop() error {
-	if err := cm.stateCheck(cmRunning); err != nil {
+	if err := cm.stateCheck(cmCreated, cmRunning); err != nil {
 		return err
 	}
 
 	logDebug("[connectionManager]", "shutting down")
 
+	if cm.isCurrentState(cmCreated) {
+		// NB: never started, so there are no connections and manageConnections is not running
+		cm.setState(cmShutdown)
+		return nil
+	}
+
 	cm.setState(cmShuttingDown)
+	cm.signalWaiters()
 	close(cm.stopChan)
 	cm.expireTicker.Stop()
 
-	if cm.count() != cm.q.count() {
+	// NB: connections returned from now on are closed by put
+	var drainErr error
+	if inFlight := cm.drain(cm.drainTimeout); inFlight > 0 {
 		logError("[connectionManager]", "stop: current connection count '%d' does NOT equal q count '%d'", cm.count(), cm.q.count())
+		if cm.drainTimeout > 0 {
+			drainErr = newClientError(fmt.Sprintf(ErrConnMgrStopTimeout, inFlight), nil)
+		}
 	}
 
 	cm.Lock()
@@ -217,6 +308,7 @@ func (cm *connectionManager) stop() error {
 		conn := v.(*connection)
 		if err := conn.close(); err != nil {
 			logErr("[connectionManager] error when closing connection in stop()", err)
+			cm.closeErrs.add(err)
 		}
 
 		if cm.connectionCounter.decrement() == 0 {
@@ -229,7 +321,11 @@ func (cm *connectionManager) stop() error {
 	cm.q.destroy()
 
 	if err == nil {
+		// NB: errors closing connections do not prevent shutdown, but are reported
 		cm.setState(cmShutdown)
+		if err = cm.closeErrs.error(); err == nil {
+			err = drainErr
+		}
 	} else {
 		cm.setState(cmError)
 	}
@@ -237,6 +333,38 @@ func (cm *connectionManager) stop() error {
 	return err
 }
 
+// drain waits up to timeout for in-flight connections to be returned, and returns the number
+// that remain in flight
+func (cm *connectionManager) drain(timeout time.Duration) uint16 {
+	inFlight := func() uint16 {
+		if total, idle := cm.count(), cm.q.count(); total > idle {
+			return total - idle
+		}
+		return 0
+	}
+	if timeout <= 0 {
+		return inFlight()
+	}
+
+	timedOut := false
+	timer := time.AfterFunc(timeout, func() {
+		cm.waitMtx.Lock()
+		timedOut = true
+		cm.waitMtx.Unlock()
+		cm.waitCond.Broadcast()
+	})
+	defer timer.Stop()
+
+	cm.waitMtx.Lock()
+	defer cm.waitMtx.Unlock()
+	for {
+		if n := inFlight(); n == 0 || timedOut {
+			return n
+		}
+		cm.waitCond.Wait()
+	}
+}
+
 func (cm *connectionManager) count() uint16 {
 	return cm.connectionCounter.count()
 }
@@ -246,39 +374,115 @@ func (cm *connectionManager) create() (*connection, error) {
 		return nil, nil
 	}
 
+	// NB: the slot is reserved before dialing so that the lock is not held while connecting,
+	// which would stall every other pool operation behind a slow or unreachable node
 	cm.Lock()
-	defer cm.Unlock()
-
 	if cm.connectionCounter.isGreaterThanOrEqual(cm.maxConnections) {
+		cm.Unlock()
 		return nil, ErrConnMgrAllConnectionsInUse
 	}
+	cm.connectionCounter.increment()
+	generation := cm.generation
+	cm.Unlock()
 
 	conn, err := cm.createConnection()
 	if err != nil {
+		cm.release(generation)
 		return nil, err
 	}
 
-	cm.connectionCounter.increment()
+	conn.generation = generation
 	return conn, nil
 }
 
+// release gives up a slot reserved by create for a connection that could not be established
+func (cm *connectionManager) release(generation uint32) {
+	defer cm.signalWaiters()
+	cm.connectionCounter.decrement()
+	cm.RLock()
+	retired := generation != cm.generation
+	cm.RUnlock()
+	if retired {
+		// NB: the slot was counted by a recycle() that started while dialing
+		cm.accountRetired(false, nil)
+	}
+}
+
 func (cm *connectionManager) createConnection() (*connection, error) {
+	return cm.createConnectionContext(context.Background())
+}
+
+func (cm *connectionManager) createConnectionContext(ctx context.Context) (*connection, error) {
 	opts := &connectionOptions{
 		remoteAddress:       cm.addr,
 		connectTimeout:      cm.connectTimeout,
 		requestTimeout:      cm.requestTimeout,
+		streamFrameTimeout:  cm.streamFrameTimeout,
 		authOptions:         cm.authOptions,
+		clientID:            cm.clientID,
 		tempNetErrorRetries: cm.tempNetErrorRetries,
+		adaptiveTimeout:     cm.adaptiveTimeout,
+		keepAlive:           cm.keepAlive,
+		noDelay:             cm.noDelay,
+		dialer:              cm.dialer,
+		maxMessageSize:      cm.maxMessageSize,
+		stats:               cm.stats,
 	}
 	conn, err := newConnection(opts)
 	if err != nil {
 		return nil, err
 	}
-	err = conn.connect()
+	err = conn.connectContext(ctx)
 	return conn, err
 }
 
+// get returns a pooled connection, or a new one if none are available. If maxConnections are in
+// use, it waits up to maxConnectionWait for one to be returned to the pool
 func (cm *connectionManager) get() (*connection, error) {
+	conn, err := cm.tryGet()
+	if err != ErrConnMgrAllConnectionsInUse || cm.maxConnectionWait <= 0 {
+		return conn, err
+	}
+
+	timedOut := false
+	timer := time.AfterFunc(cm.maxConnectionWait, func() {
+		cm.waitMtx.Lock()
+		timedOut = true
+		cm.waitMtx.Unlock()
+		cm.waitCond.Broadcast()
+	})
+	defer timer.Stop()
+
+	for {
+		cm.waitMtx.Lock()
+		seen := cm.returned
+		cm.waitMtx.Unlock()
+
+		if conn, err = cm.tryGet(); err != ErrConnMgrAllConnectionsInUse {
+			return conn, err
+		}
+
+		cm.waitMtx.Lock()
+		for cm.returned == seen && !timedOut {
+			cm.waitCond.Wait()
+		}
+		stop := timedOut || !cm.isStateLessThan(cmShuttingDown)
+		cm.waitMtx.Unlock()
+		if stop {
+			return nil, ErrConnMgrAllConnectionsInUse
+		}
+	}
+}
+
+// signalWaiters wakes callers of get that are waiting for a connection
+func (cm *connectionManager) signalWaiters() {
+	cm.waitMtx.Lock()
+	cm.returned++
+	cm.waitMtx.Unlock()
+	cm.waitCond.Broadcast()
+}
+
+func (cm *connectionManager) tryGet() (*connection, error) {
 	var conn *connection
 	var f = func(v interface{}) (bool, bool) {
 		if v == nil {
@@ -310,25 +514,183 @@ func (cm *connectionManager) get() (*connection, error) {
 }
 
 func (cm *connectionManager) put(conn *connection) error {
+	defer cm.signalWaiters()
 	if cm.isStateLessThan(cmShuttingDown) {
+		if cm.isRetired(conn) {
+			cm.retire(conn)
+			return nil
+		}
+		if !conn.available() {
+			// NB: the connection is in an unknown protocol state, e.g. after a read error, so
+			// bytes from its last Command could be read by the next one
+			logDebug("[connectionManager]", "(%v)|closing inactive connection instead of returning it", cm)
+			return cm.remove(conn)
+		}
+		conn.resetBuffer()
 		return cm.q.enqueue(conn)
 	} else {
 		// shutting down
 		logDebug("[connectionManager]", "(%v)|Connection returned during shutdown.", cm)
 		cm.connectionCounter.decrement()
-		conn.close() // NB: discard error
+		if err := conn.close(); err != nil {
+			logErr("[connectionManager] error when closing connection returned during shutdown", err)
+			cm.closeErrs.add(err)
+		}
 	}
 	return nil
 }
 
 func (cm *connectionManager) remove(conn *connection) error {
+	defer cm.signalWaiters()
 	if cm.isStateLessThan(cmShuttingDown) {
+		if cm.isRetired(conn) {
+			return cm.retire(conn)
+		}
 		cm.connectionCounter.decrement()
 		return conn.close()
 	}
 	return nil
 }
 
+// retiringConnections tracks the connections of previous generations that are being drained
+// during recycle()
+type retiringConnections struct {
+	remaining uint16
+	recycled  uint16
+	errs      errorList
+	done      chan struct{}
+	sync.Mutex
+}
+
+func (cm *connectionManager) isRetired(conn *connection) bool {
+	cm.RLock()
+	defer cm.RUnlock()
+	return conn.generation != cm.generation
+}
+
+// retire closes a connection from a previous generation, and records it against the
+// current recycle() if one is in progress
+func (cm *connectionManager) retire(conn *connection) error {
+	cm.connectionCounter.decrement()
+	err := conn.close()
+	if err != nil {
+		logErr("[connectionManager] error when closing retired connection", err)
+	}
+	cm.accountRetired(true, err)
+	return err
+}
+
+// accountRetired records a connection of a previous generation against the current recycle(),
+// if one is in progress. closed is false for a connection that was never established
+func (cm *connectionManager) accountRetired(closed bool, err error) {
+	cm.RLock()
+	r := cm.retiring
+	cm.RUnlock()
+	if r == nil {
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	if r.remaining == 0 {
+		// NB: drain has already timed out or completed
+		return
+	}
+	if closed {
+		r.recycled++
+	}
+	if err != nil {
+		r.errs = append(r.errs, err)
+	}
+	r.remaining--
+	if r.remaining == 0 {
+		close(r.done)
+	}
+}
+
+// recycle closes every connection in the pool, waiting up to drainTimeout for in-flight
+// connections to be returned, then re-establishes minConnections. Connections returned
+// after drainTimeout are closed when they are returned. It returns the number of
+// connections that were closed
+func (cm *connectionManager) recycle(drainTimeout time.Duration) (uint16, error) {
+	if err := cm.stateCheck(cmRunning); err != nil {
+		return 0, err
+	}
+
+	cm.recycleMtx.Lock()
+	defer cm.recycleMtx.Unlock()
+
+	logDebug("[connectionManager]", "(%v) recycling connections", cm)
+
+	// NB: connections are counted and the generation changed together so that every
+	// connection from a previous generation is accounted for
+	cm.Lock()
+	r := &retiringConnections{
+		remaining: cm.connectionCounter.count(),
+		done:      make(chan struct{}),
+	}
+	if r.remaining == 0 {
+		close(r.done)
+	}
+	cm.retiring = r
+	cm.generation++
+	cm.Unlock()
+
+	var f = func(v interface{}) (bool, bool) {
+		if v == nil {
+			return true, false
+		}
+		cm.retire(v.(*connection))
+		return false, false
+	}
+	if err := cm.q.iterate(f); err != nil {
+		logErr("[connectionManager]", err)
+	}
+
+	select {
+	case <-r.done:
+	case <-time.After(drainTimeout):
+	}
+
+	cm.Lock()
+	cm.retiring = nil
+	cm.Unlock()
+
+	r.Lock()
+	recycled := r.recycled
+	errs := r.errs
+	outstanding := r.remaining
+	if outstanding > 0 {
+		errs = append(errs, newClientError(fmt.Sprintf(ErrConnMgrRecycleTimeout, outstanding), nil))
+		r.remaining = 0
+	}
+	r.Unlock()
+
+	// NB: connections still draining do not count towards minConnections
+	for cm.count() < cm.minConnections+outstanding {
+		conn, err := cm.create()
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if conn == nil {
+			// shutting down
+			break
+		}
+		if err = cm.put(conn); err != nil {
+			errs = append(errs, err)
+			break
+		}
+	}
+
+	logDebug("[connectionManager]", "(%v) recycled %d connections", cm, recycled)
+
+	if len(errs) > 0 {
+		return recycled, newClientError(fmt.Sprintf(ErrConnMgrRecycle, len(errs)), errs)
+	}
+	return recycled, nil
+}
+
 func (cm *connectionManager) manageConnections() {
 	logDebug("[connectionManager]", "connection expiration routine is starting")
 	for {
@@ -342,41 +704,7 @@ func (cm *connectionManager) manageConnections() {
 			}
 
 			logDebug("[connectionManager]", "(%v) expiring connections at %v", cm, t)
-
-			count := uint16(0)
-			now := time.Now()
-
-			var f = func(v interface{}) (bool, bool) {
-				if v == nil {
-					// connection pool is empty
-					return true, false
-				}
-				if !cm.isStateLessThan(cmShuttingDown) {
-					return true, true
-				}
-				conn := v.(*connection)
-				cm.Lock()
-				defer cm.Unlock()
-				if cm.connectionCounter.isGreaterThan(cm.minConnections) {
-					// expire connection if not available or if it has passed idle timeout
-					if !conn.available() || (now.Sub(conn.lastUsed) >= cm.idleTimeout) {
-						cm.connectionCounter.decrement()
-						if err := conn.close(); err != nil {
-							logErr("[connectionManager]", err)
-						}
-						count++
-						return false, false // don't break, don't re-enqueue
-					} else {
-						return false, true // don't break, re-enqueue
-					}
-				}
-				return true, true // break, re-enqueue
-			}
-
-			if err := cm.q.iterate(f); err != nil {
-				logErr("[connectionManager]", err)
-			}
-
+			count := cm.expireIdleConnections(time.Now())
 			logDebug("[connectionManager]", "(%v) expired %d connections.", cm, count)
 
 			if !cm.isStateLessThan(cmShuttingDown) {
@@ -385,3 +713,45 @@ func (cm *connectionManager) manageConnections() {
 		}
 	}
 }
+
+// expireIdleConnections closes pooled connections that are unavailable or have been idle for
+// idleTimeout as of now, down to minConnections. At most maxIdleExpirations are closed, if set, so
+// that a pool that became idle all at once drains over several intervals rather than in a burst
+// that is followed by as many reconnections. It returns the number of connections closed
+func (cm *connectionManager) expireIdleConnections(now time.Time) uint16 {
+	count := uint16(0)
+	var f = func(v interface{}) (bool, bool) {
+		if v == nil {
+			// connection pool is empty
+			return true, false
+		}
+		if !cm.isStateLessThan(cmShuttingDown) {
+			return true, true
+		}
+		if cm.maxIdleExpirations > 0 && count >= cm.maxIdleExpirations {
+			return true, true // break, re-enqueue
+		}
+		conn := v.(*connection)
+		cm.Lock()
+		defer cm.Unlock()
+		if cm.connectionCounter.isGreaterThan(cm.minConnections) {
+			// expire connection if not available or if it has passed idle timeout
+			if !conn.available() || (now.Sub(conn.lastUsed) >= cm.idleTimeout) {
+				cm.connectionCounter.decrement()
+				if err := conn.close(); err != nil {
+					logErr("[connectionManager]", err)
+				}
+				count++
+				return false, false // don't break, don't re-enqueue
+			} else {
+				return false, true // don't break, re-enqueue
+			}
+		}
+		return true, true // break, re-enqueue
+	}
+
+	if err := cm.q.iterate(f); err != nil {
+		logErr("[connectionManager]", err)
+	}
+	return count
+}
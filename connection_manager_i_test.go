@@ -65,3 +65,77 @@ func TestConnectionManagerDoesNotExpirePastMinConnections(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestConnectionManagerRecycle(t *testing.T) {
+	o := &testListenerOpts{
+		test: t,
+		host: "127.0.0.1",
+		port: 13341,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	addr, err := net.ResolveTCPAddr("tcp", "127.0.0.1:13341")
+
+	cm, err := newConnectionManager(&connectionManagerOptions{
+		addr:           addr,
+		minConnections: 2,
+		maxConnections: 4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cm.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cm.stop()
+
+	inFlight, err := cm.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cm.put(inFlight)
+	}()
+
+	recycled, err := cm.recycle(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := recycled, uint16(2); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := cm.count(), uint16(2); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := cm.q.count(), uint16(2); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if inFlight.conn != nil {
+		t.Error("expected in-flight connection to be closed")
+	}
+
+	// in-flight connections that are not returned in time are reported
+	inFlight, err = cm.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recycled, err = cm.recycle(50 * time.Millisecond); err == nil {
+		t.Error("expected non-nil error")
+	}
+	if got, want := recycled, uint16(1); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := cm.count(), uint16(3); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	cm.put(inFlight)
+	if inFlight.conn != nil {
+		t.Error("expected in-flight connection to be closed when returned")
+	}
+	if got, want := cm.count(), uint16(2); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -15,7 +15,11 @@
 package riak
 
 import (
+	"errors"
+	"net"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestCreateConnectionManager(t *testing.T) {
@@ -24,3 +28,138 @@ func TestCreateConnectionManager(t *testing.T) {
 		t.Error("expected non-nil error when creating without options")
 	}
 }
+
+type closeErrorConn struct {
+	net.Conn
+}
+
+func (c *closeErrorConn) Close() error {
+	return errors.New("close error")
+}
+
+func TestConnectionManagerStopReportsCloseErrors(t *testing.T) {
+	addr, _ := net.ResolveTCPAddr("tcp4", "127.0.0.1:8087")
+	cm, err := newConnectionManager(&connectionManagerOptions{
+		addr:           addr,
+		maxConnections: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm.setState(cmRunning)
+	cm.expireTicker = time.NewTicker(time.Hour)
+	for i := 0; i < 2; i++ {
+		if err := cm.q.enqueue(&connection{conn: &closeErrorConn{}}); err != nil {
+			t.Fatal(err)
+		}
+		cm.connectionCounter.increment()
+	}
+
+	err = cm.stop()
+	if err == nil {
+		t.Fatal("expected non-nil error")
+	}
+	cerr, ok := err.(ClientError)
+	if !ok {
+		t.Fatalf("expected ClientError, got %T", err)
+	}
+	if errs, ok := cerr.InnerError.(errorList); !ok || len(errs) != 2 {
+		t.Errorf("expected two close errors, got %v", cerr.InnerError)
+	}
+	if !cm.isCurrentState(cmShutdown) {
+		t.Errorf("expected state %v, got %v", cmShutdown, cm.getState())
+	}
+}
+
+func TestConnectionManagerCreateDoesNotBlockWhileDialing(t *testing.T) {
+	addr, _ := net.ResolveTCPAddr("tcp4", "127.0.0.1:8087")
+	dialing := make(chan struct{})
+	unblock := make(chan struct{})
+	var dials int32
+	cm, err := newConnectionManager(&connectionManagerOptions{
+		addr:           addr,
+		maxConnections: 2,
+		dialer: func(network, address string) (net.Conn, error) {
+			if atomic.AddInt32(&dials, 1) == 1 {
+				close(dialing)
+				<-unblock
+				return nil, errors.New("dial failed")
+			}
+			client, _ := net.Pipe()
+			return client, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slowErr := make(chan error, 1)
+	go func() {
+		_, err := cm.create()
+		slowErr <- err
+	}()
+	<-dialing
+
+	created := make(chan *connection, 1)
+	go func() {
+		conn, err := cm.create()
+		if err != nil {
+			t.Error(err)
+		}
+		created <- conn
+	}()
+	var conn *connection
+	select {
+	case conn = <-created:
+	case <-time.After(time.Second):
+		t.Fatal("create was blocked by a connection that was still dialing")
+	}
+	defer conn.close()
+
+	// NB: the dialing connection holds a slot, so the pool is full
+	if _, err = cm.create(); err != ErrConnMgrAllConnectionsInUse {
+		t.Errorf("got %v, want %v", err, ErrConnMgrAllConnectionsInUse)
+	}
+
+	close(unblock)
+	if err = <-slowErr; err == nil {
+		t.Error("expected the slow dial to fail")
+	}
+	if got, want := cm.count(), uint16(1); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestConnectionManagerExpiresIdleConnectionsGradually(t *testing.T) {
+	addr, _ := net.ResolveTCPAddr("tcp4", "127.0.0.1:8087")
+	cm, err := newConnectionManager(&connectionManagerOptions{
+		addr:               addr,
+		minConnections:     1,
+		maxConnections:     10,
+		idleTimeout:        time.Second,
+		maxIdleExpirations: 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm.setState(cmRunning)
+	for i := 0; i < 10; i++ {
+		client, _ := net.Pipe()
+		if err := cm.q.enqueue(&connection{conn: client}); err != nil {
+			t.Fatal(err)
+		}
+		cm.connectionCounter.increment()
+	}
+
+	// NB: every connection is idle, but only maxIdleExpirations are closed each interval
+	now := time.Now()
+	for _, want := range []uint16{7, 4, 1, 1} {
+		cm.expireIdleConnections(now)
+		if got := cm.count(); got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got := cm.q.count(); got != want {
+			t.Errorf("got %v pooled, want %v", got, want)
+		}
+	}
+}
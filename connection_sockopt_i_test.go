@@ -0,0 +1,93 @@
+// Copyright 2015-present Basho Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build integration,linux
+
+package riak
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// NB: reads the option from a duplicate of the socket, as SyscallConn needs Go 1.9
+func getSockoptInt(t *testing.T, c net.Conn, level, opt int) int {
+	f, err := c.(*net.TCPConn).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	val, err := syscall.GetsockoptInt(int(f.Fd()), level, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return val
+}
+
+func TestConnectionAppliesTCPOptions(t *testing.T) {
+	o := &testListenerOpts{
+		test: t,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	noDelay := false
+	tests := []struct {
+		name      string
+		keepAlive time.Duration
+		noDelay   *bool
+		wantKA    int
+		wantIdle  int
+		wantNoDly int
+	}{
+		{"defaults", 0, nil, 1, int(defaultKeepAlive / time.Second), 1},
+		{"configured", 45 * time.Second, &noDelay, 1, 45, 0},
+		{"keep-alive disabled", -1, nil, 0, 0, 1},
+	}
+	for _, tt := range tests {
+		var dialed net.Conn
+		conn, err := newConnection(&connectionOptions{
+			remoteAddress: tl.addr.(*net.TCPAddr),
+			keepAlive:     tt.keepAlive,
+			noDelay:       tt.noDelay,
+			dialHook: func(c net.Conn) {
+				dialed = c
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = conn.connect(); err != nil {
+			t.Fatal(err)
+		}
+		if dialed == nil {
+			t.Fatalf("%s: expected dial hook to be called", tt.name)
+		}
+		if got, want := getSockoptInt(t, dialed, syscall.SOL_SOCKET, syscall.SO_KEEPALIVE), tt.wantKA; got != want {
+			t.Errorf("%s: SO_KEEPALIVE got %v, want %v", tt.name, got, want)
+		}
+		if tt.wantKA == 1 {
+			if got, want := getSockoptInt(t, dialed, syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE), tt.wantIdle; got != want {
+				t.Errorf("%s: TCP_KEEPIDLE got %v, want %v", tt.name, got, want)
+			}
+		}
+		if got, want := getSockoptInt(t, dialed, syscall.IPPROTO_TCP, syscall.TCP_NODELAY) != 0, tt.wantNoDly != 0; got != want {
+			t.Errorf("%s: TCP_NODELAY got %v, want %v", tt.name, got, want)
+		}
+		conn.close()
+	}
+}
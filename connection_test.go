@@ -15,7 +15,10 @@
 package riak
 
 import (
+	"io"
 	"net"
+	"os"
+	"syscall"
 	"testing"
 )
 
@@ -101,3 +104,48 @@ func TestEnsureDefaultConnectionValues(t *testing.T) {
 		t.Error(err.Error())
 	}
 }
+
+func TestResetBufferOnlyReleasesLargeBuffers(t *testing.T) {
+	addr, err := net.ResolveTCPAddr("tcp4", "127.0.0.1:8087")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := newConnection(&connectionOptions{remoteAddress: addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 64*1024)
+	conn.dataBuf = buf[:100]
+	conn.resetBuffer()
+	if &conn.dataBuf[:1][0] != &buf[0] {
+		t.Error("expected a buffer below the high-water mark to be kept")
+	}
+
+	conn.dataBuf = make([]byte, maxIdleBuffer+1)
+	conn.resetBuffer()
+	if got, want := cap(conn.dataBuf), defaultInitBuffer; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestIsClosedConnectionError(t *testing.T) {
+	tests := []struct {
+		err    error
+		closed bool
+	}{
+		{io.EOF, true},
+		{&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, true},
+		{&net.OpError{Op: "write", Err: os.NewSyscallError("write", syscall.EPIPE)}, true},
+		{syscall.ECONNRESET, true},
+		{io.ErrUnexpectedEOF, false},
+		{&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ETIMEDOUT)}, false},
+		{ErrCannotRead, false},
+		{nil, false},
+	}
+	for i, tt := range tests {
+		if got, want := isClosedConnectionError(tt.err), tt.closed; got != want {
+			t.Errorf("%d: %v: got %v, want %v", i, tt.err, got, want)
+		}
+	}
+}
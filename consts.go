@@ -31,11 +31,20 @@ const (
 	defaultIdleTimeout            = tenSeconds
 	defaultConnectTimeout         = threeSeconds
 	defaultRequestTimeout         = fiveSeconds
+	defaultKeepAlive              = time.Second * 30
 	defaultHealthCheckInterval    = 125 * time.Millisecond
+	defaultMaxHealthCheckInterval = time.Second * 30
+	healthCheckEscalationPeriod   = time.Minute
 	defaultExecutionAttempts      = byte(3)
 	defaultQueueExecutionInterval = 125 * time.Millisecond
 	defaultInitBuffer             = 2048
+	maxIdleBuffer                 = 1024 * 1024
+	defaultMaxMessageSize         = uint32(256 * 1024 * 1024)
 	defaultTempNetErrorRetries    = uint16(0)
+
+	defaultMaxDataTypeUpdatesInFlight = uint16(16)
+	defaultMaxIndexFetchesInFlight    = uint16(16)
+	defaultIndexPollInterval          = time.Second
 )
 
 var defaultRemoteAddress = fmt.Sprintf("127.0.0.1:%d", defaultRemotePort)
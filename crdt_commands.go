@@ -17,6 +17,7 @@ package riak
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"time"
 
 	rpbRiakDT "github.com/basho/riak-go-client/rpb/riak_dt"
@@ -49,16 +50,21 @@ func (cmd *UpdateCounterCommand) constructPbRequest() (proto.Message, error) {
 
 func (cmd *UpdateCounterCommand) onSuccess(msg proto.Message) error {
 	cmd.success = true
-	if msg != nil {
+	if msg == nil {
+		// NB: without return_body and a generated key, the response is empty
+		cmd.Response = &UpdateCounterResponse{}
+	} else {
 		// For legacy counters, the response may be different
 		if rpbDtUpdateResp, is_DtUpdateResp := msg.(*rpbRiakDT.DtUpdateResp); is_DtUpdateResp && !cmd.isLegacy {
 			cmd.Response = &UpdateCounterResponse{
 				GeneratedKey: string(rpbDtUpdateResp.GetKey()),
 				CounterValue: rpbDtUpdateResp.GetCounterValue(),
+				HasValue:     rpbDtUpdateResp.CounterValue != nil,
 			}
 		} else if rpbCounterUpdateResp, is_RpbCounterUpdateResp := msg.(*rpbRiakKV.RpbCounterUpdateResp); is_RpbCounterUpdateResp && cmd.isLegacy {
 			cmd.Response = &UpdateCounterResponse{
 				CounterValue: rpbCounterUpdateResp.GetValue(),
+				HasValue:     rpbCounterUpdateResp.Value != nil,
 			}
 		} else {
 			return fmt.Errorf("[UpdateCounterCommand] could not convert %v to DtUpdateResp / RpbCounterUpdateResp, isLegacy: %v", reflect.TypeOf(msg), cmd.isLegacy)
@@ -92,6 +98,9 @@ func (cmd *UpdateCounterCommand) getResponseProtobufMessage() proto.Message {
 type UpdateCounterResponse struct {
 	GeneratedKey string
 	CounterValue int64
+	// HasValue is true when Riak returned the counter value after the update, which it does
+	// when the command was built WithReturnBody(true). Otherwise CounterValue is 0
+	HasValue bool
 }
 
 // UpdateCounterCommandBuilder type is required for creating new instances of UpdateCounterCommand
@@ -119,7 +128,7 @@ func NewUpdateCounterCommandBuilder() *UpdateCounterCommandBuilder {
 	return &UpdateCounterCommandBuilder{}
 }
 
-// WithBucketType sets the bucket-type to be used by the command. If omitted, 'default' is used
+// WithBucketType sets the bucket-type to be used by the command. A bucket-type is required
 func (builder *UpdateCounterCommandBuilder) WithBucketType(bucketType string) *UpdateCounterCommandBuilder {
 	builder.bucketType = bucketType
 	return builder
@@ -325,7 +334,7 @@ func NewFetchCounterCommandBuilder() *FetchCounterCommandBuilder {
 	return &FetchCounterCommandBuilder{protobuf: &rpbRiakDT.DtFetchReq{}}
 }
 
-// WithBucketType sets the bucket-type to be used by the command. If omitted, 'default' is used
+// WithBucketType sets the bucket-type to be used by the command. A bucket-type is required
 func (builder *FetchCounterCommandBuilder) WithBucketType(bucketType string) *FetchCounterCommandBuilder {
 	builder.protobuf.Type = []byte(bucketType)
 	return builder
@@ -493,7 +502,7 @@ func NewUpdateSetCommandBuilder() *UpdateSetCommandBuilder {
 	}
 }
 
-// WithBucketType sets the bucket-type to be used by the command. If omitted, 'default' is used
+// WithBucketType sets the bucket-type to be used by the command. A bucket-type is required
 func (builder *UpdateSetCommandBuilder) WithBucketType(bucketType string) *UpdateSetCommandBuilder {
 	builder.protobuf.Type = []byte(bucketType)
 	return builder
@@ -585,6 +594,9 @@ func (builder *UpdateSetCommandBuilder) Build() (Command, error) {
 	if err := validateLocatable(builder.protobuf); err != nil {
 		return nil, err
 	}
+	if len(builder.protobuf.Op.SetOp.Removes) > 0 && builder.protobuf.GetContext() == nil {
+		return nil, newClientError(fmt.Sprintf(ErrContextRequiredForRemove, "removing from the set"), ErrContextRequired)
+	}
 	return &UpdateSetCommand{
 		timeoutImpl: timeoutImpl{
 			timeout: builder.timeout,
@@ -683,7 +695,7 @@ func NewUpdateGSetCommandBuilder() *UpdateGSetCommandBuilder {
 	}
 }
 
-// WithBucketType sets the bucket-type to be used by the command. If omitted, 'default' is used
+// WithBucketType sets the bucket-type to be used by the command. A bucket-type is required
 func (builder *UpdateGSetCommandBuilder) WithBucketType(bucketType string) *UpdateGSetCommandBuilder {
 	builder.protobuf.Type = []byte(bucketType)
 	return builder
@@ -860,7 +872,7 @@ func NewFetchSetCommandBuilder() *FetchSetCommandBuilder {
 	return &FetchSetCommandBuilder{protobuf: &rpbRiakDT.DtFetchReq{}}
 }
 
-// WithBucketType sets the bucket-type to be used by the command. If omitted, 'default' is used
+// WithBucketType sets the bucket-type to be used by the command. A bucket-type is required
 func (builder *FetchSetCommandBuilder) WithBucketType(bucketType string) *FetchSetCommandBuilder {
 	builder.protobuf.Type = []byte(bucketType)
 	return builder
@@ -1319,6 +1331,58 @@ func (mapOp *MapOperation) hasRemoves(includeRemoveFromSets bool) bool {
 	return rv
 }
 
+// firstRemoval describes the first removal within the map operation or its nested map operations,
+// or returns an empty string if there are none
+func (mapOp *MapOperation) firstRemoval() string {
+	removals := []struct {
+		desc  string
+		names []string
+	}{
+		{"removing counter", sortedKeys(mapOp.removeCounters)},
+		{"removing from set", sortedSetKeys(mapOp.removeFromSets)},
+		{"removing set", sortedKeys(mapOp.removeSets)},
+		{"removing register", sortedKeys(mapOp.removeRegisters)},
+		{"removing flag", sortedKeys(mapOp.removeFlags)},
+		{"removing map", sortedKeys(mapOp.removeMaps)},
+	}
+	for _, r := range removals {
+		if len(r.names) > 0 {
+			return fmt.Sprintf("%s '%s'", r.desc, r.names[0])
+		}
+	}
+	names := make([]string, 0, len(mapOp.maps))
+	for name := range mapOp.maps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if removal := mapOp.maps[name].firstRemoval(); removal != "" {
+			return fmt.Sprintf("%s in map '%s'", removal, name)
+		}
+	}
+	return ""
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedSetKeys(m map[string][][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k, removes := range m {
+		if len(removes) > 0 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func parsePbResponse(pbMapEntries []*rpbRiakDT.MapEntry) *Map {
 	m := &Map{}
 	for _, mapEntry := range pbMapEntries {
@@ -1365,6 +1429,65 @@ type Map struct {
 	Maps      map[string]*Map
 }
 
+// GetMap returns the nested Map at the path of map field names, e.g. m.GetMap("user", "address"),
+// and whether it exists. An empty path returns m itself
+func (m *Map) GetMap(path ...string) (*Map, bool) {
+	for _, name := range path {
+		if m == nil {
+			return nil, false
+		}
+		m = m.Maps[name]
+	}
+	return m, m != nil
+}
+
+// GetCounter returns the counter at the path of field names, the last of which names the counter
+// and the rest the maps containing it, and whether it exists
+func (m *Map) GetCounter(path ...string) (int64, bool) {
+	if parent, name, ok := m.parentOf(path); ok {
+		v, ok := parent.Counters[name]
+		return v, ok
+	}
+	return 0, false
+}
+
+// GetSet returns the set at the path of field names, as for GetCounter
+func (m *Map) GetSet(path ...string) ([][]byte, bool) {
+	if parent, name, ok := m.parentOf(path); ok {
+		v, ok := parent.Sets[name]
+		return v, ok
+	}
+	return nil, false
+}
+
+// GetRegister returns the register at the path of field names, as for GetCounter
+func (m *Map) GetRegister(path ...string) ([]byte, bool) {
+	if parent, name, ok := m.parentOf(path); ok {
+		v, ok := parent.Registers[name]
+		return v, ok
+	}
+	return nil, false
+}
+
+// GetFlag returns the flag at the path of field names, as for GetCounter
+func (m *Map) GetFlag(path ...string) (bool, bool) {
+	if parent, name, ok := m.parentOf(path); ok {
+		v, ok := parent.Flags[name]
+		return v, ok
+	}
+	return false, false
+}
+
+// parentOf returns the Map containing the last field of path, and that field's name
+func (m *Map) parentOf(path []string) (*Map, string, bool) {
+	if len(path) == 0 {
+		return nil, "", false
+	}
+	last := len(path) - 1
+	parent, ok := m.GetMap(path[:last]...)
+	return parent, path[last], ok
+}
+
 // UpdateMapResponse contains the response data for a UpdateMapCommand
 type UpdateMapResponse struct {
 	GeneratedKey string
@@ -1394,7 +1517,7 @@ func NewUpdateMapCommandBuilder() *UpdateMapCommandBuilder {
 	return &UpdateMapCommandBuilder{protobuf: &rpbRiakDT.DtUpdateReq{}}
 }
 
-// WithBucketType sets the bucket-type to be used by the command. If omitted, 'default' is used
+// WithBucketType sets the bucket-type to be used by the command. A bucket-type is required
 func (builder *UpdateMapCommandBuilder) WithBucketType(bucketType string) *UpdateMapCommandBuilder {
 	builder.protobuf.Type = []byte(bucketType)
 	return builder
@@ -1479,8 +1602,10 @@ func (builder *UpdateMapCommandBuilder) Build() (Command, error) {
 	if builder.mapOperation == nil {
 		return nil, newClientError("UpdateMapCommandBuilder requires non-nil MapOperation. Use WithMapOperation()", nil)
 	}
-	if builder.mapOperation.hasRemoves(true) && builder.protobuf.GetContext() == nil {
-		return nil, newClientError("When doing any removes a context must be provided.", nil)
+	if builder.protobuf.GetContext() == nil {
+		if removal := builder.mapOperation.firstRemoval(); removal != "" {
+			return nil, newClientError(fmt.Sprintf(ErrContextRequiredForRemove, removal), ErrContextRequired)
+		}
 	}
 	return &UpdateMapCommand{
 		timeoutImpl: timeoutImpl{
@@ -1575,7 +1700,7 @@ func NewFetchMapCommandBuilder() *FetchMapCommandBuilder {
 	return &FetchMapCommandBuilder{protobuf: &rpbRiakDT.DtFetchReq{}}
 }
 
-// WithBucketType sets the bucket-type to be used by the command. If omitted, 'default' is used
+// WithBucketType sets the bucket-type to be used by the command. A bucket-type is required
 func (builder *FetchMapCommandBuilder) WithBucketType(bucketType string) *FetchMapCommandBuilder {
 	builder.protobuf.Type = []byte(bucketType)
 	return builder
@@ -1740,7 +1865,7 @@ func NewUpdateHllCommandBuilder() *UpdateHllCommandBuilder {
 	}
 }
 
-// WithBucketType sets the bucket-type to be used by the command. If omitted, 'default' is used
+// WithBucketType sets the bucket-type to be used by the command. A bucket-type is required
 func (builder *UpdateHllCommandBuilder) WithBucketType(bucketType string) *UpdateHllCommandBuilder {
 	builder.protobuf.Type = []byte(bucketType)
 	return builder
@@ -1902,7 +2027,7 @@ func NewFetchHllCommandBuilder() *FetchHllCommandBuilder {
 	return &FetchHllCommandBuilder{protobuf: &rpbRiakDT.DtFetchReq{}}
 }
 
-// WithBucketType sets the bucket-type to be used by the command. If omitted, 'default' is used
+// WithBucketType sets the bucket-type to be used by the command. A bucket-type is required
 func (builder *FetchHllCommandBuilder) WithBucketType(bucketType string) *FetchHllCommandBuilder {
 	builder.protobuf.Type = []byte(bucketType)
 	return builder
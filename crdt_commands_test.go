@@ -23,6 +23,7 @@ import (
 
 	rpbRiakDT "github.com/basho/riak-go-client/rpb/riak_dt"
 	rpbRiakKV "github.com/basho/riak-go-client/rpb/riak_kv"
+	proto "github.com/golang/protobuf/proto"
 )
 
 // UpdateCounter
@@ -244,8 +245,20 @@ func TestValidationOfUpdateCounterViaBuilder(t *testing.T) {
 		t.Errorf("expected %v, actual %v", expected, actual)
 	}
 
+	// validate that BucketType is required
+	builder = NewUpdateCounterCommandBuilder()
+	builder.WithBucket("bucket_name")
+	_, err = builder.Build()
+	if err == nil {
+		t.Fatal("expected non-nil err")
+	}
+	if expected, actual := ErrBucketTypeRequired.Error(), err.Error(); expected != actual {
+		t.Errorf("expected %v, actual %v", expected, actual)
+	}
+
 	// validate that Key is NOT required
 	builder = NewUpdateCounterCommandBuilder()
+	builder.WithBucketType("bucket_type")
 	builder.WithBucket("bucket_name")
 	_, err = builder.Build()
 	if err != nil {
@@ -384,6 +397,141 @@ func TestFetchCounterParsesDtFetchRespWithoutValueCorrectly(t *testing.T) {
 	}
 }
 
+func TestCounterWireEncoding(t *testing.T) {
+	cmd, err := NewUpdateCounterCommandBuilder().
+		WithBucketType("counters").
+		WithBucket("myBucket").
+		WithKey("counter_1").
+		WithIncrement(5).
+		WithReturnBody(true).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := getRiakMessage(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// NB: skip the length and message code
+	if got, want := data[4], rpbCode_DtUpdateReq; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	req := &rpbRiakDT.DtUpdateReq{}
+	if err = proto.Unmarshal(data[5:], req); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := req.GetOp().GetCounterOp().GetIncrement(), int64(5); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := req.GetReturnBody(), true; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// DtUpdateResp, counter_value 5
+	msg, err := decodeRiakMessage(cmd, []byte{rpbCode_DtUpdateResp, 0x18, 0x0a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cmd.onSuccess(msg); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cmd.(*UpdateCounterCommand).Response.CounterValue, int64(5); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		data     []byte
+		notFound bool
+		value    int64
+	}{
+		// DtFetchResp, type COUNTER, value counter_value 5
+		{[]byte{rpbCode_DtFetchResp, 0x10, 0x01, 0x1a, 0x02, 0x08, 0x0a}, false, 5},
+		// DtFetchResp, type COUNTER, no value
+		{[]byte{rpbCode_DtFetchResp, 0x10, 0x01}, true, 0},
+	}
+	for _, tt := range tests {
+		cmd, err := NewFetchCounterCommandBuilder().
+			WithBucketType("counters").
+			WithBucket("myBucket").
+			WithKey("counter_1").
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err := decodeRiakMessage(cmd, tt.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = cmd.onSuccess(msg); err != nil {
+			t.Fatal(err)
+		}
+		rsp := cmd.(*FetchCounterCommand).Response
+		if got, want := rsp.IsNotFound, tt.notFound; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := rsp.CounterValue, tt.value; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestUpdateCounterReturnsValueWithReturnBody(t *testing.T) {
+	tests := []struct {
+		returnBody bool
+		resp       *rpbRiakDT.DtUpdateResp
+		hasValue   bool
+		value      int64
+	}{
+		{true, &rpbRiakDT.DtUpdateResp{CounterValue: proto.Int64(52)}, true, 52},
+		{false, &rpbRiakDT.DtUpdateResp{}, false, 0},
+	}
+	for _, tt := range tests {
+		cmd, err := NewUpdateCounterCommandBuilder().
+			WithBucketType("counters").
+			WithBucket("myBucket").
+			WithKey("counter_1").
+			WithIncrement(10).
+			WithReturnBody(tt.returnBody).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := getRiakMessage(cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := &rpbRiakDT.DtUpdateReq{}
+		if err = proto.Unmarshal(data[5:], req); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := req.GetOp().GetCounterOp().GetIncrement(), int64(10); got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := req.GetReturnBody(), tt.returnBody; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+
+		encoded, err := proto.Marshal(tt.resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err := decodeRiakMessage(cmd, append([]byte{rpbCode_DtUpdateResp}, encoded...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = cmd.onSuccess(msg); err != nil {
+			t.Fatal(err)
+		}
+		rsp := cmd.(*UpdateCounterCommand).Response
+		if got, want := rsp.HasValue, tt.hasValue; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := rsp.CounterValue, tt.value; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
+
 func TestValidationOfFetchCounterViaBuilder(t *testing.T) {
 	// validate that Bucket is required
 	builder := NewFetchCounterCommandBuilder()
@@ -549,13 +697,39 @@ func TestValidationOfUpdateSetViaBuilder(t *testing.T) {
 		t.Errorf("expected %v, actual %v", expected, actual)
 	}
 
+	// validate that BucketType is required
+	builder = NewUpdateSetCommandBuilder()
+	builder.WithBucket("bucket_name")
+	_, err = builder.Build()
+	if err == nil {
+		t.Fatal("expected non-nil err")
+	}
+	if expected, actual := ErrBucketTypeRequired.Error(), err.Error(); expected != actual {
+		t.Errorf("expected %v, actual %v", expected, actual)
+	}
+
 	// validate that Key is NOT required
 	builder = NewUpdateSetCommandBuilder()
+	builder.WithBucketType("bucket_type")
 	builder.WithBucket("bucket_name")
 	_, err = builder.Build()
 	if err != nil {
 		t.Fatal("expected nil err")
 	}
+
+	// validate that context is required when removals are present
+	builder = NewUpdateSetCommandBuilder().
+		WithBucketType("bucket_type").
+		WithBucket("bucket_name").
+		WithRemovals([]byte("r1"))
+	_, err = builder.Build()
+	if cerr, ok := err.(ClientError); !ok || cerr.InnerError != ErrContextRequired {
+		t.Errorf("expected ErrContextRequired, got %v", err)
+	}
+	builder.WithContext(crdtContextBytes)
+	if _, err = builder.Build(); err != nil {
+		t.Fatal(err)
+	}
 }
 
 // UpdateGSet
@@ -694,8 +868,20 @@ func TestValidationOfUpdateGSetViaBuilder(t *testing.T) {
 		t.Errorf("expected %v, actual %v", expected, actual)
 	}
 
+	// validate that BucketType is required
+	builder = NewUpdateGSetCommandBuilder()
+	builder.WithBucket("bucket_name")
+	_, err = builder.Build()
+	if err == nil {
+		t.Fatal("expected non-nil err")
+	}
+	if expected, actual := ErrBucketTypeRequired.Error(), err.Error(); expected != actual {
+		t.Errorf("expected %v, actual %v", expected, actual)
+	}
+
 	// validate that Key is NOT required
 	builder = NewUpdateGSetCommandBuilder()
+	builder.WithBucketType("bucket_type")
 	builder.WithBucket("bucket_name")
 	_, err = builder.Build()
 	if err != nil {
@@ -1280,8 +1466,20 @@ func TestValidationOfUpdateMapViaBuilder(t *testing.T) {
 		t.Errorf("expected %v, actual %v", expected, actual)
 	}
 
+	// validate that BucketType is required
+	builder = NewUpdateMapCommandBuilder()
+	builder.WithBucket("bucket_name")
+	_, err = builder.Build()
+	if err == nil {
+		t.Fatal("expected non-nil err")
+	}
+	if expected, actual := ErrBucketTypeRequired.Error(), err.Error(); expected != actual {
+		t.Errorf("expected %v, actual %v", expected, actual)
+	}
+
 	// validate that Key is NOT required
 	builder = NewUpdateMapCommandBuilder()
+	builder.WithBucketType("bucket_type")
 	builder.WithBucket("bucket_name")
 	builder.WithMapOperation(&MapOperation{})
 	_, err = builder.Build()
@@ -1293,11 +1491,59 @@ func TestValidationOfUpdateMapViaBuilder(t *testing.T) {
 	op := &MapOperation{}
 	op.RemoveSet("set_1")
 	builder = NewUpdateMapCommandBuilder()
+	builder.WithBucketType("bucket_type")
 	builder.WithBucket("bucket_name")
 	builder.WithMapOperation(op)
 	_, err = builder.Build()
-	if err == nil {
-		t.Fatal("expected non-nil err")
+	if cerr, ok := err.(ClientError); !ok || cerr.InnerError != ErrContextRequired {
+		t.Errorf("expected ErrContextRequired, got %v", err)
+	}
+	builder.WithContext(crdtContextBytes)
+	if _, err = builder.Build(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateMapContextRequiredNamesRemoval(t *testing.T) {
+	tests := []struct {
+		op   *MapOperation
+		want string
+	}{
+		{(&MapOperation{}).RemoveCounter("counter_1"), "removing counter 'counter_1'"},
+		{(&MapOperation{}).RemoveFromSet("set_1", []byte("v")), "removing from set 'set_1'"},
+		{(&MapOperation{}).RemoveRegister("register_b").RemoveRegister("register_a"), "removing register 'register_a'"},
+		{(&MapOperation{}).RemoveFlag("flag_1"), "removing flag 'flag_1'"},
+		{(&MapOperation{}).RemoveMap("map_1"), "removing map 'map_1'"},
+		{func() *MapOperation {
+			op := &MapOperation{}
+			op.Map("outer").Map("inner").RemoveFromSet("set_1", []byte("v"))
+			return op
+		}(), "removing from set 'set_1' in map 'inner' in map 'outer'"},
+	}
+	for _, tt := range tests {
+		_, err := NewUpdateMapCommandBuilder().
+			WithBucketType("bucket_type").
+			WithBucket("bucket_name").
+			WithMapOperation(tt.op).
+			Build()
+		cerr, ok := err.(ClientError)
+		if !ok || cerr.InnerError != ErrContextRequired {
+			t.Errorf("%s: expected ErrContextRequired, got %v", tt.want, err)
+			continue
+		}
+		if got, want := cerr.Errmsg, fmt.Sprintf(ErrContextRequiredForRemove, tt.want); got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+
+	op := &MapOperation{}
+	op.IncrementCounter("counter_1", 1).Map("inner").SetRegister("register_1", []byte("v"))
+	if _, err := NewUpdateMapCommandBuilder().
+		WithBucketType("bucket_type").
+		WithBucket("bucket_name").
+		WithMapOperation(op).
+		Build(); err != nil {
+		t.Errorf("expected no context to be required without removals, got %v", err)
 	}
 }
 
@@ -1401,6 +1647,47 @@ func TestFetchMapParsesDtFetchRespCorrectly(t *testing.T) {
 	}
 }
 
+func TestMapFieldPathAccessors(t *testing.T) {
+	m := parsePbResponse(createMapValue())
+
+	if v, ok := m.GetCounter("counter_1"); !ok || v != 50 {
+		t.Errorf("expected 50, got %v (ok: %v)", v, ok)
+	}
+	if v, ok := m.GetRegister("map_1", "register_1"); !ok || string(v) != "1234" {
+		t.Errorf("expected 1234, got %v (ok: %v)", string(v), ok)
+	}
+	if v, ok := m.GetSet("map_1", "set_1"); !ok || len(v) != 2 {
+		t.Errorf("expected two set values, got %v (ok: %v)", v, ok)
+	}
+	if v, ok := m.GetFlag("map_1", "flag_1"); !ok || !v {
+		t.Errorf("expected true, got %v (ok: %v)", v, ok)
+	}
+	if nested, ok := m.GetMap("map_1"); !ok || nested != m.Maps["map_1"] {
+		t.Errorf("expected map_1, got %v (ok: %v)", nested, ok)
+	}
+	if nested, ok := m.GetMap(); !ok || nested != m {
+		t.Errorf("expected m, got %v (ok: %v)", nested, ok)
+	}
+
+	if _, ok := m.GetCounter("missing"); ok {
+		t.Error("expected missing counter to not be found")
+	}
+	if _, ok := m.GetRegister("missing", "register_1"); ok {
+		t.Error("expected register in missing map to not be found")
+	}
+	if _, ok := m.GetRegister("map_1", "register_1", "deeper"); ok {
+		t.Error("expected register beneath a register to not be found")
+	}
+	if _, ok := m.GetCounter(); ok {
+		t.Error("expected empty path to not be found")
+	}
+
+	var notFound *Map
+	if _, ok := notFound.GetRegister("map_1", "register_1"); ok {
+		t.Error("expected register in nil map to not be found")
+	}
+}
+
 func TestFetchMapParsesDtFetchRespWithoutValueCorrectly(t *testing.T) {
 	builder := NewFetchMapCommandBuilder().
 		WithBucketType("maps").
@@ -1576,8 +1863,20 @@ func TestValidationOfUpdateHllViaBuilder(t *testing.T) {
 		t.Errorf("expected %v, actual %v", expected, actual)
 	}
 
+	// validate that BucketType is required
+	builder = NewUpdateHllCommandBuilder()
+	builder.WithBucket("bucket_name")
+	_, err = builder.Build()
+	if err == nil {
+		t.Fatal("expected non-nil err")
+	}
+	if expected, actual := ErrBucketTypeRequired.Error(), err.Error(); expected != actual {
+		t.Errorf("expected %v, actual %v", expected, actual)
+	}
+
 	// validate that Key is NOT required
 	builder = NewUpdateHllCommandBuilder()
+	builder.WithBucketType("bucket_type")
 	builder.WithBucket("bucket_name")
 	_, err = builder.Build()
 	if err != nil {
@@ -1717,6 +2016,81 @@ func TestFetchHllParsesDtFetchRespWithoutValueCorrectly(t *testing.T) {
 	}
 }
 
+func TestHllWireEncoding(t *testing.T) {
+	cmd, err := NewUpdateHllCommandBuilder().
+		WithBucketType("hlls").
+		WithBucket("bucket").
+		WithKey("hll_1").
+		WithAdditions([]byte("a"), []byte("b"), []byte("c")).
+		WithReturnBody(true).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := getRiakMessage(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// NB: skip the length and message code
+	if got, want := data[4], rpbCode_DtUpdateReq; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	req := &rpbRiakDT.DtUpdateReq{}
+	if err = proto.Unmarshal(data[5:], req); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprintf("%s", req.GetOp().GetHllOp().GetAdds()), "[a b c]"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// DtUpdateResp, hll_value 3
+	msg, err := decodeRiakMessage(cmd, []byte{rpbCode_DtUpdateResp, 0x30, 0x03})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cmd.onSuccess(msg); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cmd.(*UpdateHllCommand).Response.Cardinality, uint64(3); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		data        []byte
+		notFound    bool
+		cardinality uint64
+	}{
+		// DtFetchResp, type HLL, value hll_value 3
+		{[]byte{rpbCode_DtFetchResp, 0x10, 0x04, 0x1a, 0x02, 0x20, 0x03}, false, 3},
+		// DtFetchResp, type HLL, no value
+		{[]byte{rpbCode_DtFetchResp, 0x10, 0x04}, true, 0},
+	}
+	for _, tt := range tests {
+		cmd, err := NewFetchHllCommandBuilder().
+			WithBucketType("hlls").
+			WithBucket("bucket").
+			WithKey("hll_1").
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err := decodeRiakMessage(cmd, tt.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = cmd.onSuccess(msg); err != nil {
+			t.Fatal(err)
+		}
+		rsp := cmd.(*FetchHllCommand).Response
+		if got, want := rsp.IsNotFound, tt.notFound; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := rsp.Cardinality, tt.cardinality; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
+
 func TestValidationOfFetchHllViaBuilder(t *testing.T) {
 	// validate that Bucket is required
 	builder := NewFetchHllCommandBuilder()
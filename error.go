@@ -16,11 +16,14 @@ package riak
 
 import (
 	"fmt"
+	"strings"
 
 	rpb_riak "github.com/basho/riak-go-client/rpb/riak"
 	proto "github.com/golang/protobuf/proto"
 )
 
+// RiakError is returned when Riak responds to a Command with an error, as opposed to a network or
+// client error. Riak error codes are not specific, so Errmsg describes the error
 type RiakError struct {
 	Errcode uint32
 	Errmsg  string
@@ -50,12 +53,46 @@ func (e RiakError) Error() (s string) {
 	return fmt.Sprintf("RiakError|%d|%s", e.Errcode, e.Errmsg)
 }
 
+// AsRiakError returns the RiakError in err or in the InnerError of a ClientError wrapping it, if
+// any, for example when a Cluster has wrapped it after exhausting its execution attempts
+func AsRiakError(err error) (RiakError, bool) {
+	for err != nil {
+		switch e := err.(type) {
+		case RiakError:
+			return e, true
+		case ClientError:
+			err = e.InnerError
+		default:
+			return RiakError{}, false
+		}
+	}
+	return RiakError{}, false
+}
+
+// IsPreconditionFailed returns true when err is the RiakError returned for a StoreValueCommand
+// whose WithIfNotModified or WithIfNoneMatch condition was not met. The caller may fetch the
+// object again and retry
+func IsPreconditionFailed(err error) bool {
+	riakErr, ok := AsRiakError(err)
+	if !ok {
+		return false
+	}
+	switch riakErr.Errmsg {
+	case "modified", "match_found", "notfound":
+		return true
+	default:
+		return false
+	}
+}
+
 // Client errors
 var (
 	ErrAddressRequired      = newClientError("RemoteAddress is required in options", nil)
 	ErrAuthMissingConfig    = newClientError("[Connection] authentication is missing TLS config", nil)
 	ErrAuthTLSUpgradeFailed = newClientError("[Connection] upgrading to TLS connection failed", nil)
 	ErrBucketRequired       = newClientError("Bucket is required", nil)
+	ErrBucketTypeRequired   = newClientError("Bucket type is required", nil)
+	ErrContextRequired      = newClientError("Context is required when removing data type elements", nil)
 	ErrKeyRequired          = newClientError("Key is required", nil)
 	ErrNilOptions           = newClientError("[Command] options must be non-nil", nil)
 	ErrOptionsRequired      = newClientError("Options are required", nil)
@@ -65,6 +102,21 @@ var (
 	ErrListingDisabled      = newClientError("Bucket and key list operations are expensive and should not be used in production.", nil)
 )
 
+// ErrContextRequiredForRemove is the message of the error returned when building a data type update
+// that removes elements without a context. Its InnerError is ErrContextRequired
+const ErrContextRequiredForRemove = "%s requires a context, fetch the data type first and use WithContext()"
+
+// errorList aggregates several errors into one
+type errorList []error
+
+func (e errorList) Error() string {
+	s := make([]string, len(e))
+	for i, err := range e {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "; ")
+}
+
 type ClientError struct {
 	Errmsg     string
 	InnerError error
@@ -77,6 +129,11 @@ func newClientError(errmsg string, innerError error) error {
 	}
 }
 
+// Unwrap returns the error that caused the ClientError, if any
+func (e ClientError) Unwrap() error {
+	return e.InnerError
+}
+
 func (e ClientError) Error() (s string) {
 	if e.InnerError == nil {
 		return fmt.Sprintf("ClientError|%s", e.Errmsg)
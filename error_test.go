@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	rpb_riak "github.com/basho/riak-go-client/rpb/riak"
+	proto "github.com/golang/protobuf/proto"
 )
 
 func TestBuildRiakErrorFromRpbErrorResp(t *testing.T) {
@@ -43,3 +44,69 @@ func TestBuildRiakErrorFromRpbErrorResp(t *testing.T) {
 		t.Error("error in type conversion")
 	}
 }
+
+func TestRiakErrorDecodedFromErrorResp(t *testing.T) {
+	errResp, err := buildRiakErrorResp(1, "{n_val_violation,3}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = maybeRiakError(errResp)
+	riakErr, ok := AsRiakError(err)
+	if !ok {
+		t.Fatalf("expected a RiakError, got %v", err)
+	}
+	if got, want := riakErr, (RiakError{Errcode: 1, Errmsg: "{n_val_violation,3}"}); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// NB: a Cluster wraps the last error once its execution attempts are exhausted
+	wrapped := newClientError(ErrClusterNoNodesAvailable, err)
+	if got, ok := AsRiakError(wrapped); !ok || got != riakErr {
+		t.Errorf("got %v (%v), want %v", got, ok, riakErr)
+	}
+	if _, ok := AsRiakError(newClientError("not a riak error", nil)); ok {
+		t.Error("expected no RiakError")
+	}
+	if _, ok := AsRiakError(nil); ok {
+		t.Error("expected no RiakError")
+	}
+}
+
+func TestIsPreconditionFailed(t *testing.T) {
+	tests := []struct {
+		errmsg string
+		want   bool
+	}{
+		{"modified", true},
+		{"match_found", true},
+		{"notfound", true},
+		{"{n_val_violation,3}", false},
+	}
+	for _, tt := range tests {
+		errResp, err := buildRiakErrorResp(0, tt.errmsg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = maybeRiakError(errResp)
+		if got := IsPreconditionFailed(err); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.errmsg, got, tt.want)
+		}
+		if got := IsPreconditionFailed(newClientError(ErrClusterNoNodesAvailable, err)); got != tt.want {
+			t.Errorf("%s wrapped: got %v, want %v", tt.errmsg, got, tt.want)
+		}
+	}
+	if IsPreconditionFailed(ErrKeyRequired) {
+		t.Error("expected a client error not to be a failed precondition")
+	}
+}
+
+func buildRiakErrorResp(errcode uint32, errmsg string) ([]byte, error) {
+	encoded, err := proto.Marshal(&rpb_riak.RpbErrorResp{
+		Errcode: &errcode,
+		Errmsg:  []byte(errmsg),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{rpbCode_RpbErrorResp}, encoded...), nil
+}
@@ -215,13 +215,18 @@ func readWriteResp(t *testing.T, c net.Conn, shouldClose bool) (success bool) {
 	return
 }
 
-// TODO this is copied from connection.go and should be shared
 func readClientMessage(c net.Conn) (msgCode byte, err error) {
+	msgCode, _, err = readClientMessageWithData(c)
+	return
+}
+
+// TODO this is copied from connection.go and should be shared
+func readClientMessageWithData(c net.Conn) (msgCode byte, data []byte, err error) {
 	var sizeBuf []byte = make([]byte, 4)
 	var count int = 0
 	if count, err = io.ReadFull(c, sizeBuf); err == nil && count == 4 {
 		messageLength := binary.BigEndian.Uint32(sizeBuf)
-		data := make([]byte, messageLength)
+		data = make([]byte, messageLength)
 		count, err = io.ReadFull(c, data)
 		if err != nil {
 			return
@@ -229,6 +234,7 @@ func readClientMessage(c net.Conn) (msgCode byte, err error) {
 			err = fmt.Errorf("[readClientMessage] message length: %d, only read: %d", messageLength, count)
 		}
 		msgCode = data[0]
+		data = data[1:]
 	} else {
 		if err != io.EOF {
 			err = errors.New(fmt.Sprintf("[readClientMessage] error reading command size into sizeBuf: count %d, err %s, errtype %v", count, err, reflect.TypeOf(err)))
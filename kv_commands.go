@@ -15,15 +15,28 @@
 package riak
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
 	rpbRiakKV "github.com/basho/riak-go-client/rpb/riak_kv"
 	proto "github.com/golang/protobuf/proto"
 )
 
+// Symbolic replica counts, which may be passed in place of a number to the R, PR, W, DW and PW
+// builder methods of KV commands, e.g. WithR(RWQuorum). Riak represents them as the largest
+// uint32 values
+const (
+	RWOne     uint32 = math.MaxUint32 - 1
+	RWQuorum  uint32 = math.MaxUint32 - 2
+	RWAll     uint32 = math.MaxUint32 - 3
+	RWDefault uint32 = math.MaxUint32 - 4
+)
+
 // FetchValue
 // RpbGetReq
 // RpbGetResp
@@ -33,6 +46,25 @@ type ConflictResolver interface {
 	Resolve([]*Object) []*Object
 }
 
+// LastModifiedWinsResolver is a ConflictResolver that resolves siblings to the single most
+// recently modified value
+type LastModifiedWinsResolver struct {
+}
+
+// Resolve returns the sibling with the latest LastModified time
+func (r *LastModifiedWinsResolver) Resolve(objs []*Object) []*Object {
+	if len(objs) == 0 {
+		return objs
+	}
+	latest := objs[0]
+	for _, obj := range objs[1:] {
+		if obj.LastModified.After(latest.LastModified) {
+			latest = obj
+		}
+	}
+	return []*Object{latest}
+}
+
 // FetchValueCommand is used to fetch / get a value from Riak KV
 type FetchValueCommand struct {
 	commandImpl
@@ -68,29 +100,26 @@ func (cmd *FetchValueCommand) onSuccess(msg proto.Message) error {
 				IsNotFound:  false,
 			}
 
+			bucketType := string(cmd.protobuf.Type)
+			bucket := string(cmd.protobuf.Bucket)
+			key := string(cmd.protobuf.Key)
+			// NB: Riak sends no content when the value matches WithIfModified, which
+			// must not be mistaken for a tombstone
+			if response.IsUnchanged {
+				cmd.Response = response
+				return nil
+			}
 			if pbContent := rpbGetResp.GetContent(); pbContent == nil || len(pbContent) == 0 {
-				object := &Object{
-					IsTombstone: true,
-					BucketType:  string(cmd.protobuf.Type),
-					Bucket:      string(cmd.protobuf.Bucket),
-					Key:         string(cmd.protobuf.Key),
-				}
-				response.Values = []*Object{object}
+				response.Values = []*Object{newTombstone(vclock, bucketType, bucket, key)}
 			} else {
-				response.Values = make([]*Object, len(pbContent))
-				for i, content := range pbContent {
-					ro, err := fromRpbContent(content)
-					if err != nil {
-						return err
-					}
-					ro.VClock = vclock
-					ro.BucketType = string(cmd.protobuf.Type)
-					ro.Bucket = string(cmd.protobuf.Bucket)
-					ro.Key = string(cmd.protobuf.Key)
-					response.Values[i] = ro
+				values, err := fromRpbContents(pbContent, vclock, bucketType, bucket, key)
+				if err != nil {
+					return err
 				}
-				if cmd.resolver != nil {
-					response.Values = cmd.resolver.Resolve(response.Values)
+				response.Values = values
+				if cmd.resolver != nil && len(values) > 1 {
+					response.Siblings = values
+					response.Values = cmd.resolver.Resolve(values)
 				}
 			}
 
@@ -120,6 +149,9 @@ type FetchValueResponse struct {
 	IsUnchanged bool
 	VClock      []byte
 	Values      []*Object
+	// Siblings holds the values returned by Riak before they were passed to the
+	// ConflictResolver. It is only set when siblings were resolved
+	Siblings []*Object
 }
 
 // FetchValueCommandBuilder type is required for creating new instances of FetchValueCommand
@@ -218,7 +250,7 @@ func (builder *FetchValueCommandBuilder) WithIfModified(ifModified []byte) *Fetc
 }
 
 // WithHeadOnly returns only the meta data for the value, useful when objects contain large amounts
-// of data
+// of data or to check that an object exists. The Objects returned have an empty Value
 func (builder *FetchValueCommandBuilder) WithHeadOnly(headOnly bool) *FetchValueCommandBuilder {
 	builder.protobuf.Head = &headOnly
 	return builder
@@ -273,12 +305,15 @@ type StoreValueCommand struct {
 	commandImpl
 	timeoutImpl
 	retryableCommandImpl
-	Response *StoreValueResponse
-	value    *Object
-	protobuf *rpbRiakKV.RpbPutReq
-	resolver ConflictResolver
+	Response      *StoreValueResponse
+	value         *Object
+	protobuf      *rpbRiakKV.RpbPutReq
+	resolver      ConflictResolver
+	lastWriteWins bool
 }
 
+var ErrStoreValueLastWriteWinsDisabled = newClientError("[StoreValueCommand] WithLastWriteWins requires a bucket with last_write_wins enabled", nil)
+
 // Name identifies this command
 func (cmd *StoreValueCommand) Name() string {
 	return cmd.getName("StoreValue")
@@ -289,6 +324,10 @@ func (cmd *StoreValueCommand) constructPbRequest() (msg proto.Message, err error
 
 	// Some properties of the value override options
 	setProtobufFromValue(cmd.protobuf, cmd.value)
+	// NB: an empty vclock is omitted, as Riak expects none when creating an object
+	if cmd.lastWriteWins || len(cmd.protobuf.Vclock) == 0 {
+		cmd.protobuf.Vclock = nil
+	}
 
 	cmd.protobuf.Content, err = toRpbContent(value)
 	if err != nil {
@@ -317,23 +356,15 @@ func (cmd *StoreValueCommand) onSuccess(msg proto.Message) error {
 			}
 
 			if pbContent := rpbPutResp.GetContent(); pbContent != nil && len(pbContent) > 0 {
-				response.Values = make([]*Object, len(pbContent))
-				for i, content := range pbContent {
-					ro, err := fromRpbContent(content)
-					if err != nil {
-						return err
-					}
-
-					ro.VClock = vclock
-					ro.BucketType = string(cmd.protobuf.Type)
-					ro.Bucket = string(cmd.protobuf.Bucket)
-					if responseKey == "" {
-						ro.Key = string(cmd.protobuf.Key)
-					} else {
-						ro.Key = responseKey
-					}
-					response.Values[i] = ro
+				key := responseKey
+				if key == "" {
+					key = string(cmd.protobuf.Key)
+				}
+				values, err := fromRpbContents(pbContent, vclock, string(cmd.protobuf.Type), string(cmd.protobuf.Bucket), key)
+				if err != nil {
+					return err
 				}
+				response.Values = values
 				if cmd.resolver != nil {
 					response.Values = cmd.resolver.Resolve(response.Values)
 				}
@@ -360,7 +391,7 @@ func (cmd *StoreValueCommand) getResponseProtobufMessage() proto.Message {
 }
 
 func setProtobufFromValue(pb *rpbRiakKV.RpbPutReq, value *Object) {
-	if value.VClock != nil {
+	if len(value.VClock) > 0 {
 		pb.Vclock = value.VClock
 	}
 	if value.BucketType != "" {
@@ -388,10 +419,12 @@ type StoreValueResponse struct {
 //		WithBucket("myBucket").
 //		Build()
 type StoreValueCommandBuilder struct {
-	value    *Object
-	timeout  time.Duration
-	protobuf *rpbRiakKV.RpbPutReq
-	resolver ConflictResolver
+	value         *Object
+	timeout       time.Duration
+	protobuf      *rpbRiakKV.RpbPutReq
+	resolver      ConflictResolver
+	lastWriteWins bool
+	bucketProps   *FetchBucketPropsResponse
 }
 
 // NewStoreValueCommandBuilder is a factory function for generating the command builder struct
@@ -425,12 +458,27 @@ func (builder *StoreValueCommandBuilder) WithKey(key string) *StoreValueCommandB
 	return builder
 }
 
-// WithVClock sets the vclock for the object to be stored, providing causal context for conflicts
+// WithVClock sets the vclock for the object to be stored, providing causal context for conflicts.
+// A nil or empty vclock stores the object as new, which may create siblings should it exist
 func (builder *StoreValueCommandBuilder) WithVClock(vclock []byte) *StoreValueCommandBuilder {
 	builder.protobuf.Vclock = vclock
 	return builder
 }
 
+// WithLastWriteWins omits the vclock from the request, whether set via WithVClock or on the
+// object, as buckets with last_write_wins enabled resolve conflicts by timestamp and ignore it
+func (builder *StoreValueCommandBuilder) WithLastWriteWins(lastWriteWins bool) *StoreValueCommandBuilder {
+	builder.lastWriteWins = lastWriteWins
+	return builder
+}
+
+// WithBucketProps sets the properties of the bucket, as returned by FetchBucketPropsCommand, which
+// are used to validate the command when it is built
+func (builder *StoreValueCommandBuilder) WithBucketProps(props *FetchBucketPropsResponse) *StoreValueCommandBuilder {
+	builder.bucketProps = props
+	return builder
+}
+
 // WithContent sets the object / value to be stored at the specified key
 func (builder *StoreValueCommandBuilder) WithContent(object *Object) *StoreValueCommandBuilder {
 	setProtobufFromValue(builder.protobuf, object)
@@ -438,6 +486,61 @@ func (builder *StoreValueCommandBuilder) WithContent(object *Object) *StoreValue
 	return builder
 }
 
+// content returns the object to be stored, creating an empty one if WithContent has not been called
+func (builder *StoreValueCommandBuilder) content() *Object {
+	if builder.value == nil {
+		builder.value = &Object{}
+	}
+	return builder.value
+}
+
+// WithContentType sets the content type of the object to be stored, e.g. "application/json". As
+// with the other content methods, it modifies the object set by WithContent, so must be called
+// after it
+func (builder *StoreValueCommandBuilder) WithContentType(contentType string) *StoreValueCommandBuilder {
+	builder.content().ContentType = contentType
+	return builder
+}
+
+// WithCharset sets the character set of the object to be stored, e.g. "utf-8"
+func (builder *StoreValueCommandBuilder) WithCharset(charset string) *StoreValueCommandBuilder {
+	builder.content().Charset = charset
+	return builder
+}
+
+// WithContentEncoding sets the content encoding of the object to be stored, e.g. "gzip"
+func (builder *StoreValueCommandBuilder) WithContentEncoding(contentEncoding string) *StoreValueCommandBuilder {
+	builder.content().ContentEncoding = contentEncoding
+	return builder
+}
+
+// AddMeta adds a user defined meta data pair to the object to be stored
+func (builder *StoreValueCommandBuilder) AddMeta(key, value string) *StoreValueCommandBuilder {
+	o := builder.content()
+	o.UserMeta = append(o.UserMeta, &Pair{Key: key, Value: value})
+	return builder
+}
+
+// AddToIndex adds the object to be stored to the specified binary secondary index. The "_bin"
+// suffix is appended to indexName if it is not already present
+func (builder *StoreValueCommandBuilder) AddToIndex(indexName string, indexValue string) *StoreValueCommandBuilder {
+	if !strings.HasSuffix(indexName, binIndexSuffix) {
+		indexName += binIndexSuffix
+	}
+	builder.content().AddToIndex(indexName, indexValue)
+	return builder
+}
+
+// AddToIntIndex adds the object to be stored to the specified integer secondary index. The "_int"
+// suffix is appended to indexName if it is not already present
+func (builder *StoreValueCommandBuilder) AddToIntIndex(indexName string, indexValue int64) *StoreValueCommandBuilder {
+	if !strings.HasSuffix(indexName, intIndexSuffix) {
+		indexName += intIndexSuffix
+	}
+	builder.content().AddToIndex(indexName, strconv.FormatInt(indexValue, 10))
+	return builder
+}
+
 // WithW sets the number of nodes that must report back a successful write in order for then
 // command operation to be considered a success by Riak
 //
@@ -484,13 +587,15 @@ func (builder *StoreValueCommandBuilder) WithReturnBody(returnBody bool) *StoreV
 }
 
 // WithIfNotModified tells Riak to only update the object in Riak if the vclock provided matches the
-// one currently in Riak
+// one currently in Riak. Otherwise the command fails with an error for which IsPreconditionFailed
+// returns true
 func (builder *StoreValueCommandBuilder) WithIfNotModified(ifNotModified bool) *StoreValueCommandBuilder {
 	builder.protobuf.IfNotModified = &ifNotModified
 	return builder
 }
 
-// WithIfNoneMatch tells Riak to store the object only if it does not already exist in the database
+// WithIfNoneMatch tells Riak to store the object only if it does not already exist in the database.
+// Otherwise the command fails with an error for which IsPreconditionFailed returns true
 func (builder *StoreValueCommandBuilder) WithIfNoneMatch(ifNoneMatch bool) *StoreValueCommandBuilder {
 	builder.protobuf.IfNoneMatch = &ifNoneMatch
 	return builder
@@ -535,13 +640,17 @@ func (builder *StoreValueCommandBuilder) Build() (Command, error) {
 	if err := validateLocatable(builder.protobuf); err != nil {
 		return nil, err
 	}
+	if builder.lastWriteWins && builder.bucketProps != nil && !builder.bucketProps.LastWriteWins {
+		return nil, ErrStoreValueLastWriteWinsDisabled
+	}
 	return &StoreValueCommand{
 		value: builder.value,
 		timeoutImpl: timeoutImpl{
 			timeout: builder.timeout,
 		},
-		protobuf: builder.protobuf,
-		resolver: builder.resolver}, nil
+		protobuf:      builder.protobuf,
+		resolver:      builder.resolver,
+		lastWriteWins: builder.lastWriteWins}, nil
 }
 
 // DeleteValue
@@ -549,6 +658,10 @@ func (builder *StoreValueCommandBuilder) Build() (Command, error) {
 // RpbDelResp
 
 // DeleteValueCommand is used to delete a value from Riak KV.
+//
+// Riak's delete response carries no data, so the number of replicas that acknowledged the delete
+// is not known. To require acknowledgement by a quorum of replicas use WithW, WithDw and WithPw,
+// in which case a successful Response means that the quorum was met
 type DeleteValueCommand struct {
 	commandImpl
 	timeoutImpl
@@ -720,6 +833,7 @@ func (builder *DeleteValueCommandBuilder) Build() (Command, error) {
 type ListBucketsCommand struct {
 	commandImpl
 	listingImpl
+	timeoutImpl
 	Response *ListBucketsResponse
 	protobuf *rpbRiakKV.RpbListBucketsReq
 	callback func(buckets []string) error
@@ -813,6 +927,7 @@ type ListBucketsResponse struct {
 //		Build()
 type ListBucketsCommandBuilder struct {
 	allowListing bool
+	timeout      time.Duration
 	callback     func(buckets []string) error
 	protobuf     *rpbRiakKV.RpbListBucketsReq
 }
@@ -851,9 +966,10 @@ func (builder *ListBucketsCommandBuilder) WithCallback(callback func([]string) e
 	return builder
 }
 
-// WithTimeout sets a timeout in milliseconds to be used for this command operation
+// WithTimeout sets a timeout to be used for this command operation
 func (builder *ListBucketsCommandBuilder) WithTimeout(timeout time.Duration) *ListBucketsCommandBuilder {
 	timeoutMilliseconds := uint32(timeout / time.Millisecond)
+	builder.timeout = timeout
 	builder.protobuf.Timeout = &timeoutMilliseconds
 	return builder
 }
@@ -876,6 +992,9 @@ func (builder *ListBucketsCommandBuilder) Build() (Command, error) {
 		listingImpl: listingImpl{
 			allowListing: builder.allowListing,
 		},
+		timeoutImpl: timeoutImpl{
+			timeout: builder.timeout,
+		},
 		protobuf: builder.protobuf,
 		callback: builder.callback}, nil
 }
@@ -1230,7 +1349,10 @@ func (cmd *SecondaryIndexQueryCommand) onSuccess(msg proto.Message) error {
 				cmd.Response = response
 			}
 
-			response.Continuation = rpbIndexResp.GetContinuation()
+			// NB: when streaming, the continuation may not be repeated in every message
+			if c := rpbIndexResp.GetContinuation(); c != nil {
+				response.Continuation = c
+			}
 
 			var results []*SecondaryIndexQueryResult
 			rpbIndexRespResultsLen := len(rpbIndexResp.GetResults())
@@ -1457,11 +1579,16 @@ func (builder *SecondaryIndexQueryCommandBuilder) Build() (Command, error) {
 // MapReduceCommand is used to fetch keys or data from Riak KV using the MapReduce technique
 type MapReduceCommand struct {
 	commandImpl
-	Response  [][]byte
-	protobuf  *rpbRiakKV.RpbMapRedReq
-	streaming bool
-	callback  func(response []byte) error
-	done      bool
+	timeoutImpl
+	Response [][]byte
+	// PhaseResponses groups the JSON-encoded responses by the number of the query phase that
+	// emitted them. A phase that emitted no output has no entry
+	PhaseResponses map[uint32][][]byte
+	protobuf       *rpbRiakKV.RpbMapRedReq
+	streaming      bool
+	callback       func(response []byte) error
+	phaseCallback  func(phase uint32, response []byte) error
+	done           bool
 }
 
 // Name identifies this command
@@ -1488,17 +1615,34 @@ func (cmd *MapReduceCommand) onSuccess(msg proto.Message) error {
 		if rpbMapRedResp, ok := msg.(*rpbRiakKV.RpbMapRedResp); ok {
 			cmd.done = rpbMapRedResp.GetDone()
 			rpbMapRedRespData := rpbMapRedResp.GetResponse()
+			// NB: the final message usually carries only the done flag, and a phase
+			// that emits nothing sends no data at all
+			if len(rpbMapRedRespData) == 0 {
+				return nil
+			}
+			phase := rpbMapRedResp.GetPhase()
 			if cmd.streaming {
-				if cmd.callback == nil {
+				if cmd.callback == nil && cmd.phaseCallback == nil {
 					panic("MapReduceCommand requires a callback when streaming.")
-				} else {
+				}
+				if cmd.callback != nil {
 					if err := cmd.callback(rpbMapRedRespData); err != nil {
 						cmd.Response = nil
 						return err
 					}
 				}
+				if cmd.phaseCallback != nil {
+					if err := cmd.phaseCallback(phase, rpbMapRedRespData); err != nil {
+						cmd.Response = nil
+						return err
+					}
+				}
 			} else {
 				cmd.Response = append(cmd.Response, rpbMapRedRespData)
+				if cmd.PhaseResponses == nil {
+					cmd.PhaseResponses = make(map[uint32][][]byte)
+				}
+				cmd.PhaseResponses[phase] = append(cmd.PhaseResponses[phase], rpbMapRedRespData)
 			}
 		} else {
 			cmd.done = true
@@ -1526,11 +1670,19 @@ func (cmd *MapReduceCommand) getResponseProtobufMessage() proto.Message {
 //		WithQuery("myMapReduceQuery").
 //		Build()
 type MapReduceCommandBuilder struct {
-	protobuf  *rpbRiakKV.RpbMapRedReq
-	streaming bool
-	callback  func(response []byte) error
+	protobuf       *rpbRiakKV.RpbMapRedReq
+	streaming      bool
+	callback       func(response []byte) error
+	phaseCallback  func(phase uint32, response []byte) error
+	maxRequestSize int
+	timeout        time.Duration
 }
 
+const (
+	ErrMapReduceRequestTooLarge = "[MapReduceCommand] request of %d bytes exceeds maximum size of %d bytes"
+	ErrMapReduceInputsNotList   = "[MapReduceCommand] only a query with a list of bucket/key inputs may be split"
+)
+
 // NewMapReduceCommandBuilder is a factory function for generating the command builder struct
 func NewMapReduceCommandBuilder() *MapReduceCommandBuilder {
 	return &MapReduceCommandBuilder{
@@ -1546,9 +1698,16 @@ func (builder *MapReduceCommandBuilder) WithQuery(query string) *MapReduceComman
 	return builder
 }
 
+// WithContentType sets the content type of the map reduce query, which defaults to
+// "application/json"
+func (builder *MapReduceCommandBuilder) WithContentType(contentType string) *MapReduceCommandBuilder {
+	builder.protobuf.ContentType = []byte(contentType)
+	return builder
+}
+
 // WithStreaming sets the command to provide a streamed response
 //
-// If true, a callback must be provided via WithCallback()
+// If true, a callback must be provided via WithCallback() or WithPhaseCallback()
 func (builder *MapReduceCommandBuilder) WithStreaming(streaming bool) *MapReduceCommandBuilder {
 	builder.streaming = streaming
 	return builder
@@ -1562,17 +1721,302 @@ func (builder *MapReduceCommandBuilder) WithCallback(callback func([]byte) error
 	return builder
 }
 
+// WithPhaseCallback sets a callback to be used when handling a streaming response that also
+// receives the number of the query phase each partial result was emitted by
+//
+// Requires WithStreaming(true)
+func (builder *MapReduceCommandBuilder) WithPhaseCallback(callback func(phase uint32, response []byte) error) *MapReduceCommandBuilder {
+	builder.phaseCallback = callback
+	return builder
+}
+
+// WithTimeout sets how long to wait for each response from Riak, when greater than the Node's
+// RequestTimeout. The query itself may set a "timeout" for Riak to stop the job
+func (builder *MapReduceCommandBuilder) WithTimeout(timeout time.Duration) *MapReduceCommandBuilder {
+	builder.timeout = timeout
+	return builder
+}
+
+// WithMaxRequestSize sets the maximum size in bytes of the map reduce query. Build returns an
+// error rather than building a command that Riak would reject. See BuildSplit for executing
+// a query with a large input list as several smaller jobs
+func (builder *MapReduceCommandBuilder) WithMaxRequestSize(maxRequestSize int) *MapReduceCommandBuilder {
+	builder.maxRequestSize = maxRequestSize
+	return builder
+}
+
 // Build validates the configuration options provided then builds the command
 func (builder *MapReduceCommandBuilder) Build() (Command, error) {
 	if builder.protobuf == nil {
 		panic("builder.protobuf must not be nil")
 	}
-	if builder.streaming && builder.callback == nil {
+	return builder.build(builder.protobuf)
+}
+
+// BuildSplit validates the configuration options provided then builds one command for every
+// maxInputs bucket/key inputs of the query, so that a query with a very large input list may
+// be executed as several smaller jobs. Each job runs every phase of the query, which means
+// that a reduce phase only reduces the results of its own job's inputs
+func (builder *MapReduceCommandBuilder) BuildSplit(maxInputs int) ([]Command, error) {
+	if builder.protobuf == nil {
+		panic("builder.protobuf must not be nil")
+	}
+	if maxInputs < 1 {
+		return nil, newClientError("maxInputs must be greater than zero", nil)
+	}
+
+	var query map[string]json.RawMessage
+	if err := json.Unmarshal(builder.protobuf.Request, &query); err != nil {
+		return nil, err
+	}
+	var inputs []json.RawMessage
+	if err := json.Unmarshal(query["inputs"], &inputs); err != nil {
+		return nil, newClientError(ErrMapReduceInputsNotList, err)
+	}
+
+	if len(inputs) <= maxInputs {
+		cmd, err := builder.build(builder.protobuf)
+		if err != nil {
+			return nil, err
+		}
+		return []Command{cmd}, nil
+	}
+
+	cmds := make([]Command, 0, (len(inputs)+maxInputs-1)/maxInputs)
+	for i := 0; i < len(inputs); i += maxInputs {
+		end := i + maxInputs
+		if end > len(inputs) {
+			end = len(inputs)
+		}
+		var err error
+		if query["inputs"], err = json.Marshal(inputs[i:end]); err != nil {
+			return nil, err
+		}
+		request, err := json.Marshal(query)
+		if err != nil {
+			return nil, err
+		}
+		cmd, err := builder.build(&rpbRiakKV.RpbMapRedReq{
+			Request:     request,
+			ContentType: builder.protobuf.ContentType,
+		})
+		if err != nil {
+			return nil, err
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds, nil
+}
+
+func (builder *MapReduceCommandBuilder) build(protobuf *rpbRiakKV.RpbMapRedReq) (Command, error) {
+	if builder.streaming && builder.callback == nil && builder.phaseCallback == nil {
 		return nil, newClientError("MapReduceCommand requires a callback when streaming.", nil)
 	}
+	if size := len(protobuf.Request); builder.maxRequestSize > 0 && size > builder.maxRequestSize {
+		return nil, newClientError(fmt.Sprintf(ErrMapReduceRequestTooLarge, size, builder.maxRequestSize), nil)
+	}
 	return &MapReduceCommand{
-		protobuf:  builder.protobuf,
-		streaming: builder.streaming,
-		callback:  builder.callback,
+		timeoutImpl: timeoutImpl{
+			timeout: builder.timeout,
+		},
+		protobuf:      protobuf,
+		streaming:     builder.streaming,
+		callback:      builder.callback,
+		phaseCallback: builder.phaseCallback,
 	}, nil
 }
+
+// CountKeys
+// RpbMapRedReq
+// RpbMapRedResp
+
+// CountKeysCommand counts the keys in a bucket using a MapReduce job over the entire bucket.
+//
+// Like ListKeysCommand, this requires Riak to traverse every key in the cluster and should not be
+// used in production, so WithAllowListing must be used to build the command
+type CountKeysCommand struct {
+	commandImpl
+	listingImpl
+	timeoutImpl
+	Response uint64
+	protobuf *rpbRiakKV.RpbMapRedReq
+	done     bool
+}
+
+// Name identifies this command
+func (cmd *CountKeysCommand) Name() string {
+	return cmd.getName("CountKeys")
+}
+
+func (cmd *CountKeysCommand) isDone() bool {
+	return cmd.done
+}
+
+func (cmd *CountKeysCommand) constructPbRequest() (msg proto.Message, err error) {
+	msg = cmd.protobuf
+	return
+}
+
+func (cmd *CountKeysCommand) onSuccess(msg proto.Message) error {
+	cmd.success = true
+	if msg == nil {
+		cmd.done = true
+		return nil
+	}
+	rpbMapRedResp, ok := msg.(*rpbRiakKV.RpbMapRedResp)
+	if !ok {
+		cmd.done = true
+		return fmt.Errorf("[CountKeysCommand] could not convert %v to RpbMapRedResp", reflect.TypeOf(msg))
+	}
+	cmd.done = rpbMapRedResp.GetDone()
+	if data := rpbMapRedResp.GetResponse(); len(data) > 0 {
+		var counts []uint64
+		if err := json.Unmarshal(data, &counts); err != nil {
+			return err
+		}
+		for _, count := range counts {
+			cmd.Response += count
+		}
+	}
+	return nil
+}
+
+func (cmd *CountKeysCommand) getRequestCode() byte {
+	return rpbCode_RpbMapRedReq
+}
+
+func (cmd *CountKeysCommand) getResponseCode() byte {
+	return rpbCode_RpbMapRedResp
+}
+
+func (cmd *CountKeysCommand) getResponseProtobufMessage() proto.Message {
+	return &rpbRiakKV.RpbMapRedResp{}
+}
+
+// CountKeysCommandBuilder type is required for creating new instances of CountKeysCommand
+//
+//	command, err := NewCountKeysCommandBuilder().
+//		WithAllowListing().
+//		WithBucketType("myBucketType").
+//		WithBucket("myBucket").
+//		Build()
+type CountKeysCommandBuilder struct {
+	allowListing bool
+	timeout      time.Duration
+	bucketType   string
+	bucket       string
+}
+
+// NewCountKeysCommandBuilder is a factory function for generating the command builder struct
+func NewCountKeysCommandBuilder() *CountKeysCommandBuilder {
+	return &CountKeysCommandBuilder{}
+}
+
+// WithAllowListing will allow this command to be built and execute
+func (builder *CountKeysCommandBuilder) WithAllowListing() *CountKeysCommandBuilder {
+	builder.allowListing = true
+	return builder
+}
+
+// WithBucketType sets the bucket-type to be used by the command. If omitted, 'default' is used
+func (builder *CountKeysCommandBuilder) WithBucketType(bucketType string) *CountKeysCommandBuilder {
+	builder.bucketType = bucketType
+	return builder
+}
+
+// WithBucket sets the bucket to be used by the command
+func (builder *CountKeysCommandBuilder) WithBucket(bucket string) *CountKeysCommandBuilder {
+	builder.bucket = bucket
+	return builder
+}
+
+// WithTimeout sets a timeout to be used for this command operation
+func (builder *CountKeysCommandBuilder) WithTimeout(timeout time.Duration) *CountKeysCommandBuilder {
+	builder.timeout = timeout
+	return builder
+}
+
+// Build validates the configuration options provided then builds the command
+func (builder *CountKeysCommandBuilder) Build() (Command, error) {
+	if builder.bucket == "" {
+		return nil, ErrBucketRequired
+	}
+	if !builder.allowListing {
+		return nil, ErrListingDisabled
+	}
+
+	var inputs interface{} = builder.bucket
+	if builder.bucketType != "" {
+		inputs = []string{builder.bucketType, builder.bucket}
+	}
+	query := map[string]interface{}{
+		"inputs": inputs,
+		"query": []interface{}{
+			map[string]interface{}{
+				"reduce": map[string]string{
+					"language": "erlang",
+					"module":   "riak_kv_mapreduce",
+					"function": "reduce_count_inputs",
+				},
+			},
+		},
+	}
+	if builder.timeout > 0 {
+		query["timeout"] = uint32(builder.timeout / time.Millisecond)
+	}
+	request, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CountKeysCommand{
+		listingImpl: listingImpl{
+			allowListing: builder.allowListing,
+		},
+		timeoutImpl: timeoutImpl{
+			timeout: builder.timeout,
+		},
+		protobuf: &rpbRiakKV.RpbMapRedReq{
+			Request:     request,
+			ContentType: []byte("application/json"),
+		},
+	}, nil
+}
+
+// SetClientId
+// RpbSetClientIdReq
+// RpbSetClientIdResp
+
+// setClientIdCommand tags a connection with a client id, sent when it is established if
+// NodeOptions.ClientID is set
+type setClientIdCommand struct {
+	commandImpl
+	clientID []byte
+}
+
+func (cmd *setClientIdCommand) Name() string {
+	return cmd.getName("SetClientId")
+}
+
+func (cmd *setClientIdCommand) constructPbRequest() (msg proto.Message, err error) {
+	return &rpbRiakKV.RpbSetClientIdReq{
+		ClientId: cmd.clientID,
+	}, nil
+}
+
+func (cmd *setClientIdCommand) onSuccess(msg proto.Message) error {
+	cmd.success = true
+	return nil
+}
+
+func (cmd *setClientIdCommand) getRequestCode() byte {
+	return rpbCode_RpbSetClientIdReq
+}
+
+func (cmd *setClientIdCommand) getResponseCode() byte {
+	return rpbCode_RpbSetClientIdResp
+}
+
+func (cmd *setClientIdCommand) getResponseProtobufMessage() proto.Message {
+	return nil
+}
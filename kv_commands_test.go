@@ -16,7 +16,9 @@ package riak
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"math"
 	"reflect"
 	"testing"
 	"time"
@@ -325,6 +327,55 @@ func TestParseRpbGetRespWithSiblingsCorrectly(t *testing.T) {
 	}
 }
 
+type countingConflictResolver struct {
+	ConflictResolver
+	calls int
+}
+
+func (cr *countingConflictResolver) Resolve(objs []*Object) []*Object {
+	cr.calls++
+	return cr.ConflictResolver.Resolve(objs)
+}
+
+func TestParseRpbGetRespResolvesSiblingsWithLastModifiedWins(t *testing.T) {
+	contents := make([]*rpbRiakKV.RpbContent, 3)
+	for i, lastMod := range []uint32{1000, 3000, 2000} {
+		contents[i] = generateTestRpbContent(fmt.Sprintf("value_%d", i), "text/plain")
+		contents[i].LastMod = proto.Uint32(lastMod)
+	}
+	rpbGetResp := &rpbRiakKV.RpbGetResp{
+		Content: contents,
+		Vclock:  vclockBytes,
+	}
+
+	cr := &countingConflictResolver{ConflictResolver: &LastModifiedWinsResolver{}}
+	cmd, err := NewFetchValueCommandBuilder().
+		WithBucket("bucket_name").
+		WithKey("key").
+		WithConflictResolver(cr).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.onSuccess(rpbGetResp); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := cr.calls, 1; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	rsp := cmd.(*FetchValueCommand).Response
+	if got, want := len(rsp.Values), 1; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := string(rsp.Values[0].Value), "value_1"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := len(rsp.Siblings), 3; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
 func TestParseRpbGetRespWithoutContentCorrectly(t *testing.T) {
 	builder := NewFetchValueCommandBuilder()
 	cmd, err := builder.
@@ -351,6 +402,355 @@ func TestParseRpbGetRespWithoutContentCorrectly(t *testing.T) {
 	}
 }
 
+func TestDecodeRecordedRpbGetResp(t *testing.T) {
+	// RpbContent with value 'v1' or 'v2' and content type 'text/plain'
+	content := func(v byte) []byte {
+		return append([]byte{0x0a, 0x10, 0x0a, 0x02, 'v', v, 0x12, 0x0a}, "text/plain"...)
+	}
+	vclock := []byte{0x12, 0x02, 'v', 'c'}
+	tombstone := []byte{0x0a, 0x04, 0x0a, 0x00, 0x58, 0x01}
+	join := func(parts ...[]byte) []byte {
+		data := []byte{rpbCode_RpbGetResp}
+		for _, p := range parts {
+			data = append(data, p...)
+		}
+		return data
+	}
+
+	tests := []struct {
+		name       string
+		data       []byte
+		notFound   bool
+		values     []string
+		tombstones int
+	}{
+		{"single value", join(content('1'), vclock), false, []string{"v1"}, 0},
+		{"siblings", join(content('1'), content('2'), vclock), false, []string{"v1", "v2"}, 0},
+		{"tombstone", join(tombstone, vclock), false, []string{""}, 1},
+		{"not found", join(), true, nil, 0},
+	}
+	for _, tt := range tests {
+		cmd, err := NewFetchValueCommandBuilder().
+			WithBucketType("bucket_type").
+			WithBucket("bucket_name").
+			WithKey("key").
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err := decodeRiakMessage(cmd, tt.data)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if err = cmd.onSuccess(msg); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		rsp := cmd.(*FetchValueCommand).Response
+		if got, want := rsp.IsNotFound, tt.notFound; got != want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, want)
+		}
+		if got, want := len(rsp.Values), len(tt.values); got != want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, want)
+			continue
+		}
+		tombstones := 0
+		for i, ro := range rsp.Values {
+			if got, want := string(ro.Value), tt.values[i]; got != want {
+				t.Errorf("%s: got %v, want %v", tt.name, got, want)
+			}
+			if got, want := string(ro.VClock), "vc"; got != want {
+				t.Errorf("%s: got %v, want %v", tt.name, got, want)
+			}
+			if ro.IsTombstone {
+				tombstones++
+			}
+		}
+		if got, want := tombstones, tt.tombstones; got != want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, want)
+		}
+		if !tt.notFound {
+			if got, want := string(rsp.VClock), "vc"; got != want {
+				t.Errorf("%s: got %v, want %v", tt.name, got, want)
+			}
+		}
+	}
+}
+
+func TestParseRpbGetRespTypedIndexes(t *testing.T) {
+	rpbGetResp := &rpbRiakKV.RpbGetResp{
+		Content: []*rpbRiakKV.RpbContent{
+			{
+				Value: []byte("this is a value"),
+				Indexes: []*rpbRiak.RpbPair{
+					{Key: []byte("email_bin"), Value: []byte("golang@basho.com")},
+					{Key: []byte("age_int"), Value: []byte("42")},
+					{Key: []byte("age_int"), Value: []byte("-7")},
+				},
+			},
+		},
+		Vclock: vclockBytes,
+	}
+
+	cmd, err := NewFetchValueCommandBuilder().
+		WithBucket("bucket_name").
+		WithKey("key").
+		Build()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := cmd.onSuccess(rpbGetResp); err != nil {
+		t.Fatal(err)
+	}
+	ro := cmd.(*FetchValueCommand).Response.Values[0]
+
+	intIndexes, err := ro.IntIndexes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := intIndexes, map[string][]int64{"age_int": {42, -7}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := ro.BinIndexes(), map[string][]string{"email_bin": {"golang@basho.com"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	ro.AddToIndex("bad_int", "forty-two")
+	if _, err := ro.IntIndexes(); err == nil {
+		t.Error("expected non-nil error")
+	}
+}
+
+func TestObjectRoundTripsThroughRpbContent(t *testing.T) {
+	ro := &Object{
+		Value:           []byte("this is a value"),
+		ContentType:     "application/json",
+		Charset:         "utf-8",
+		ContentEncoding: "gzip",
+		UserMeta: []*Pair{
+			{"metaKey1", "metaValue1"},
+			{"metaKey2", "metaValue2"},
+			{"metaKey3", "metaValue3"},
+		},
+		Links: []*Link{
+			{"b", "k", "t"},
+		},
+	}
+	ro.AddToIndex("email_bin", "golang@basho.com")
+	ro.AddToIntIndex("age_int", 42)
+	ro.AddToIntIndex("age_int", -7)
+
+	rpbContent, err := toRpbContent(ro)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(rpbContent.Indexes), 3; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := len(rpbContent.Usermeta), 3; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// NB: last-modified is only ever set by Riak
+	ro.LastModified = time.Unix(0, 0)
+
+	got, err := fromRpbContent(rpbContent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, ro) {
+		t.Errorf("got %v, want %v", got, ro)
+	}
+	intIndexes, err := got.IntIndexes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := intIndexes, map[string][]int64{"age_int": {42, -7}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseRpbGetRespTombstoneRetainsVClock(t *testing.T) {
+	rpbGetResp := &rpbRiakKV.RpbGetResp{
+		Vclock: vclockBytes,
+	}
+
+	builder := NewFetchValueCommandBuilder()
+	cmd, err := builder.
+		WithBucketType("bucket_type").
+		WithBucket("bucket_name").
+		WithKey("key").
+		WithReturnDeletedVClock(true).
+		Build()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := cmd.onSuccess(rpbGetResp); err != nil {
+		t.Fatal(err)
+	}
+	fetchValueCommand := cmd.(*FetchValueCommand)
+	rsp := fetchValueCommand.Response
+	if rsp == nil {
+		t.Fatal("unexpected nil response")
+	}
+	if got, want := rsp.IsNotFound, false; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := len(rsp.Values), 1; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	ro := rsp.Values[0]
+	if got, want := ro.IsTombstone, true; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := ro.VClock, vclockBytes; !bytes.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := ro.Key, "key"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// tombstone may be used as causal context for a delete
+	dcmd, err := NewDeleteValueCommandBuilder().
+		WithBucketType(ro.BucketType).
+		WithBucket(ro.Bucket).
+		WithKey(ro.Key).
+		WithVClock(ro.VClock).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	protobuf, err := dcmd.constructPbRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := protobuf.(*rpbRiakKV.RpbDelReq).Vclock, vclockBytes; !bytes.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFetchValueHeadOnlyDecodesMetadataWithoutValue(t *testing.T) {
+	cmd, err := NewFetchValueCommandBuilder().
+		WithBucket("bucket_name").
+		WithKey("key").
+		WithHeadOnly(true).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := getRiakMessage(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &rpbRiakKV.RpbGetReq{}
+	if err = proto.Unmarshal(data[5:], req); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := req.GetHead(), true; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// NB: with head, Riak returns the content metadata with an empty value
+	encoded, err := proto.Marshal(&rpbRiakKV.RpbGetResp{
+		Vclock: vclockBytes,
+		Content: []*rpbRiakKV.RpbContent{
+			{
+				Value:       []byte{},
+				ContentType: []byte("application/json"),
+				Vtag:        []byte("vtag"),
+				LastMod:     proto.Uint32(1234),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := decodeRiakMessage(cmd, append([]byte{rpbCode_RpbGetResp}, encoded...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cmd.onSuccess(msg); err != nil {
+		t.Fatal(err)
+	}
+	rsp := cmd.(*FetchValueCommand).Response
+	if got, want := rsp.IsNotFound, false; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := len(rsp.Values), 1; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	ro := rsp.Values[0]
+	if got, want := ro.IsTombstone, false; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := len(ro.Value), 0; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := ro.VTag, "vtag"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := ro.ContentType, "application/json"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := ro.LastModified.Unix(), int64(1234); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := ro.VClock, vclockBytes; !bytes.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFetchValueIfModifiedUnchangedResponse(t *testing.T) {
+	cmd, err := NewFetchValueCommandBuilder().
+		WithBucket("bucket_name").
+		WithKey("key").
+		WithIfModified(vclockBytes).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := getRiakMessage(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := data[4], rpbCode_RpbGetReq; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	req := &rpbRiakKV.RpbGetReq{}
+	if err = proto.Unmarshal(data[5:], req); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := req.GetIfModified(), vclockBytes; !bytes.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	encoded, err := proto.Marshal(&rpbRiakKV.RpbGetResp{
+		Unchanged: proto.Bool(true),
+		Vclock:    vclockBytes,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := decodeRiakMessage(cmd, append([]byte{rpbCode_RpbGetResp}, encoded...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cmd.onSuccess(msg); err != nil {
+		t.Fatal(err)
+	}
+	rsp := cmd.(*FetchValueCommand).Response
+	if got, want := rsp.IsUnchanged, true; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := rsp.IsNotFound, false; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := len(rsp.Values), 0; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
 func TestValidationOfRpbGetReqViaBuilder(t *testing.T) {
 	// validate that Bucket is required
 	builder := NewFetchValueCommandBuilder()
@@ -918,6 +1318,243 @@ func TestBuildRpbDelReqCorrectlyViaBuilder(t *testing.T) {
 	}
 }
 
+func TestStoreValueWithLastWriteWinsOmitsVClock(t *testing.T) {
+	ro := &Object{
+		Value:  []byte("this is a value"),
+		VClock: vclockBytes,
+	}
+	cmd, err := NewStoreValueCommandBuilder().
+		WithBucket("bucket").
+		WithVClock(vclockBytes).
+		WithContent(ro).
+		WithLastWriteWins(true).
+		WithBucketProps(&FetchBucketPropsResponse{LastWriteWins: true}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := cmd.constructPbRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req := msg.(*rpbRiakKV.RpbPutReq); req.Vclock != nil {
+		t.Errorf("expected nil vclock, got %v", req.Vclock)
+	}
+
+	_, err = NewStoreValueCommandBuilder().
+		WithBucket("bucket").
+		WithContent(ro).
+		WithLastWriteWins(true).
+		WithBucketProps(&FetchBucketPropsResponse{}).
+		Build()
+	if err != ErrStoreValueLastWriteWinsDisabled {
+		t.Errorf("got %v, want %v", err, ErrStoreValueLastWriteWinsDisabled)
+	}
+}
+
+func TestStoreValueRequestBytes(t *testing.T) {
+	cmd, err := NewStoreValueCommandBuilder().
+		WithBucket("bucket").
+		WithKey("key").
+		WithVClock([]byte{}).
+		WithContent(&Object{Value: []byte("value")}).
+		WithW(3).
+		WithPw(1).
+		WithDw(2).
+		WithReturnBody(true).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := getRiakMessage(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// NB: skip the length and message code
+	if got, want := data[4], rpbCode_RpbPutReq; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	req := &rpbRiakKV.RpbPutReq{}
+	if err = proto.Unmarshal(data[5:], req); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := req.GetW(), uint32(3); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := req.GetPw(), uint32(1); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := req.GetDw(), uint32(2); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := req.GetReturnBody(), true; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if req.Vclock != nil {
+		t.Errorf("expected empty vclock to be omitted, got %v", req.Vclock)
+	}
+}
+
+func TestKVQuorumValuesEncodeNumericAndSymbolic(t *testing.T) {
+	if got, want := RWQuorum, uint32(4294967293); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	fetchCmd, err := NewFetchValueCommandBuilder().
+		WithBucket("bucket").
+		WithKey("key").
+		WithR(2).
+		WithPr(RWQuorum).
+		WithBasicQuorum(true).
+		WithNotFoundOk(false).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := getRiakMessage(fetchCmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	getReq := &rpbRiakKV.RpbGetReq{}
+	if err = proto.Unmarshal(data[5:], getReq); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := getReq.GetR(), uint32(2); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := getReq.GetPr(), uint32(math.MaxUint32-2); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := getReq.GetBasicQuorum(), true; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if getReq.NotfoundOk == nil || getReq.GetNotfoundOk() {
+		t.Errorf("expected notfound_ok to be sent as false, got %v", getReq.NotfoundOk)
+	}
+
+	storeCmd, err := NewStoreValueCommandBuilder().
+		WithBucket("bucket").
+		WithKey("key").
+		WithContent(&Object{Value: []byte("value")}).
+		WithW(RWAll).
+		WithDw(RWOne).
+		WithPw(RWDefault).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err = getRiakMessage(storeCmd); err != nil {
+		t.Fatal(err)
+	}
+	putReq := &rpbRiakKV.RpbPutReq{}
+	if err = proto.Unmarshal(data[5:], putReq); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := putReq.GetW(), uint32(math.MaxUint32-3); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := putReq.GetDw(), uint32(math.MaxUint32-1); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := putReq.GetPw(), uint32(math.MaxUint32-4); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestStoreValueContentMetadataViaBuilder(t *testing.T) {
+	cmd, err := NewStoreValueCommandBuilder().
+		WithBucket("bucket").
+		WithKey("key").
+		WithContent(&Object{Value: []byte(`{"name":"riak"}`)}).
+		WithContentType("application/json").
+		WithCharset("utf-8").
+		WithContentEncoding("identity").
+		AddMeta("owner", "basho").
+		AddToIntIndex("age", 42).
+		AddToIndex("email_bin", "riak@basho.com").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := getRiakMessage(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &rpbRiakKV.RpbPutReq{}
+	if err = proto.Unmarshal(data[5:], req); err != nil {
+		t.Fatal(err)
+	}
+	content := req.GetContent()
+	if got, want := string(content.GetValue()), `{"name":"riak"}`; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := string(content.GetContentType()), "application/json"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := string(content.GetCharset()), "utf-8"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := string(content.GetContentEncoding()), "identity"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := len(content.GetUsermeta()), 1; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	meta := content.GetUsermeta()[0]
+	if got, want := string(meta.GetKey())+"="+string(meta.GetValue()), "owner=basho"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	indexes := make(map[string]string)
+	for _, idx := range content.GetIndexes() {
+		indexes[string(idx.GetKey())] = string(idx.GetValue())
+	}
+	want := map[string]string{"age_int": "42", "email_bin": "riak@basho.com"}
+	if !reflect.DeepEqual(indexes, want) {
+		t.Errorf("got %v, want %v", indexes, want)
+	}
+}
+
+func TestStoreValueConditionalRequestBytesAndPreconditionFailed(t *testing.T) {
+	cmd, err := NewStoreValueCommandBuilder().
+		WithBucket("bucket").
+		WithKey("key").
+		WithVClock(vclockBytes).
+		WithIfNotModified(true).
+		WithIfNoneMatch(true).
+		WithContent(&Object{Value: []byte("value")}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := getRiakMessage(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &rpbRiakKV.RpbPutReq{}
+	if err = proto.Unmarshal(data[5:], req); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := req.GetIfNotModified(), true; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := req.GetIfNoneMatch(), true; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	errResp, err := buildRiakErrorResp(0, "match_found")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = maybeRiakError(errResp)
+	if !IsPreconditionFailed(err) {
+		t.Errorf("expected a failed precondition, got %v", err)
+	}
+	// NB: the same request will fail again, so it is left to the caller to retry
+	if retry, _ := (&ExponentialBackoff{MaxAttempts: 3}).ShouldRetry(err, 1); retry {
+		t.Error("expected a failed precondition not to be retried")
+	}
+}
+
 func TestValidationOfRpbDelReqViaBuilder(t *testing.T) {
 	builder := NewDeleteValueCommandBuilder()
 	// validate that Bucket is required
@@ -940,6 +1577,65 @@ func TestValidationOfRpbDelReqViaBuilder(t *testing.T) {
 	}
 }
 
+func TestDeleteValueRequestBytesAndEmptyResponse(t *testing.T) {
+	cmd, err := NewDeleteValueCommandBuilder().
+		WithBucketType("bucket_type").
+		WithBucket("bucket_name").
+		WithKey("key").
+		WithVClock(vclockBytes).
+		WithRw(1).
+		WithR(2).
+		WithW(3).
+		WithPr(4).
+		WithPw(5).
+		WithDw(6).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := getRiakMessage(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := data[4], rpbCode_RpbDelReq; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	req := &rpbRiakKV.RpbDelReq{}
+	if err = proto.Unmarshal(data[5:], req); err != nil {
+		t.Fatal(err)
+	}
+	want := &rpbRiakKV.RpbDelReq{
+		Type:   []byte("bucket_type"),
+		Bucket: []byte("bucket_name"),
+		Key:    []byte("key"),
+		Vclock: vclockBytes,
+		Rw:     proto.Uint32(1),
+		R:      proto.Uint32(2),
+		W:      proto.Uint32(3),
+		Pr:     proto.Uint32(4),
+		Pw:     proto.Uint32(5),
+		Dw:     proto.Uint32(6),
+	}
+	if !proto.Equal(req, want) {
+		t.Errorf("got %v, want %v", req, want)
+	}
+
+	// NB: Riak responds with an empty RpbDelResp whether or not the key existed
+	msg, err := decodeRiakMessage(cmd, []byte{rpbCode_RpbDelResp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cmd.onSuccess(msg); err != nil {
+		t.Fatal(err)
+	}
+	if !cmd.Success() {
+		t.Error("expected success")
+	}
+	if got, want := cmd.(*DeleteValueCommand).Response, true; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
 // ListBuckets
 func TestListBucketsErrorsViaBuilder(t *testing.T) {
 	var streamingCallback = func(buckets []string) error { return nil }
@@ -1314,6 +2010,64 @@ func TestValidationOfRpbGetBucketKeyPreflistReqViaBuilder(t *testing.T) {
 	}
 }
 
+func TestFetchPreflistWireRoundTrip(t *testing.T) {
+	cmd, err := NewFetchPreflistCommandBuilder().
+		WithBucketType("bucket_type").
+		WithBucket("bucket_name").
+		WithKey("key").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := getRiakMessage(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := data[4], rpbCode_RpbGetBucketKeyPreflistReq; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	req := &rpbRiakKV.RpbGetBucketKeyPreflistReq{}
+	if err = proto.Unmarshal(data[5:], req); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(req.GetType()), "bucket_type"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := string(req.GetBucket()), "bucket_name"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := string(req.GetKey()), "key"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	encoded, err := proto.Marshal(&rpbRiakKV.RpbGetBucketKeyPreflistResp{
+		Preflist: []*rpbRiakKV.RpbBucketKeyPreflistItem{
+			{Partition: proto.Int64(0), Node: []byte("riak@10.0.0.1"), Primary: proto.Bool(true)},
+			{Partition: proto.Int64(1), Node: []byte("riak@10.0.0.2"), Primary: proto.Bool(true)},
+			{Partition: proto.Int64(2), Node: []byte("riak@10.0.0.3"), Primary: proto.Bool(false)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := decodeRiakMessage(cmd, append([]byte{rpbCode_RpbGetBucketKeyPreflistResp}, encoded...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cmd.onSuccess(msg); err != nil {
+		t.Fatal(err)
+	}
+	want := []*PreflistItem{
+		{Partition: 0, Node: "riak@10.0.0.1", Primary: true},
+		{Partition: 1, Node: "riak@10.0.0.2", Primary: true},
+		{Partition: 2, Node: "riak@10.0.0.3", Primary: false},
+	}
+	if got := cmd.(*FetchPreflistCommand).Response.Preflist; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
 // SecondaryIndexQuery
 
 func TestBuildRpbIndexReqCorrectlyViaBuilder(t *testing.T) {
@@ -1783,3 +2537,198 @@ func TestParseRpbMapRedRespCorrectlyWithStreaming(t *testing.T) {
 		t.Error(err.Error())
 	}
 }
+
+func multiPhaseMapRedResps() []*rpbRiakKV.RpbMapRedResp {
+	// NB: phase 0 emits no output, phase 1 is streamed in two parts
+	return []*rpbRiakKV.RpbMapRedResp{
+		{Phase: proto.Uint32(1), Response: []byte(`["a","b"]`)},
+		{Phase: proto.Uint32(2), Response: []byte(`[3]`)},
+		{Phase: proto.Uint32(1), Response: []byte(`["c"]`)},
+		{Done: proto.Bool(true)},
+	}
+}
+
+func TestParseMultiPhaseRpbMapRedRespGroupsByPhase(t *testing.T) {
+	cmd, err := NewMapReduceCommandBuilder().WithQuery("some query").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, resp := range multiPhaseMapRedResps() {
+		if err = cmd.onSuccess(resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mr := cmd.(*MapReduceCommand)
+	if !mr.isDone() {
+		t.Error("expected command to be done")
+	}
+	if got, want := len(mr.Response), 3; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	want := map[uint32][][]byte{
+		1: {[]byte(`["a","b"]`), []byte(`["c"]`)},
+		2: {[]byte(`[3]`)},
+	}
+	if got := mr.PhaseResponses; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, ok := mr.PhaseResponses[0]; ok {
+		t.Error("expected no responses for phase 0")
+	}
+}
+
+func TestParseMultiPhaseRpbMapRedRespWithPhaseCallback(t *testing.T) {
+	type partial struct {
+		phase    uint32
+		response string
+	}
+	var partials []partial
+	cmd, err := NewMapReduceCommandBuilder().
+		WithQuery("some query").
+		WithContentType("application/x-erlang-binary").
+		WithStreaming(true).
+		WithPhaseCallback(func(phase uint32, response []byte) error {
+			partials = append(partials, partial{phase, string(response)})
+			return nil
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := cmd.constructPbRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(req.(*rpbRiakKV.RpbMapRedReq).GetContentType()), "application/x-erlang-binary"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, resp := range multiPhaseMapRedResps() {
+		if err = cmd.onSuccess(resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []partial{{1, `["a","b"]`}, {2, `[3]`}, {1, `["c"]`}}
+	if got := partials; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	mr := cmd.(*MapReduceCommand)
+	if mr.Response != nil || mr.PhaseResponses != nil {
+		t.Error("expected nil results when streaming")
+	}
+}
+
+// MapReduce
+// RpbMapRedReq
+
+func TestMapReduceMaxRequestSize(t *testing.T) {
+	query := `{"inputs":[["b","k1"],["b","k2"]],"query":[{"map":{"language":"erlang","module":"riak_kv_mapreduce","function":"map_object_value"}}]}`
+	_, err := NewMapReduceCommandBuilder().
+		WithQuery(query).
+		WithMaxRequestSize(len(query) - 1).
+		Build()
+	if err == nil {
+		t.Fatal("expected non-nil error")
+	}
+	if _, err = NewMapReduceCommandBuilder().WithQuery(query).WithMaxRequestSize(len(query)).Build(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMapReduceBuildSplit(t *testing.T) {
+	query := `{"inputs":[["b","k1"],["b","k2"],["b","k3"],["b","k4"],["b","k5"]],"query":[{"map":{"language":"erlang","module":"riak_kv_mapreduce","function":"map_object_value"}}]}`
+	cmds, err := NewMapReduceCommandBuilder().WithQuery(query).BuildSplit(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(cmds), 3; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	expectedInputs := [][][]string{
+		{{"b", "k1"}, {"b", "k2"}},
+		{{"b", "k3"}, {"b", "k4"}},
+		{{"b", "k5"}},
+	}
+	for i, cmd := range cmds {
+		var q struct {
+			Inputs [][]string
+			Query  []map[string]interface{}
+		}
+		if err := json.Unmarshal(cmd.(*MapReduceCommand).protobuf.Request, &q); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := q.Inputs, expectedInputs[i]; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := len(q.Query), 1; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+
+	bucketQuery := `{"inputs":"b","query":[{"map":{"language":"erlang","module":"riak_kv_mapreduce","function":"map_object_value"}}]}`
+	if _, err := NewMapReduceCommandBuilder().WithQuery(bucketQuery).BuildSplit(2); err == nil {
+		t.Error("expected non-nil error")
+	}
+}
+
+// CountKeys
+// RpbMapRedReq
+
+func TestBuildCountKeysCommand(t *testing.T) {
+	if _, err := NewCountKeysCommandBuilder().WithBucket("bucket").Build(); err != ErrListingDisabled {
+		t.Errorf("got %v, want %v", err, ErrListingDisabled)
+	}
+	if _, err := NewCountKeysCommandBuilder().WithAllowListing().Build(); err != ErrBucketRequired {
+		t.Errorf("got %v, want %v", err, ErrBucketRequired)
+	}
+
+	cmd, err := NewCountKeysCommandBuilder().
+		WithAllowListing().
+		WithBucketType("bucket_type").
+		WithBucket("bucket").
+		WithTimeout(time.Second * 30).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var q struct {
+		Inputs  []string
+		Timeout uint32
+		Query   []map[string]map[string]string
+	}
+	if err := json.Unmarshal(cmd.(*CountKeysCommand).protobuf.Request, &q); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.Inputs, []string{"bucket_type", "bucket"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := q.Timeout, uint32(30000); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := q.Query[0]["reduce"]["function"], "reduce_count_inputs"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCountKeysParsesRpbMapRedResp(t *testing.T) {
+	cmd, err := NewCountKeysCommandBuilder().
+		WithAllowListing().
+		WithBucket("bucket").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.onSuccess(&rpbRiakKV.RpbMapRedResp{Response: []byte("[42]")}); err != nil {
+		t.Fatal(err)
+	}
+	done := true
+	if err := cmd.onSuccess(&rpbRiakKV.RpbMapRedResp{Done: &done}); err != nil {
+		t.Fatal(err)
+	}
+	countKeysCommand := cmd.(*CountKeysCommand)
+	if !countKeysCommand.isDone() {
+		t.Error("expected command to be done")
+	}
+	if got, want := countKeysCommand.Response, uint64(42); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2015-present Basho Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package riak
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// AdaptiveTimeoutOptions enables computing each Command's request timeout from the latency
+// observed by a Node, rather than using the static RequestTimeout. The timeout is the 99th
+// percentile of recent latencies multiplied by Multiplier, bounded by MinTimeout and MaxTimeout.
+// A non-streaming Command that times out counts as a latency of the time it waited, so that the
+// timeout widens while Riak is slow. Until enough latencies have been observed the Node's
+// RequestTimeout is used
+type AdaptiveTimeoutOptions struct {
+	Multiplier float64       // default 4
+	MinTimeout time.Duration // default 100ms
+	MaxTimeout time.Duration // default is the Node's RequestTimeout
+	WindowSize uint16        // number of recent latencies to track, default 256
+}
+
+const (
+	defaultAdaptiveMultiplier = float64(4)
+	defaultAdaptiveMinTimeout = 100 * time.Millisecond
+	defaultAdaptiveWindowSize = uint16(256)
+	adaptiveMinSamples        = 16
+)
+
+type adaptiveTimeout struct {
+	multiplier float64
+	minTimeout time.Duration
+	maxTimeout time.Duration
+	latencies  *latencyWindow
+}
+
+func newAdaptiveTimeout(options *AdaptiveTimeoutOptions, requestTimeout time.Duration) *adaptiveTimeout {
+	a := &adaptiveTimeout{
+		multiplier: options.Multiplier,
+		minTimeout: options.MinTimeout,
+		maxTimeout: options.MaxTimeout,
+	}
+	if a.multiplier <= 0 {
+		a.multiplier = defaultAdaptiveMultiplier
+	}
+	if a.minTimeout == 0 {
+		a.minTimeout = defaultAdaptiveMinTimeout
+	}
+	if a.maxTimeout == 0 {
+		a.maxTimeout = requestTimeout
+	}
+	if a.maxTimeout < a.minTimeout {
+		a.maxTimeout = a.minTimeout
+	}
+	windowSize := options.WindowSize
+	if windowSize == 0 {
+		windowSize = defaultAdaptiveWindowSize
+	}
+	a.latencies = newLatencyWindow(windowSize)
+	return a
+}
+
+// timeout returns the adaptive timeout, or requestTimeout if too few latencies have been observed
+func (a *adaptiveTimeout) timeout(requestTimeout time.Duration) time.Duration {
+	p99, count := a.latencies.percentile(0.99)
+	if count < adaptiveMinSamples {
+		return requestTimeout
+	}
+	t := time.Duration(float64(p99) * a.multiplier)
+	if t < a.minTimeout {
+		return a.minTimeout
+	}
+	if t > a.maxTimeout {
+		return a.maxTimeout
+	}
+	return t
+}
+
+// observe records the latency of a Command, or the time waited for a response that timed out,
+// so that the timeout widens while Riak is slow
+func (a *adaptiveTimeout) observe(latency time.Duration) {
+	a.latencies.add(latency)
+}
+
+// latencyWindow keeps the most recent latencies in a ring buffer, and a sorted copy of them so
+// that a percentile is read without sorting for every Command
+type latencyWindow struct {
+	samples []time.Duration // in the order observed
+	sorted  []time.Duration // the same samples in ascending order
+	next    int
+	sync.Mutex
+}
+
+func newLatencyWindow(size uint16) *latencyWindow {
+	return &latencyWindow{
+		samples: make([]time.Duration, size),
+		sorted:  make([]time.Duration, 0, size),
+	}
+}
+
+func (w *latencyWindow) add(latency time.Duration) {
+	w.Lock()
+	defer w.Unlock()
+	if len(w.sorted) == len(w.samples) {
+		// NB: the window is full, so the oldest sample is replaced
+		oldest := w.samples[w.next]
+		i := sort.Search(len(w.sorted), func(i int) bool { return w.sorted[i] >= oldest })
+		w.sorted = append(w.sorted[:i], w.sorted[i+1:]...)
+	}
+	w.samples[w.next] = latency
+	w.next++
+	if w.next == len(w.samples) {
+		w.next = 0
+	}
+	i := sort.Search(len(w.sorted), func(i int) bool { return w.sorted[i] >= latency })
+	w.sorted = append(w.sorted, 0)
+	copy(w.sorted[i+1:], w.sorted[i:])
+	w.sorted[i] = latency
+}
+
+// percentile returns the p-th percentile (0 < p <= 1) of the window and the number of samples
+func (w *latencyWindow) percentile(p float64) (time.Duration, int) {
+	w.Lock()
+	defer w.Unlock()
+	count := len(w.sorted)
+	if count == 0 {
+		return 0, 0
+	}
+	idx := int(float64(count)*p+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= count {
+		idx = count - 1
+	}
+	return w.sorted[idx], count
+}
@@ -0,0 +1,132 @@
+// Copyright 2015-present Basho Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package riak
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestLatencyWindowPercentile(t *testing.T) {
+	w := newLatencyWindow(100)
+	if p, count := w.percentile(0.99); p != 0 || count != 0 {
+		t.Errorf("expected empty window, got %v %v", p, count)
+	}
+	for i := 1; i <= 100; i++ {
+		w.add(time.Duration(i) * time.Millisecond)
+	}
+	p99, count := w.percentile(0.99)
+	if got, want := count, 100; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := p99, 99*time.Millisecond; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// oldest samples roll out of the window
+	for i := 0; i < 100; i++ {
+		w.add(time.Millisecond)
+	}
+	if p99, _ = w.percentile(0.99); p99 != time.Millisecond {
+		t.Errorf("got %v, want %v", p99, time.Millisecond)
+	}
+}
+
+func TestLatencyWindowStaysSortedAsSamplesRollOut(t *testing.T) {
+	w := newLatencyWindow(8)
+	latencies := []time.Duration{5, 3, 9, 3, 1, 7, 7, 2, 8, 4, 6, 1, 9, 5}
+	for i, latency := range latencies {
+		w.add(latency)
+		start := 0
+		if i >= 8 {
+			start = i - 7
+		}
+		want := append([]time.Duration(nil), latencies[start:i+1]...)
+		sort.Slice(want, func(i, j int) bool { return want[i] < want[j] })
+		if !reflect.DeepEqual(w.sorted, want) {
+			t.Fatalf("after %v samples got %v, want %v", i+1, w.sorted, want)
+		}
+	}
+}
+
+func TestAdaptiveTimeoutIsBounded(t *testing.T) {
+	opts := &AdaptiveTimeoutOptions{
+		Multiplier: 2,
+		MinTimeout: 50 * time.Millisecond,
+		MaxTimeout: time.Second,
+	}
+	a := newAdaptiveTimeout(opts, fiveSeconds)
+
+	// too few samples, static timeout is used
+	a.observe(time.Millisecond)
+	if got, want := a.timeout(fiveSeconds), fiveSeconds; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for i := 0; i < adaptiveMinSamples; i++ {
+		a.observe(time.Millisecond)
+	}
+	if got, want := a.timeout(fiveSeconds), opts.MinTimeout; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for i := 0; i < int(defaultAdaptiveWindowSize); i++ {
+		a.observe(100 * time.Millisecond)
+	}
+	if got, want := a.timeout(fiveSeconds), 200*time.Millisecond; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for i := 0; i < int(defaultAdaptiveWindowSize); i++ {
+		a.observe(time.Second)
+	}
+	if got, want := a.timeout(fiveSeconds), opts.MaxTimeout; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestAdaptiveTimeoutDefaults(t *testing.T) {
+	node, err := NewNode(&NodeOptions{
+		RequestTimeout:  tenSeconds,
+		AdaptiveTimeout: &AdaptiveTimeoutOptions{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := node.cm.adaptiveTimeout
+	if a == nil {
+		t.Fatal("expected non-nil adaptive timeout")
+	}
+	if got, want := a.multiplier, defaultAdaptiveMultiplier; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := a.minTimeout, defaultAdaptiveMinTimeout; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := a.maxTimeout, tenSeconds; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := len(a.latencies.samples), int(defaultAdaptiveWindowSize); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if node, err = NewNode(nil); err != nil {
+		t.Fatal(err)
+	}
+	if node.cm.adaptiveTimeout != nil {
+		t.Error("expected adaptive timeout to be disabled by default")
+	}
+}
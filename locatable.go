@@ -21,6 +21,7 @@ import (
 type rpbLocatable interface {
 	GetType() []byte
 	SetType(bt []byte) // NB: bt == bucket type
+	BucketTypeIsRequired() bool
 	BucketIsRequired() bool
 	GetBucket() []byte
 	KeyIsRequired() bool
@@ -40,6 +41,9 @@ func validateLocatable(msg proto.Message) error {
 		}
 	}
 	if bucketType := l.GetType(); len(bucketType) == 0 {
+		if l.BucketTypeIsRequired() {
+			return ErrBucketTypeRequired
+		}
 		l.SetType([]byte(defaultBucketType))
 	}
 	return nil
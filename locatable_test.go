@@ -0,0 +1,215 @@
+// Copyright 2015-present Basho Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package riak
+
+import (
+	"testing"
+)
+
+type buildWithBucketType func(bucketType string) (Command, error)
+
+// commands for which the default bucket type is sent when no type is given
+var optionalBucketTypeBuilders = map[string]buildWithBucketType{
+	"FetchValue": func(bt string) (Command, error) {
+		return NewFetchValueCommandBuilder().WithBucketType(bt).WithBucket("b").WithKey("k").Build()
+	},
+	"StoreValue": func(bt string) (Command, error) {
+		return NewStoreValueCommandBuilder().WithBucketType(bt).WithBucket("b").WithContent(&Object{}).Build()
+	},
+	"DeleteValue": func(bt string) (Command, error) {
+		return NewDeleteValueCommandBuilder().WithBucketType(bt).WithBucket("b").WithKey("k").Build()
+	},
+	"ListBuckets": func(bt string) (Command, error) {
+		return NewListBucketsCommandBuilder().WithAllowListing().WithBucketType(bt).Build()
+	},
+	"ListKeys": func(bt string) (Command, error) {
+		return NewListKeysCommandBuilder().WithAllowListing().WithBucketType(bt).WithBucket("b").Build()
+	},
+	"FetchPreflist": func(bt string) (Command, error) {
+		return NewFetchPreflistCommandBuilder().WithBucketType(bt).WithBucket("b").WithKey("k").Build()
+	},
+	"SecondaryIndexQuery": func(bt string) (Command, error) {
+		return NewSecondaryIndexQueryCommandBuilder().WithBucketType(bt).WithBucket("b").WithIndexName("i_bin").WithIndexKey("k").Build()
+	},
+	"FetchBucketProps": func(bt string) (Command, error) {
+		return NewFetchBucketPropsCommandBuilder().WithBucketType(bt).WithBucket("b").Build()
+	},
+	"StoreBucketProps": func(bt string) (Command, error) {
+		return NewStoreBucketPropsCommandBuilder().WithBucketType(bt).WithBucket("b").Build()
+	},
+	"ResetBucket": func(bt string) (Command, error) {
+		return NewResetBucketCommandBuilder().WithBucketType(bt).WithBucket("b").Build()
+	},
+}
+
+// commands for which the protocol requires an explicit bucket type
+var requiredBucketTypeBuilders = map[string]buildWithBucketType{
+	"UpdateCounter": func(bt string) (Command, error) {
+		return NewUpdateCounterCommandBuilder().WithBucketType(bt).WithBucket("b").Build()
+	},
+	"FetchCounter": func(bt string) (Command, error) {
+		return NewFetchCounterCommandBuilder().WithBucketType(bt).WithBucket("b").WithKey("k").Build()
+	},
+	"UpdateSet": func(bt string) (Command, error) {
+		return NewUpdateSetCommandBuilder().WithBucketType(bt).WithBucket("b").Build()
+	},
+	"UpdateGSet": func(bt string) (Command, error) {
+		return NewUpdateGSetCommandBuilder().WithBucketType(bt).WithBucket("b").Build()
+	},
+	"FetchSet": func(bt string) (Command, error) {
+		return NewFetchSetCommandBuilder().WithBucketType(bt).WithBucket("b").WithKey("k").Build()
+	},
+	"UpdateMap": func(bt string) (Command, error) {
+		return NewUpdateMapCommandBuilder().WithBucketType(bt).WithBucket("b").WithMapOperation(&MapOperation{}).Build()
+	},
+	"FetchMap": func(bt string) (Command, error) {
+		return NewFetchMapCommandBuilder().WithBucketType(bt).WithBucket("b").WithKey("k").Build()
+	},
+	"UpdateHll": func(bt string) (Command, error) {
+		return NewUpdateHllCommandBuilder().WithBucketType(bt).WithBucket("b").Build()
+	},
+	"FetchHll": func(bt string) (Command, error) {
+		return NewFetchHllCommandBuilder().WithBucketType(bt).WithBucket("b").WithKey("k").Build()
+	},
+	"FetchBucketTypeProps": func(bt string) (Command, error) {
+		return NewFetchBucketTypePropsCommandBuilder().WithBucketType(bt).Build()
+	},
+	"StoreBucketTypeProps": func(bt string) (Command, error) {
+		return NewStoreBucketTypePropsCommandBuilder().WithBucketType(bt).Build()
+	},
+}
+
+type buildWithBucketAndKey func(bucket, key string) (Command, error)
+
+// KV and CRDT commands, and whether each requires a key
+var bucketAndKeyBuilders = map[string]struct {
+	build       buildWithBucketAndKey
+	keyRequired bool
+}{
+	"FetchValue": {func(b, k string) (Command, error) {
+		return NewFetchValueCommandBuilder().WithBucket(b).WithKey(k).Build()
+	}, true},
+	"StoreValue": {func(b, k string) (Command, error) {
+		return NewStoreValueCommandBuilder().WithBucket(b).WithKey(k).WithContent(&Object{}).Build()
+	}, false},
+	"DeleteValue": {func(b, k string) (Command, error) {
+		return NewDeleteValueCommandBuilder().WithBucket(b).WithKey(k).Build()
+	}, true},
+	"FetchPreflist": {func(b, k string) (Command, error) {
+		return NewFetchPreflistCommandBuilder().WithBucket(b).WithKey(k).Build()
+	}, true},
+	"UpdateCounter": {func(b, k string) (Command, error) {
+		return NewUpdateCounterCommandBuilder().WithBucketType("counters").WithBucket(b).WithKey(k).Build()
+	}, false},
+	"FetchCounter": {func(b, k string) (Command, error) {
+		return NewFetchCounterCommandBuilder().WithBucketType("counters").WithBucket(b).WithKey(k).Build()
+	}, true},
+	"UpdateSet": {func(b, k string) (Command, error) {
+		return NewUpdateSetCommandBuilder().WithBucketType("sets").WithBucket(b).WithKey(k).Build()
+	}, false},
+	"FetchSet": {func(b, k string) (Command, error) {
+		return NewFetchSetCommandBuilder().WithBucketType("sets").WithBucket(b).WithKey(k).Build()
+	}, true},
+	"UpdateMap": {func(b, k string) (Command, error) {
+		return NewUpdateMapCommandBuilder().WithBucketType("maps").WithBucket(b).WithKey(k).WithMapOperation(&MapOperation{}).Build()
+	}, false},
+	"FetchMap": {func(b, k string) (Command, error) {
+		return NewFetchMapCommandBuilder().WithBucketType("maps").WithBucket(b).WithKey(k).Build()
+	}, true},
+	"UpdateHll": {func(b, k string) (Command, error) {
+		return NewUpdateHllCommandBuilder().WithBucketType("hlls").WithBucket(b).WithKey(k).Build()
+	}, false},
+	"FetchHll": {func(b, k string) (Command, error) {
+		return NewFetchHllCommandBuilder().WithBucketType("hlls").WithBucket(b).WithKey(k).Build()
+	}, true},
+}
+
+func TestEmptyBucketIsRejected(t *testing.T) {
+	for name, b := range bucketAndKeyBuilders {
+		if _, err := b.build("", "key"); err != ErrBucketRequired {
+			t.Errorf("%s: got %v, want %v", name, err, ErrBucketRequired)
+		}
+	}
+}
+
+func TestEmptyKeyIsRejectedWhereRequired(t *testing.T) {
+	for name, b := range bucketAndKeyBuilders {
+		_, err := b.build("bucket", "")
+		if b.keyRequired && err != ErrKeyRequired {
+			t.Errorf("%s: got %v, want %v", name, err, ErrKeyRequired)
+		}
+		if !b.keyRequired && err != nil {
+			t.Errorf("%s: expected Riak to generate the key, got %v", name, err)
+		}
+	}
+}
+
+func requestBucketType(t *testing.T, cmd Command) []byte {
+	msg, err := cmd.constructPbRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, ok := msg.(rpbLocatable)
+	if !ok {
+		t.Fatalf("%T is not locatable", msg)
+	}
+	return l.GetType()
+}
+
+func TestEmptyBucketTypeIsSentAsDefault(t *testing.T) {
+	for name, build := range optionalBucketTypeBuilders {
+		cmd, err := build("")
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if got, want := string(requestBucketType(t, cmd)), defaultBucketType; got != want {
+			t.Errorf("%s: got %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestDefaultBucketTypeIsSent(t *testing.T) {
+	for name, build := range optionalBucketTypeBuilders {
+		cmd, err := build(defaultBucketType)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if got, want := string(requestBucketType(t, cmd)), defaultBucketType; got != want {
+			t.Errorf("%s: got %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestBucketTypeIsRequired(t *testing.T) {
+	for name, build := range requiredBucketTypeBuilders {
+		if _, err := build(""); err != ErrBucketTypeRequired {
+			t.Errorf("%s: got %v, want %v", name, err, ErrBucketTypeRequired)
+		}
+		cmd, err := build("bucket_type")
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		// NB: legacy counters do not carry a bucket type
+		if _, ok := cmd.(*UpdateCounterCommand); ok {
+			continue
+		}
+		if got, want := string(requestBucketType(t, cmd)), "bucket_type"; got != want {
+			t.Errorf("%s: got %v, want %v", name, got, want)
+		}
+	}
+}
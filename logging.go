@@ -35,7 +35,7 @@ func init() {
 }
 
 // SetLogger sets the standard logger used for
-// WARN and DEBUG (if enabled)
+// INFO, WARN and DEBUG (if enabled)
 func SetLogger(logger *log.Logger) {
 	stdLogger = logger
 }
@@ -59,6 +59,11 @@ func logDebugln(source string, v ...interface{}) {
 	}
 }
 
+// logInfo writes formatted string informational messages using Printf
+func logInfo(source, format string, v ...interface{}) {
+	stdLogger.Printf(fmt.Sprintf("[INFO] %s %s", source, format), v...)
+}
+
 // logWarn writes formatted string warning messages using Printf
 func logWarn(source, format string, v ...interface{}) {
 	stdLogger.Printf(fmt.Sprintf("[WARNING] %s %s", source, format), v...)
@@ -83,3 +88,58 @@ func logErr(source string, err error) {
 func logErrorln(source string, v ...interface{}) {
 	errLogger.Println("[ERROR]", source, v)
 }
+
+// Logger is implemented by types that receive the log messages of a Node or Cluster, for example
+// to route them to a structured logging library. Format and arguments are as for fmt.Printf.
+// Debug messages are passed to a Logger regardless of EnableDebugLogging
+type Logger interface {
+	Debugf(format string, v ...interface{})
+	Infof(format string, v ...interface{})
+	Warnf(format string, v ...interface{})
+	Errorf(format string, v ...interface{})
+}
+
+// scopedLogger writes to its Logger if one is set, otherwise to the package loggers
+type scopedLogger struct {
+	logger Logger
+}
+
+func (l scopedLogger) debug(source, format string, v ...interface{}) {
+	if l.logger == nil {
+		logDebug(source, format, v...)
+		return
+	}
+	l.logger.Debugf(source+" "+format, v...)
+}
+
+func (l scopedLogger) info(source, format string, v ...interface{}) {
+	if l.logger == nil {
+		logInfo(source, format, v...)
+		return
+	}
+	l.logger.Infof(source+" "+format, v...)
+}
+
+func (l scopedLogger) warn(source, format string, v ...interface{}) {
+	if l.logger == nil {
+		logWarn(source, format, v...)
+		return
+	}
+	l.logger.Warnf(source+" "+format, v...)
+}
+
+func (l scopedLogger) error(source, format string, v ...interface{}) {
+	if l.logger == nil {
+		logError(source, format, v...)
+		return
+	}
+	l.logger.Errorf(source+" "+format, v...)
+}
+
+func (l scopedLogger) err(source string, err error) {
+	if l.logger == nil {
+		logErr(source, err)
+		return
+	}
+	l.logger.Errorf("%s %v", source, err)
+}
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"fmt"
 	"log"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -21,6 +23,11 @@ func TestLog(t *testing.T) {
 			logError,
 			"[ERROR]",
 		},
+		{
+			SetLogger,
+			logInfo,
+			"[INFO]",
+		},
 		{
 			SetLogger,
 			logWarn,
@@ -104,3 +111,62 @@ func TestDebugDisabled(t *testing.T) {
 		t.Errorf("Debug was disabled but got %s", actual)
 	}
 }
+
+// testLogger is a Logger that records every message it receives
+type testLogger struct {
+	messages []string
+	sync.Mutex
+}
+
+func (l *testLogger) record(level, format string, v ...interface{}) {
+	l.Lock()
+	defer l.Unlock()
+	l.messages = append(l.messages, level+" "+fmt.Sprintf(format, v...))
+}
+
+func (l *testLogger) Debugf(format string, v ...interface{}) { l.record("debug", format, v...) }
+func (l *testLogger) Infof(format string, v ...interface{})  { l.record("info", format, v...) }
+func (l *testLogger) Warnf(format string, v ...interface{})  { l.record("warn", format, v...) }
+func (l *testLogger) Errorf(format string, v ...interface{}) { l.record("error", format, v...) }
+
+func (l *testLogger) recorded() []string {
+	l.Lock()
+	defer l.Unlock()
+	return append([]string(nil), l.messages...)
+}
+
+func TestScopedLogger(t *testing.T) {
+	EnableDebugLogging = true
+
+	buf := &bytes.Buffer{}
+	SetLogger(log.New(buf, "", 0))
+	SetErrorLogger(log.New(buf, "", 0))
+
+	tl := &testLogger{}
+	sl := scopedLogger{logger: tl}
+	sl.debug("[test]", "Hello %s!", "World")
+	sl.info("[test]", "Hello %s!", "World")
+	sl.warn("[test]", "Hello %s!", "World")
+	sl.error("[test]", "Hello %s!", "World")
+	sl.err("[test]", fmt.Errorf("Hello World!"))
+
+	want := []string{
+		"debug [test] Hello World!",
+		"info [test] Hello World!",
+		"warn [test] Hello World!",
+		"error [test] Hello World!",
+		"error [test] Hello World!",
+	}
+	if got := tl.recorded(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if buf.Len() > 0 {
+		t.Errorf("expected nothing written to the package loggers, got %s", buf.String())
+	}
+
+	// NB: without a Logger, messages go to the package loggers
+	scopedLogger{}.info("[test]", "Hello %s!", "World")
+	if got, want := buf.String(), "[INFO] [test] Hello World!\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -17,6 +17,7 @@ package riak
 import (
 	"fmt"
 	"reflect"
+	"time"
 
 	rpbRiak "github.com/basho/riak-go-client/rpb/riak"
 	proto "github.com/golang/protobuf/proto"
@@ -35,6 +36,7 @@ func (builder *PingCommandBuilder) Build() (Command, error) {
 type PingCommand struct {
 	commandImpl
 	retryableCommandImpl
+	duration time.Duration
 }
 
 // Name identifies this command
@@ -42,6 +44,16 @@ func (cmd *PingCommand) Name() string {
 	return cmd.getName("Ping")
 }
 
+// Duration returns the time between writing the ping to Riak and reading its response. It is
+// zero if the ping has not completed successfully
+func (cmd *PingCommand) Duration() time.Duration {
+	return cmd.duration
+}
+
+func (cmd *PingCommand) setDuration(duration time.Duration) {
+	cmd.duration = duration
+}
+
 func (cmd *PingCommand) getRequestCode() byte {
 	return rpbCode_RpbPingReq
 }
@@ -63,6 +75,24 @@ func (cmd *PingCommand) getResponseProtobufMessage() proto.Message {
 	return nil
 }
 
+// GetServerInfoCommandBuilder is the command builder required for GetServerInfoCommand. Since
+// the command returns the Riak version, it may be used as NodeOptions.HealthCheckBuilder to both
+// check that a node is reachable and that it is running Riak
+type GetServerInfoCommandBuilder struct {
+}
+
+// NewServerInfoHealthCheckBuilder returns a builder for NodeOptions.HealthCheckBuilder that checks
+// node health with a GetServerInfoCommand rather than the default Ping, so that a node is only
+// considered healthy once it answers a request
+func NewServerInfoHealthCheckBuilder() CommandBuilder {
+	return &GetServerInfoCommandBuilder{}
+}
+
+// Build validates the configuration options provided then builds the command
+func (builder *GetServerInfoCommandBuilder) Build() (Command, error) {
+	return &GetServerInfoCommand{}, nil
+}
+
 // GetServerInfoResponse contains the response data for Riak server information
 type GetServerInfoResponse struct {
 	Node          string
@@ -335,7 +365,7 @@ func NewFetchBucketTypePropsCommandBuilder() *FetchBucketTypePropsCommandBuilder
 	return builder
 }
 
-// WithBucketType sets the bucket-type to be used by the command. If omitted, 'default' is used
+// WithBucketType sets the bucket-type to be used by the command. A bucket-type is required
 func (builder *FetchBucketTypePropsCommandBuilder) WithBucketType(bucketType string) *FetchBucketTypePropsCommandBuilder {
 	builder.protobuf.Type = []byte(bucketType)
 	return builder
@@ -520,7 +550,7 @@ func NewStoreBucketTypePropsCommandBuilder() *StoreBucketTypePropsCommandBuilder
 	return builder
 }
 
-// WithBucketType sets the bucket-type to be used by the command. If omitted, 'default' is used
+// WithBucketType sets the bucket-type to be used by the command. A bucket-type is required
 func (builder *StoreBucketTypePropsCommandBuilder) WithBucketType(bucketType string) *StoreBucketTypePropsCommandBuilder {
 	builder.protobuf.Type = []byte(bucketType)
 	return builder
@@ -700,6 +730,14 @@ func (builder *StoreBucketTypePropsCommandBuilder) WithHllPrecision(precision ui
 	return builder
 }
 
+// WithDataType sets the data type of the bucket type, one of counter, set, gset, map or hll.
+// NOTE: Riak only accepts a data type before the bucket type is activated, and it may not be
+// changed afterwards.
+func (builder *StoreBucketTypePropsCommandBuilder) WithDataType(dataType string) *StoreBucketTypePropsCommandBuilder {
+	builder.props.Datatype = []byte(dataType)
+	return builder
+}
+
 // Build validates the configuration options provided then builds the command
 func (builder *StoreBucketTypePropsCommandBuilder) Build() (Command, error) {
 	if builder.protobuf == nil {
@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	rpbRiak "github.com/basho/riak-go-client/rpb/riak"
+	proto "github.com/golang/protobuf/proto"
 )
 
 func buildRpbGetBucketResp() *rpbRiak.RpbGetBucketResp {
@@ -465,6 +466,37 @@ func TestBuildRpbSetBucketTypeReqCorrectlyViaBuilder(t *testing.T) {
 	}
 }
 
+func TestStoreBucketTypePropsWithDataType(t *testing.T) {
+	cmd, err := NewStoreBucketTypePropsCommandBuilder().
+		WithBucketType("maps").
+		WithDataType("map").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := getRiakMessage(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// NB: skip the length and message code
+	if got, want := data[4], rpbCode_RpbSetBucketTypeReq; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	req := &rpbRiak.RpbSetBucketTypeReq{}
+	if err = proto.Unmarshal(data[5:], req); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(req.GetType()), "maps"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	want := &rpbRiak.RpbBucketProps{
+		Datatype: []byte("map"),
+	}
+	if got := req.GetProps(); !proto.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
 func TestParseRpbStoreBucketTypeRespCorrectly(t *testing.T) {
 	builder := NewStoreBucketTypePropsCommandBuilder()
 	cmd, err := builder.
@@ -562,6 +594,36 @@ func TestBuildRpbSetBucketReqCorrectlyViaBuilder(t *testing.T) {
 	}
 }
 
+func TestStoreBucketPropsOnlySendsSetFields(t *testing.T) {
+	cmd, err := NewStoreBucketPropsCommandBuilder().
+		WithBucket("bucket").
+		WithAllowMult(true).
+		WithNVal(3).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := getRiakMessage(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// NB: skip the length and message code
+	if got, want := data[4], rpbCode_RpbSetBucketReq; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	req := &rpbRiak.RpbSetBucketReq{}
+	if err = proto.Unmarshal(data[5:], req); err != nil {
+		t.Fatal(err)
+	}
+	want := &rpbRiak.RpbBucketProps{
+		NVal:      proto.Uint32(3),
+		AllowMult: proto.Bool(true),
+	}
+	if got := req.GetProps(); !proto.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
 func TestParseRpbStoreBucketRespCorrectly(t *testing.T) {
 	builder := NewStoreBucketPropsCommandBuilder()
 	cmd, err := builder.
@@ -623,3 +685,81 @@ func TestBuildRpbResetBucketReqCorrectlyViaBuilder(t *testing.T) {
 		t.Errorf("ok: %v - could not convert %v to *rpbRiak.RpbResetBucketReq", ok, reflect.TypeOf(protobuf))
 	}
 }
+
+func TestResetBucketWireBytes(t *testing.T) {
+	cmd, err := NewResetBucketCommandBuilder().
+		WithBucketType("btype").
+		WithBucket("bucket").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := getRiakMessage(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0x00, 0x00, 0x00, 0x10, // length
+		rpbCode_RpbResetBucketReq,
+		0x0a, 0x06, 'b', 'u', 'c', 'k', 'e', 't', // bucket
+		0x12, 0x05, 'b', 't', 'y', 'p', 'e', // type
+	}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("got %v, want %v", data, want)
+	}
+
+	// RpbResetBucketResp has no body
+	msg, err := decodeRiakMessage(cmd, []byte{rpbCode_RpbResetBucketResp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cmd.onSuccess(msg); err != nil {
+		t.Fatal(err)
+	}
+	if !cmd.Success() {
+		t.Error("expected an empty response to be successful")
+	}
+}
+
+func TestGetServerInfoSendsEmptyRequest(t *testing.T) {
+	var builder CommandBuilder = &GetServerInfoCommandBuilder{}
+	cmd, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := getRiakMessage(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := data, []byte{0, 0, 0, 1, rpbCode_RpbGetServerInfoReq}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDecodeGetServerInfoResp(t *testing.T) {
+	cmd, err := (&GetServerInfoCommandBuilder{}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := proto.Marshal(&rpbRiak.RpbGetServerInfoResp{
+		Node:          []byte("riak@127.0.0.1"),
+		ServerVersion: []byte("2.2.3"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := decodeRiakMessage(cmd, append([]byte{rpbCode_RpbGetServerInfoResp}, encoded...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cmd.onSuccess(msg); err != nil {
+		t.Fatal(err)
+	}
+	if !cmd.Success() {
+		t.Error("expected success")
+	}
+	want := &GetServerInfoResponse{Node: "riak@127.0.0.1", ServerVersion: "2.2.3"}
+	if got := cmd.(*GetServerInfoCommand).Response; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -15,7 +15,12 @@
 package riak
 
 import (
+	"io"
 	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
 )
 
 func isTemporaryNetError(err error) bool {
@@ -25,3 +30,38 @@ func isTemporaryNetError(err error) bool {
 		return false
 	}
 }
+
+func isTimeoutNetError(err error) bool {
+	nerr, ok := err.(net.Error)
+	return ok && nerr.Timeout()
+}
+
+// isClosedConnectionError returns true if err shows that the other end closed the connection,
+// as Riak does with connections that have been idle for too long or when it restarts
+func isClosedConnectionError(err error) bool {
+	if err == io.EOF {
+		return true
+	}
+	if operr, ok := err.(*net.OpError); ok {
+		err = operr.Err
+	}
+	if syserr, ok := err.(*os.SyscallError); ok {
+		err = syserr.Err
+	}
+	return err == syscall.ECONNRESET || err == syscall.EPIPE
+}
+
+// withDefaultPort returns address with port appended when it does not already specify one.
+// Hostnames, IPv4 addresses and IPv6 literals, with or without brackets, are accepted. An
+// unbracketed IPv6 literal is never taken to include a port. Any other address is returned
+// unchanged, so that resolving it reports the error
+func withDefaultPort(address string, port uint16) string {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(address, "["), "]")
+	if strings.Contains(host, ":") && net.ParseIP(host) == nil {
+		return address
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(port)))
+}
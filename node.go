@@ -15,9 +15,13 @@
 package riak
 
 import (
+	"context"
 	"fmt"
 	"net"
+	"sync"
 	"time"
+
+	backoff "github.com/basho/backoff"
 )
 
 // Constants identifying Node state
@@ -30,29 +34,161 @@ const (
 	nodeError
 )
 
+// NodeStateChange describes a Node moving from one state to another, see NodeOptions.StateChanges
+type NodeStateChange struct {
+	Address  string
+	OldState string
+	NewState string
+}
+
+// ErrNodeHealthCheckFailed is returned by CheckHealth when the health check Command does not succeed
+var ErrNodeHealthCheckFailed = newClientError("[Node] health check did not succeed", nil)
+
+// ErrNodeNoConnections is returned when starting a Node none of whose connections could be
+// established. The Node is health checking rather than running, and runs once Riak is reachable
+var ErrNodeNoConnections = newClientError("[Node] could not establish any connection, health checking", nil)
+
+const (
+	ErrNodeCannotResolveAddress     = "[Node] could not resolve RemoteAddress '%s', expected host, host:port or [IPv6]:port"
+	ErrNodeMinConnectionsExceedsMax = "[Node] MinConnections (%d) must not be greater than MaxConnections (%d)"
+)
+
 // NodeOptions defines the RemoteAddress and operational configuration for connections to a Riak KV
 // instance
 type NodeOptions struct {
+	// RemoteAddress is a hostname, IPv4 address or IPv6 literal, such as "[::1]:8087", with an
+	// optional port. Port 8087 is used when none is given
 	RemoteAddress       string
 	MinConnections      uint16
 	MaxConnections      uint16
 	TempNetErrorRetries uint16
-	IdleTimeout         time.Duration
+	// ConnectionErrorRetries is the number of times a retryable Command is retried on a new
+	// connection when its connection fails, before the Node is health checked. Commands are
+	// not retried after Riak errors. Default is 0
+	ConnectionErrorRetries uint16
+	// RetryPolicy, if set, decides instead of ConnectionErrorRetries whether a retryable Command
+	// is retried on a new connection after its connection fails, and how long to wait first
+	RetryPolicy RetryPolicy
+	IdleTimeout time.Duration
+	// IdleExpirationInterval is how often connections idle for longer than IdleTimeout are
+	// closed, down to MinConnections. Default is 5 seconds
+	IdleExpirationInterval time.Duration
+	// MaxIdleExpirations is the maximum number of idle connections closed every
+	// IdleExpirationInterval, so that the pool drains gradually. Default is 0, no maximum
+	MaxIdleExpirations  uint16
 	ConnectTimeout      time.Duration
 	RequestTimeout      time.Duration
 	HealthCheckInterval time.Duration
-	HealthCheckBuilder  CommandBuilder
-	AuthOptions         *AuthOptions
+	// MaxHealthCheckInterval bounds the interval between health checks, which widens
+	// exponentially from HealthCheckInterval while a node remains down
+	MaxHealthCheckInterval time.Duration
+	// HealthCheckBuilder builds the Command used to check the health of a node. Default is a
+	// Ping, see also NewServerInfoHealthCheckBuilder. Build is called for every health check, and
+	// health checks may run concurrently, so it must return a new Command each time
+	HealthCheckBuilder CommandBuilder
+	AuthOptions        *AuthOptions
+	AdaptiveTimeout    *AdaptiveTimeoutOptions // NB: if nil, RequestTimeout is always used
+	// ClientID, if set, is sent to Riak as the client id of each connection once it is
+	// established and authenticated, which older Riak versions use in vclocks
+	ClientID []byte
+	// StatsLogInterval is the interval at which a one-line summary of the connection pool is
+	// logged while the Node is running. If 0, the summary is not logged
+	StatsLogInterval time.Duration
+	// KeepAlive is the TCP keep-alive period of connections to Riak. Default is 30 seconds, a
+	// negative value disables keep-alive
+	KeepAlive time.Duration
+	// NoDelay disables Nagle's algorithm on connections to Riak. If nil, it is disabled, since
+	// Riak protocol messages are small
+	NoDelay *bool
+	// Dialer, if set, is used to establish connections instead of dialing TCP directly, for
+	// example to connect via a proxy. It is responsible for its own timeout, as ConnectTimeout
+	// only applies to direct dialing. KeepAlive and NoDelay only apply if it returns a
+	// *net.TCPConn
+	Dialer func(network, address string) (net.Conn, error)
+	// MaxMessageSize is the largest message, in bytes, that will be read from Riak. A connection
+	// receiving a larger length prefix, which can only be due to corruption, fails the Command
+	// and is closed rather than allocating the buffer. Default is 256MiB
+	MaxMessageSize uint32
+	// StreamFrameTimeout is how long a streaming Command, such as ListKeys or MapReduce, waits
+	// for each response after the first, so that a stalled stream fails even though the whole
+	// operation may take far longer. The first response is awaited for the request timeout.
+	// If 0, every response is awaited for the request timeout
+	StreamFrameTimeout time.Duration
+	// MaxConnectionWait is how long a Command waits for a connection to be returned to the pool
+	// when MaxConnections are in use. If 0, the Command is not executed on this Node
+	MaxConnectionWait time.Duration
+	// DrainTimeout is how long stopping the Node waits for Commands in flight to complete. If they
+	// do not, stopping returns an error and their connections are closed once they complete.
+	// If 0, stopping does not wait
+	DrainTimeout time.Duration
+	// Logger, if set, receives the Node's log messages instead of the package loggers
+	Logger Logger
+	// StateChanges receives a NodeStateChange whenever the Node changes state, for example when
+	// it starts health checking after losing its connection to Riak. Changes are dropped if the
+	// channel is full, so that a slow receiver does not stall the Node
+	StateChanges chan<- NodeStateChange
+}
+
+// NodeStats is a snapshot of a Node's state and connection pool, as returned by Node.Stats
+type NodeStats struct {
+	State            string
+	TotalConnections uint16 // connections open to Riak
+	Available        uint16 // idle connections in the pool
+	InFlight         uint16 // connections executing a Command
+	MinConnections   uint16
+	MaxConnections   uint16
+	BytesSent        uint64 // bytes written to Riak by all connections
+	BytesReceived    uint64 // bytes read from Riak by all connections
+	CommandsExecuted uint64 // Commands executed, including health checks and authentication
+	CommandErrors    uint64 // Commands that completed with an error
+	// ReconnectAttempts is the number of times a health check has tried to reach Riak while
+	// the Node was down
+	ReconnectAttempts uint64
+	// LastHealthCheckSuccess is when a health check last succeeded, zero if none has
+	LastHealthCheckSuccess time.Time
+	// LastError is the most recent error that started a health check or caused one to fail
+	LastError error
+}
+
+// nodeHealth records health check outcomes for NodeStats
+type nodeHealth struct {
+	reconnectAttempts      uint64
+	lastHealthCheckSuccess time.Time
+	lastError              error
+	sync.Mutex
+}
+
+func (h *nodeHealth) attempted() {
+	h.Lock()
+	defer h.Unlock()
+	h.reconnectAttempts++
+}
+
+func (h *nodeHealth) failed(err error) {
+	h.Lock()
+	defer h.Unlock()
+	h.lastError = err
+}
+
+func (h *nodeHealth) succeeded(t time.Time) {
+	h.Lock()
+	defer h.Unlock()
+	h.lastHealthCheckSuccess = t
 }
 
 // Node is a struct that contains all of the information needed to connect and maintain connections
 // with a Riak KV instance
 type Node struct {
-	addr                *net.TCPAddr
-	healthCheckInterval time.Duration
-	healthCheckBuilder  CommandBuilder
-	stopChan            chan struct{}
-	cm                  *connectionManager
+	addr                   *net.TCPAddr
+	healthCheckInterval    time.Duration
+	maxHealthCheckInterval time.Duration
+	healthCheckBuilder     CommandBuilder
+	statsLogInterval       time.Duration
+	retryPolicy            RetryPolicy
+	stopChan               chan struct{}
+	cm                     *connectionManager
+	health                 nodeHealth
+	log                    scopedLogger
 	stateData
 }
 
@@ -66,14 +202,19 @@ var defaultNodeOptions = &NodeOptions{
 	RequestTimeout:      defaultRequestTimeout,
 }
 
-// NewNode is a factory function that takes a NodeOptions struct and returns a Node struct
-func NewNode(options *NodeOptions) (*Node, error) {
-	if options == nil {
-		options = defaultNodeOptions
+// NewNode is a factory function that takes a NodeOptions struct and returns a Node struct. The
+// NodeOptions are not modified
+func NewNode(nodeOptions *NodeOptions) (*Node, error) {
+	if nodeOptions == nil {
+		nodeOptions = defaultNodeOptions
 	}
+	// NB: defaults are applied to a copy, as the caller may share options between Nodes
+	opts := *nodeOptions
+	options := &opts
 	if options.RemoteAddress == "" {
 		options.RemoteAddress = defaultRemoteAddress
 	}
+	options.RemoteAddress = withDefaultPort(options.RemoteAddress, defaultRemotePort)
 	if options.MinConnections == 0 {
 		options.MinConnections = defaultMinConnections
 	}
@@ -95,39 +236,101 @@ func NewNode(options *NodeOptions) (*Node, error) {
 	if options.HealthCheckInterval == 0 {
 		options.HealthCheckInterval = defaultHealthCheckInterval
 	}
+	if options.MaxHealthCheckInterval == 0 {
+		options.MaxHealthCheckInterval = defaultMaxHealthCheckInterval
+	}
+	if options.MaxHealthCheckInterval < options.HealthCheckInterval {
+		options.MaxHealthCheckInterval = options.HealthCheckInterval
+	}
+	if options.RetryPolicy == nil {
+		options.RetryPolicy = &ExponentialBackoff{MaxAttempts: int(options.ConnectionErrorRetries) + 1}
+	}
+	if options.MinConnections > options.MaxConnections {
+		return nil, newClientError(fmt.Sprintf(ErrNodeMinConnectionsExceedsMax, options.MinConnections, options.MaxConnections), nil)
+	}
 
 	var err error
+	authOptions := options.AuthOptions
+	if authOptions != nil {
+		if authOptions, err = authOptions.withServerName(options.RemoteAddress); err != nil {
+			return nil, err
+		}
+	}
+
 	var resolvedAddress *net.TCPAddr
 	resolvedAddress, err = net.ResolveTCPAddr("tcp", options.RemoteAddress)
-	if err == nil {
-		n := &Node{
-			stopChan:            make(chan struct{}),
-			addr:                resolvedAddress,
-			healthCheckInterval: options.HealthCheckInterval,
-			healthCheckBuilder:  options.HealthCheckBuilder,
-		}
+	if err != nil {
+		return nil, newClientError(fmt.Sprintf(ErrNodeCannotResolveAddress, options.RemoteAddress), err)
+	}
+	n := &Node{
+		stopChan:               make(chan struct{}),
+		addr:                   resolvedAddress,
+		healthCheckInterval:    options.HealthCheckInterval,
+		maxHealthCheckInterval: options.MaxHealthCheckInterval,
+		healthCheckBuilder:     options.HealthCheckBuilder,
+		statsLogInterval:       options.StatsLogInterval,
+		retryPolicy:            options.RetryPolicy,
+		log:                    scopedLogger{logger: options.Logger},
+	}
 
-		connMgrOpts := &connectionManagerOptions{
-			addr:                resolvedAddress,
-			minConnections:      options.MinConnections,
-			maxConnections:      options.MaxConnections,
-			tempNetErrorRetries: options.TempNetErrorRetries,
-			idleTimeout:         options.IdleTimeout,
-			connectTimeout:      options.ConnectTimeout,
-			requestTimeout:      options.RequestTimeout,
-			authOptions:         options.AuthOptions,
-		}
+	var at *adaptiveTimeout
+	if options.AdaptiveTimeout != nil {
+		at = newAdaptiveTimeout(options.AdaptiveTimeout, options.RequestTimeout)
+	}
 
-		var cm *connectionManager
-		if cm, err = newConnectionManager(connMgrOpts); err == nil {
-			n.cm = cm
-			n.initStateData("nodeCreated", "nodeRunning", "nodeHealthChecking", "nodeShuttingDown", "nodeShutdown", "nodeError")
-			n.setState(nodeCreated)
-			return n, nil
-		}
+	connMgrOpts := &connectionManagerOptions{
+		addr:                   resolvedAddress,
+		minConnections:         options.MinConnections,
+		maxConnections:         options.MaxConnections,
+		tempNetErrorRetries:    options.TempNetErrorRetries,
+		idleTimeout:            options.IdleTimeout,
+		idleExpirationInterval: options.IdleExpirationInterval,
+		maxIdleExpirations:     options.MaxIdleExpirations,
+		connectTimeout:         options.ConnectTimeout,
+		requestTimeout:         options.RequestTimeout,
+		streamFrameTimeout:     options.StreamFrameTimeout,
+		authOptions:            authOptions,
+		clientID:               options.ClientID,
+		adaptiveTimeout:        at,
+		keepAlive:              options.KeepAlive,
+		noDelay:                options.NoDelay,
+		dialer:                 options.Dialer,
+		maxMessageSize:         options.MaxMessageSize,
+		maxConnectionWait:      options.MaxConnectionWait,
+		drainTimeout:           options.DrainTimeout,
+	}
+
+	if n.cm, err = newConnectionManager(connMgrOpts); err != nil {
+		return nil, err
+	}
+	n.initStateData("nodeCreated", "nodeRunning", "nodeHealthChecking", "nodeShuttingDown", "nodeShutdown", "nodeError")
+	n.setState(nodeCreated)
+	if options.StateChanges != nil {
+		n.setStateFunc = n.stateChangeFunc(options.StateChanges)
 	}
+	return n, nil
+}
 
-	return nil, err
+// stateChangeFunc returns a setStateFunc that sends every change of state to stateChanges
+// without blocking
+func (n *Node) stateChangeFunc(stateChanges chan<- NodeStateChange) func(sd *stateData, st state) {
+	return func(sd *stateData, st state) {
+		old := sd.stateVal
+		defaultSetStateFunc(sd, st)
+		if old == st {
+			return
+		}
+		change := NodeStateChange{
+			Address:  n.addr.String(),
+			OldState: sd.describe(old),
+			NewState: sd.describe(st),
+		}
+		select {
+		case stateChanges <- change:
+		default:
+			n.log.debug("[Node]", "(%v) dropped state change %v -> %v, channel is full", n.addr, change.OldState, change.NewState)
+		}
+	}
 }
 
 // String returns a formatted string including the remoteAddress for the Node and its current
@@ -143,12 +346,22 @@ func (n *Node) start() error {
 		return err
 	}
 
-	logDebug("[Node]", "(%v) starting", n)
-	if err := n.cm.start(); err != nil {
-		logErr("[Node]", err)
+	n.log.debug("[Node]", "(%v) starting", n)
+	err := n.cm.start()
+	if n.statsLogInterval > 0 {
+		go n.logStats()
+	}
+	if err == ErrConnMgrNoConnections {
+		// NB: a node that is up but has no connections is not usable, so health check instead
+		n.log.warn("[Node]", "(%v) %v", n, err)
+		n.doHealthCheck(err)
+		return ErrNodeNoConnections
+	}
+	if err != nil {
+		n.log.err("[Node]", err)
 	}
 	n.setState(nodeRunning)
-	logDebug("[Node]", "(%v) started", n)
+	n.log.debug("[Node]", "(%v) started", n)
 
 	return nil
 }
@@ -156,40 +369,199 @@ func (n *Node) start() error {
 // Stop closes the connections with Riak at the configured remoteAddress and removes the connections
 // from the active pool
 func (n *Node) stop() error {
-	if err := n.stateCheck(nodeRunning, nodeHealthChecking); err != nil {
+	if err := n.stateCheck(nodeCreated, nodeRunning, nodeHealthChecking); err != nil {
 		return err
 	}
 
-	logDebug("[Node]", "(%v) shutting down.", n)
+	n.log.debug("[Node]", "(%v) shutting down.", n)
 
 	n.setState(nodeShuttingDown)
 	close(n.stopChan)
 
 	err := n.cm.stop()
 
-	if err == nil {
+	if n.cm.isCurrentState(cmShutdown) {
+		// NB: err may contain errors from closing connections, which do not prevent shutdown
 		n.setState(nodeShutdown)
-		logDebug("[Node]", "(%v) shut down.", n)
+		n.log.debug("[Node]", "(%v) shut down.", n)
 	} else {
 		n.setState(nodeError)
-		logErr("[Node]", err)
+	}
+	if err != nil {
+		n.log.err("[Node]", err)
 	}
 
 	return err
 }
 
+// RecyclePool closes all of the Node's connections and re-establishes MinConnections, which is
+// useful after a known event such as a Riak restart. In-flight Commands are given up to
+// drainTimeout to complete, and connections still in use after that are closed once their
+// Command completes. The Node continues to execute Commands while its pool is recycled.
+// The number of connections closed is returned
+func (n *Node) RecyclePool(drainTimeout time.Duration) (uint16, error) {
+	if err := n.stateCheck(nodeRunning); err != nil {
+		return 0, err
+	}
+	recycled, err := n.cm.recycle(drainTimeout)
+	if err != nil {
+		n.log.err("[Node]", err)
+	}
+	return recycled, err
+}
+
+// Stats returns a snapshot of the Node's state and connection pool. It is safe to call
+// concurrently with Command execution, for example to export metrics
+func (n *Node) Stats() NodeStats {
+	// NB: connections may be taken from or returned to the pool between these reads
+	total, available := n.cm.count(), n.cm.q.count()
+	if available > total {
+		available = total
+	}
+	cs := n.cm.stats.snapshot()
+	n.health.Lock()
+	defer n.health.Unlock()
+	return NodeStats{
+		State:            n.stateData.String(),
+		TotalConnections: total,
+		Available:        available,
+		InFlight:         total - available,
+		MinConnections:   n.cm.minConnections,
+		MaxConnections:   n.cm.maxConnections,
+		BytesSent:        cs.bytesSent,
+		BytesReceived:    cs.bytesReceived,
+		CommandsExecuted: cs.commandsExecuted,
+		CommandErrors:    cs.errors,
+
+		ReconnectAttempts:      n.health.reconnectAttempts,
+		LastHealthCheckSuccess: n.health.lastHealthCheckSuccess,
+		LastError:              n.health.lastError,
+	}
+}
+
+// CheckHealth synchronously executes the Node's health check Command on a new connection and
+// returns its result. Unlike the background health check, neither the Node's state nor its
+// connection pool are affected, so this may be used to actively probe Riak, for example from a
+// readiness endpoint. ctx bounds both connecting and executing the health check
+func (n *Node) CheckHealth(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	conn, err := n.cm.createConnectionContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.close()
+
+	hcmd := n.getHealthCheckCommand()
+	n.log.debug("[Node]", "(%v) on-demand healthcheck executing %v", n, hcmd.Name())
+	if err = conn.executeContext(ctx, hcmd); err != nil {
+		return err
+	}
+	if !hcmd.Success() {
+		return ErrNodeHealthCheckFailed
+	}
+	return nil
+}
+
+// ExecuteContext retrieves an available connection from the pool and executes the Command against
+// Riak, abandoning it if ctx is cancelled or reaches its deadline, in which case ctx.Err() is
+// returned. The deadline of ctx, if earlier than the request timeout, bounds waiting for Riak.
+// The bool result is false if the Node was not able to execute the Command, for example as it is
+// health checking, which allows a custom NodeManager to try another Node
+func (n *Node) ExecuteContext(ctx context.Context, cmd Command) (bool, error) {
+	return n.executeContext(ctx, cmd)
+}
+
+// BatchResult contains the outcome of a single Command executed via ExecuteBatch. Executed is
+// false for a Command that was not sent to Riak, as the batch could not start or was aborted
+type BatchResult struct {
+	Command  Command
+	Executed bool
+	Error    error
+}
+
+// ExecuteBatch retrieves one connection from the pool and executes commands against Riak one
+// after another on it, which saves retrieving a connection for each Command. Results are returned
+// in the same order as commands. An error returned by Riak fails only its own Command, but should
+// the connection fail, or be left unusable, the batch is aborted and the remaining Commands are
+// not executed. The error result is that of retrieving the connection or aborting the batch. When
+// the Node is not able to execute the batch, for example as it is health checking, no Command is
+// executed, which allows a Cluster to try another Node
+func (n *Node) ExecuteBatch(commands []Command) ([]*BatchResult, error) {
+	results := make([]*BatchResult, len(commands))
+	for i, cmd := range commands {
+		results[i] = &BatchResult{Command: cmd}
+	}
+	if err := n.stateCheck(nodeRunning, nodeHealthChecking); err != nil {
+		return results, err
+	}
+	if len(commands) == 0 || !n.isCurrentState(nodeRunning) {
+		return results, nil
+	}
+
+	conn, err := n.cm.get()
+	if err != nil {
+		n.log.err("[Node]", err)
+		if err != ErrConnMgrAllConnectionsInUse {
+			n.doHealthCheck(err)
+		}
+		return results, err
+	}
+
+	ctx := context.Background()
+	for i, cmd := range commands {
+		if rc, ok := cmd.(retryableCommand); ok {
+			rc.setLastNode(n)
+		}
+		if nc, ok := cmd.(nodeCommand); ok {
+			nc.setNode(n)
+		}
+		n.log.debug("[Node]", "(%v) - executing batched command '%v'", n, cmd.Name())
+		err = conn.executeContext(ctx, cmd)
+		results[i].Executed = true
+		results[i].Error = err
+		if err != nil && (isConnectionError(ctx, err) || !conn.available()) {
+			n.log.debug("[Node]", "(%v) - aborting batch after command '%v': %v", n, cmd.Name(), err)
+			if cmErr := n.cm.remove(conn); cmErr != nil {
+				n.log.err("[Node]", cmErr)
+			}
+			if isConnectionError(ctx, err) && !isTemporaryNetError(err) {
+				n.doHealthCheck(err)
+			}
+			return results, err
+		}
+	}
+	if cmErr := n.cm.put(conn); cmErr != nil {
+		n.log.err("[Node]", cmErr)
+	}
+	return results, nil
+}
+
 // Execute retrieves an available connection from the pool and executes the Command operation against
 // Riak
 func (n *Node) execute(cmd Command) (bool, error) {
+	return n.executeContext(context.Background(), cmd)
+}
+
+// executeContext is the same as execute, but will abandon the Command if ctx is cancelled or reaches
+// its deadline. The connection on which the Command was executing is closed and discarded in that case
+func (n *Node) executeContext(ctx context.Context, cmd Command) (bool, error) {
 	if err := n.stateCheck(nodeRunning, nodeHealthChecking); err != nil {
 		return false, err
 	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
 
 	if n.isCurrentState(nodeRunning) {
 		conn, err := n.cm.get()
 		if err != nil {
-			logErr("[Node]", err)
-			n.doHealthCheck()
+			n.log.err("[Node]", err)
+			if err != ErrConnMgrAllConnectionsInUse {
+				// NB: a new connection could not be created, a busy pool does not need checking
+				n.doHealthCheck(err)
+			}
 			return false, err
 		}
 
@@ -197,18 +569,74 @@ func (n *Node) execute(cmd Command) (bool, error) {
 			panic(fmt.Sprintf("[Node] (%v) expected non-nil connection", n))
 		}
 
-		if rc, ok := cmd.(retryableCommand); ok {
+		rc, retryable := cmd.(retryableCommand)
+		if retryable {
 			rc.setLastNode(n)
 		}
+		if nc, ok := cmd.(nodeCommand); ok {
+			nc.setNode(n)
+		}
 
-		logDebug("[Node]", "(%v) - executing command '%v'", n, cmd.Name())
-		err = conn.execute(cmd)
+		n.log.debug("[Node]", "(%v) - executing command '%v'", n, cmd.Name())
+		err = conn.executeContext(ctx, cmd)
+		if err != nil && isConnectionError(ctx, err) && conn.closedByPeer() {
+			// NB: Riak closed the pooled connection, e.g. after an idle timeout, and did not
+			// respond, so the Command is sent once more on a new connection without health checking
+			if cmErr := n.cm.remove(conn); cmErr != nil {
+				n.log.err("[Node]", cmErr)
+			}
+			var cerr error
+			if conn, cerr = n.cm.create(); cerr != nil || conn == nil {
+				n.log.debug("[Node]", "(%v) - could not create connection to replace one closed by Riak: %v", n, cerr)
+				if cerr != nil && cerr != ErrConnMgrAllConnectionsInUse {
+					n.doHealthCheck(cerr)
+				}
+				return true, err
+			}
+			n.log.debug("[Node]", "(%v) - connection closed by Riak, executing command '%v' on a new connection", n, cmd.Name())
+			cmd.onRetry()
+			err = conn.executeContext(ctx, cmd)
+		}
+		// NB: a pooled connection may have been closed by Riak, so a retryable Command is
+		// retried on new connections after connection errors, but never after Riak errors
+		for attempt := 1; err != nil && retryable && isConnectionError(ctx, err); attempt++ {
+			retry, backoff := n.retryPolicy.ShouldRetry(err, attempt)
+			if !retry {
+				break
+			}
+			if cmErr := n.cm.remove(conn); cmErr != nil {
+				n.log.err("[Node]", cmErr)
+			}
+			if serr := sleepContext(ctx, backoff); serr != nil {
+				return true, serr
+			}
+			var cerr error
+			if conn, cerr = n.cm.create(); cerr != nil || conn == nil {
+				n.log.debug("[Node]", "(%v) - could not create connection to retry command '%v': %v", n, cmd.Name(), cerr)
+				if cerr != nil && cerr != ErrConnMgrAllConnectionsInUse {
+					n.doHealthCheck(cerr)
+				} else if !isTemporaryNetError(err) {
+					n.doHealthCheck(err)
+				}
+				return true, err
+			}
+			n.log.debug("[Node]", "(%v) - retrying command '%v' on a new connection after error: %v", n, cmd.Name(), err)
+			cmd.onRetry()
+			err = conn.executeContext(ctx, cmd)
+		}
 		if err == nil {
 			// NB: basically the success path of _responseReceived in Node.js client
 			if cmErr := n.cm.put(conn); cmErr != nil {
-				logErr("[Node]", cmErr)
+				n.log.err("[Node]", cmErr)
 			}
 			return true, nil
+		} else if err == ctx.Err() {
+			// NB: command was abandoned and its socket closed, discard the connection
+			n.log.debug("[Node]", "(%v) - command '%v' abandoned: %v", n, cmd.Name(), err)
+			if cmErr := n.cm.remove(conn); cmErr != nil {
+				n.log.err("[Node]", cmErr)
+			}
+			return true, err
 		} else {
 			// NB: basically, this is _connectionClosed / _responseReceived in Node.js client
 			// must differentiate between Riak and non-Riak errors here and within execute() in connection
@@ -216,16 +644,16 @@ func (n *Node) execute(cmd Command) (bool, error) {
 			case RiakError, ClientError:
 				// Riak and Client errors will not close connection
 				if cmErr := n.cm.put(conn); cmErr != nil {
-					logErr("[Node]", cmErr)
+					n.log.err("[Node]", cmErr)
 				}
 				return true, err
 			default:
 				// NB: must be a non-Riak, non-Client error, close the connection
 				if cmErr := n.cm.remove(conn); cmErr != nil {
-					logErr("[Node]", cmErr)
+					n.log.err("[Node]", cmErr)
 				}
 				if !isTemporaryNetError(err) {
-					n.doHealthCheck()
+					n.doHealthCheck(err)
 				}
 				return true, err
 			}
@@ -235,20 +663,39 @@ func (n *Node) execute(cmd Command) (bool, error) {
 	}
 }
 
-func (n *Node) doHealthCheck() {
-	// NB: ensure we're not already healthchecking or shutting down
-	if n.isStateLessThan(nodeHealthChecking) {
-		n.setState(nodeHealthChecking)
+// isConnectionError returns true if err indicates that the connection failed, rather than that
+// Riak or the client rejected the Command
+func isConnectionError(ctx context.Context, err error) bool {
+	if err == ctx.Err() {
+		return false
+	}
+	switch err.(type) {
+	case RiakError, ClientError:
+		return false
+	default:
+		return true
+	}
+}
+
+// doHealthCheck starts health checking the Node, after cause, unless it is already
+func (n *Node) doHealthCheck(cause error) {
+	if cause != nil {
+		n.health.failed(cause)
+	}
+	// NB: ensure we're not already healthchecking or shutting down. Concurrent failures may
+	// all get here, but only one will change the state and start the health check
+	if n.setStateIfLessThan(nodeHealthChecking) {
 		go n.healthCheck()
 	} else {
-		logDebug("[Node]", "(%v) is already healthchecking or shutting down.", n)
+		n.log.debug("[Node]", "(%v) is already healthchecking or shutting down.", n)
 	}
 }
 
 func (n *Node) getHealthCheckCommand() (hc Command) {
 	// This is necessary to have a unique Command struct as part of each
 	// connection so that concurrent calls to check health can all have
-	// unique results
+	// unique results. Commands hold their results, e.g. PingCommand its
+	// success and duration, so must never be shared between health checks
 	var err error
 	if n.healthCheckBuilder != nil {
 		hc, err = n.healthCheckBuilder.Build()
@@ -257,17 +704,28 @@ func (n *Node) getHealthCheckCommand() (hc Command) {
 	}
 
 	if err != nil {
-		logErr("[Node]", err)
+		n.log.err("[Node]", err)
 		hc = &PingCommand{}
 	}
 
 	return
 }
 
+// logHealthCheckFailure logs at escalating severity so that a node that has been down for an
+// extended time is distinguishable from one that has just failed
+func (n *Node) logHealthCheckFailure(downSince time.Time, msg string, err error) {
+	downFor := time.Since(downSince)
+	if downFor < healthCheckEscalationPeriod {
+		n.log.warn("[Node]", "(%v) %s, down for %v, err: %v", n, msg, downFor, err)
+	} else {
+		n.log.error("[Node]", "(%v) %s, down for %v, err: %v", n, msg, downFor, err)
+	}
+}
+
 func (n *Node) ensureHealthCheckCanContinue() bool {
 	// ensure we ARE healthchecking
 	if !n.isCurrentState(nodeHealthChecking) {
-		logDebug("[Node]", "(%v) expected healthchecking state, got %s", n, n.stateData.String())
+		n.log.debug("[Node]", "(%v) expected healthchecking state, got %s", n, n.stateData.String())
 		return false
 	}
 	return true
@@ -275,11 +733,46 @@ func (n *Node) ensureHealthCheckCanContinue() bool {
 
 // private goroutine funcs
 
+func (n *Node) logStats() {
+	ticker := time.NewTicker(n.statsLogInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-n.stopChan:
+			return
+		case <-ticker.C:
+			n.log.info("[Node]", "(%v) %s", n.addr, n.statsSummary())
+		}
+	}
+}
+
+// statsSummary returns a one-line summary of the Node's state and connection pool
+func (n *Node) statsSummary() string {
+	s := n.Stats()
+	return fmt.Sprintf("state: %s, connections: %d, available: %d, in flight: %d, min: %d, max: %d",
+		s.State, s.TotalConnections, s.Available, s.InFlight, s.MinConnections, s.MaxConnections)
+}
+
+// newHealthCheckBackoff returns the delays between health checks. They double from
+// healthCheckInterval up to maxHealthCheckInterval while the node remains down, with jitter so
+// that many clients do not reconnect in step. Every health check run starts a new backoff, so
+// the delay is reset once the node recovers
+func (n *Node) newHealthCheckBackoff() *backoff.Backoff {
+	return &backoff.Backoff{
+		Min:    n.healthCheckInterval,
+		Max:    n.maxHealthCheckInterval,
+		Factor: 2,
+		Jitter: true,
+	}
+}
+
 func (n *Node) healthCheck() {
-	logDebug("[Node]", "(%v) starting healthcheck routine", n)
+	n.log.debug("[Node]", "(%v) starting healthcheck routine", n)
 
-	healthCheckTicker := time.NewTicker(n.healthCheckInterval)
-	defer healthCheckTicker.Stop()
+	b := n.newHealthCheckBackoff()
+	downSince := time.Now()
+	healthCheckTimer := time.NewTimer(b.Duration())
+	defer healthCheckTimer.Stop()
 
 	for {
 		if !n.ensureHealthCheckCanContinue() {
@@ -287,36 +780,47 @@ func (n *Node) healthCheck() {
 		}
 		select {
 		case <-n.stopChan:
-			logDebug("[Node]", "(%v) healthcheck quitting", n)
+			n.log.debug("[Node]", "(%v) healthcheck quitting", n)
 			return
-		case t := <-healthCheckTicker.C:
+		case t := <-healthCheckTimer.C:
 			if !n.ensureHealthCheckCanContinue() {
 				return
 			}
-			logDebug("[Node]", "(%v) running healthcheck at %v", n, t)
+			n.log.debug("[Node]", "(%v) running healthcheck at %v", n, t)
+			n.health.attempted()
 			conn, cerr := n.cm.createConnection()
 			if cerr != nil {
 				conn.close()
-				logError("[Node]", "(%v) failed healthcheck in createConnection, err: %v", n, cerr)
+				n.health.failed(cerr)
+				n.logHealthCheckFailure(downSince, "failed healthcheck in createConnection", cerr)
 			} else {
 				if !n.ensureHealthCheckCanContinue() {
 					conn.close()
 					return
 				}
 				hcmd := n.getHealthCheckCommand()
-				logDebug("[Node]", "(%v) healthcheck executing %v", n, hcmd.Name())
+				n.log.debug("[Node]", "(%v) healthcheck executing %v", n, hcmd.Name())
 				if hcerr := conn.execute(hcmd); hcerr != nil || !hcmd.Success() {
 					conn.close()
-					logError("[Node]", "(%v) failed healthcheck, err: %v", n, hcerr)
+					if hcerr == nil {
+						hcerr = ErrNodeHealthCheckFailed
+					}
+					n.health.failed(hcerr)
+					n.logHealthCheckFailure(downSince, "failed healthcheck", hcerr)
 				} else {
 					conn.close()
-					logDebug("[Node]", "(%v) healthcheck success, err: %v, success: %v", n, hcerr, hcmd.Success())
+					n.health.succeeded(time.Now())
+					n.log.debug("[Node]", "(%v) healthcheck success after %v, err: %v, success: %v", n, time.Since(downSince), hcerr, hcmd.Success())
+					if pc, ok := hcmd.(*PingCommand); ok {
+						n.log.debug("[Node]", "(%v) healthcheck ping took %v", n, pc.Duration())
+					}
 					if n.ensureHealthCheckCanContinue() {
 						n.setState(nodeRunning)
 					}
 					return
 				}
 			}
+			healthCheckTimer.Reset(b.Duration())
 		}
 	}
 }
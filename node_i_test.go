@@ -17,11 +17,21 @@
 package riak
 
 import (
+	"bytes"
+	"context"
+	"log"
 	"net"
+	"os"
+	"reflect"
 	"runtime"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	rpbRiakKV "github.com/basho/riak-go-client/rpb/riak_kv"
+	proto "github.com/golang/protobuf/proto"
 )
 
 func TestCreateNodeWithOptionsAndStart(t *testing.T) {
@@ -235,17 +245,13 @@ func TestRecoverAfterConnectionComesUpViaDefaultPingHealthCheck(t *testing.T) {
 
 	go func() {
 		listenerStarted := false
-		nodeIsRunningCount := 0
 		for {
 			logDebug("[TestRecoverAfterConnectionComesUpViaDefaultPingHealthCheck]", "waiting on stateChan...")
 			if nodeState, ok := <-stateChan; ok {
 				logDebug("[TestRecoverAfterConnectionComesUpViaDefaultPingHealthCheck]", "received nodeState: '%v'", nodeState)
-				if nodeState == nodeRunning {
-					nodeIsRunningCount++
-				}
-				logDebug("[TestRecoverAfterConnectionComesUpViaDefaultPingHealthCheck]", "nodeIsRunningCount: '%v'", nodeIsRunningCount)
-				if nodeIsRunningCount == 2 {
-					// This is the second time node has entered nodeRunning state, so it must have recovered via the healthcheck
+				if listenerStarted && nodeState == nodeRunning {
+					// NB: as no connection could be established, the node started health checking, so it
+					// must have recovered via the healthcheck
 					logDebug("[TestRecoverAfterConnectionComesUpViaDefaultPingHealthCheck]", "SUCCESS node recovered via healthcheck")
 					close(recoveredChan)
 					break
@@ -310,3 +316,1587 @@ func TestRecoverAfterConnectionComesUpViaDefaultPingHealthCheck(t *testing.T) {
 		t.Error("test timed out")
 	}
 }
+
+func TestRecoverViaServerInfoHealthCheck(t *testing.T) {
+	var connects, serverInfoReqs uint32
+	var onConn = func(c net.Conn) bool {
+		defer c.Close()
+		if atomic.AddUint32(&connects, 1) == 1 {
+			return true
+		}
+		msgCode, err := readClientMessage(c)
+		if err != nil {
+			return true
+		}
+		if msgCode != rpbCode_RpbGetServerInfoReq {
+			t.Errorf("got msg code %v, want %v", msgCode, rpbCode_RpbGetServerInfoReq)
+			return true
+		}
+		atomic.AddUint32(&serverInfoReqs, 1)
+		data, err := buildGetServerInfoResp()
+		if err != nil {
+			t.Error(err)
+			return true
+		}
+		if _, err = c.Write(data); err != nil {
+			t.Error(err)
+		}
+		return true
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	stateChanges := make(chan NodeStateChange, 8)
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:       tl.addr.String(),
+		MinConnections:      0,
+		HealthCheckInterval: time.Millisecond * 10,
+		HealthCheckBuilder:  NewServerInfoHealthCheckBuilder(),
+		StateChanges:        stateChanges,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer node.stop()
+
+	// NB: the first connection is closed by the listener, which starts the health check
+	if _, err = node.execute(&PingCommand{}); err == nil {
+		t.Fatal("expected ping on a closed connection to fail")
+	}
+
+	var got []string
+	for len(got) < 3 {
+		select {
+		case change := <-stateChanges:
+			got = append(got, change.NewState)
+		case <-time.After(time.Second * 5):
+			t.Fatalf("timeout waiting for node to recover, saw states %v", got)
+		}
+	}
+	if want := []string{"nodeRunning", "nodeHealthChecking", "nodeRunning"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := atomic.LoadUint32(&serverInfoReqs), uint32(1); got != want {
+		t.Errorf("got %v server info requests, want %v", got, want)
+	}
+}
+
+func TestNodeStatsRecordHealthChecks(t *testing.T) {
+	var connects uint32
+	var onConn = func(c net.Conn) bool {
+		// NB: the pooled connection and the first health check connection are closed
+		if atomic.AddUint32(&connects, 1) <= 2 {
+			c.Close()
+			return true
+		}
+		readWriteResp(t, c, true)
+		return true
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	stateChanges := make(chan NodeStateChange, 8)
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:       tl.addr.String(),
+		MinConnections:      1,
+		HealthCheckInterval: time.Millisecond * 10,
+		StateChanges:        stateChanges,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := time.Now()
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer node.stop()
+
+	if _, err = node.execute(&PingCommand{}); err == nil {
+		t.Fatal("expected ping on a closed connection to fail")
+	}
+	if node.Stats().LastError == nil {
+		t.Error("expected the error that started the health check to be recorded")
+	}
+
+	var states []string
+	for len(states) < 3 {
+		select {
+		case change := <-stateChanges:
+			states = append(states, change.NewState)
+		case <-time.After(time.Second * 5):
+			t.Fatalf("timeout waiting for node to recover, saw states %v", states)
+		}
+	}
+	s := node.Stats()
+	if got, want := s.ReconnectAttempts, uint64(2); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if s.LastHealthCheckSuccess.Before(started) {
+		t.Errorf("expected last health check success after %v, got %v", started, s.LastHealthCheckSuccess)
+	}
+	if s.LastError == nil {
+		t.Error("expected the failed health check to be recorded")
+	}
+}
+
+func TestCheckHealthDoesNotAffectNodeState(t *testing.T) {
+	o := &testListenerOpts{
+		test: t,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:  tl.addr.String(),
+		MinConnections: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer node.stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = node.CheckHealth(ctx); err != nil {
+		t.Error(err)
+	}
+	if !node.isCurrentState(nodeRunning) {
+		t.Errorf("expected node to be running, got %v", node.stateData.String())
+	}
+	if got, want := node.cm.count(), uint16(1); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCheckHealthRespectsContextDeadline(t *testing.T) {
+	var onConn = func(c net.Conn) bool {
+		if _, err := readClientMessage(c); err != nil {
+			return true
+		}
+		time.Sleep(500 * time.Millisecond) // NB: never respond in time
+		return true
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:  tl.addr.String(),
+		MinConnections: 0,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if got, want := node.CheckHealth(ctx), context.DeadlineExceeded; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Errorf("expected CheckHealth to return at the deadline, took %v", elapsed)
+	}
+	if !node.isCurrentState(nodeCreated) {
+		t.Errorf("expected node state to be unchanged, got %v", node.stateData.String())
+	}
+}
+
+// recordingPingBuilder builds the health check PingCommands of a Node, recording each so that a
+// test can check that no two health checks share one
+type recordingPingBuilder struct {
+	built []*PingCommand
+	sync.Mutex
+}
+
+func (b *recordingPingBuilder) Build() (Command, error) {
+	cmd := &PingCommand{}
+	b.Lock()
+	b.built = append(b.built, cmd)
+	b.Unlock()
+	return cmd, nil
+}
+
+// NB: run with -race, which reports any state shared between concurrent health checks
+func TestConcurrentHealthChecksDoNotShareCommands(t *testing.T) {
+	o := &testListenerOpts{
+		test: t,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	builder := &recordingPingBuilder{}
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:       tl.addr.String(),
+		MinConnections:      1,
+		HealthCheckInterval: 5 * time.Millisecond,
+		HealthCheckBuilder:  builder,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer node.stop()
+
+	// NB: the background health check runs alongside the on-demand ones
+	node.doHealthCheck(nil)
+	const checks = 50
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := make(chan error, checks)
+	for i := 0; i < checks; i++ {
+		go func() {
+			errs <- node.CheckHealth(ctx)
+		}()
+	}
+	for i := 0; i < checks; i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+	for !node.isCurrentState(nodeRunning) {
+		if ctx.Err() != nil {
+			t.Fatalf("expected node to recover, got %v", node.stateData.String())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	builder.Lock()
+	defer builder.Unlock()
+	if got, want := len(builder.built), checks+1; got < want {
+		t.Errorf("got %v health check commands, want at least %v", got, want)
+	}
+	seen := make(map[*PingCommand]bool)
+	succeeded := 0
+	for _, cmd := range builder.built {
+		if seen[cmd] {
+			t.Fatal("expected a new command for each health check")
+		}
+		seen[cmd] = true
+		if cmd.Success() {
+			succeeded++
+			if cmd.Duration() <= 0 {
+				t.Error("expected each successful ping to record its own duration")
+			}
+		}
+	}
+	if got, want := succeeded, checks+1; got < want {
+		t.Errorf("got %v successful health checks, want at least %v", got, want)
+	}
+}
+
+type syncBuffer struct {
+	buf bytes.Buffer
+	sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.String()
+}
+
+func TestNodeLogsStatsAtInterval(t *testing.T) {
+	buf := &syncBuffer{}
+	SetLogger(log.New(buf, "", log.LstdFlags))
+	defer SetLogger(log.New(os.Stderr, "", log.LstdFlags))
+
+	o := &testListenerOpts{
+		test: t,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:    tl.addr.String(),
+		MinConnections:   2,
+		StatsLogInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err = node.stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	logged := buf.String()
+	if !strings.Contains(logged, "[INFO] [Node] ("+tl.addr.String()+") state: nodeRunning, connections: 2, available: 2, in flight: 0, min: 2, max: ") {
+		t.Errorf("expected stats to be logged, got %q", logged)
+	}
+
+	// NB: logging stops with the Node, allowing for a summary that was being logged during stop
+	time.Sleep(20 * time.Millisecond)
+	lines := strings.Count(buf.String(), "\n")
+	time.Sleep(50 * time.Millisecond)
+	if got, want := strings.Count(buf.String(), "\n"), lines; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestStopRunningNode(t *testing.T) {
+	o := &testListenerOpts{
+		test: t,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress: tl.addr.String(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	ping := &PingCommand{}
+	if _, err = node.execute(ping); err != nil {
+		t.Fatal(err)
+	}
+	if !ping.Success() {
+		t.Error("expected ping to succeed")
+	}
+	if err = node.stop(); err != nil {
+		t.Fatal(err)
+	}
+	if !node.isCurrentState(nodeShutdown) {
+		t.Errorf("expected node to be shut down, got %v", node.stateData.String())
+	}
+}
+
+func TestNodeExpiresIdleConnectionsAtInterval(t *testing.T) {
+	o := &testListenerOpts{
+		test: t,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:          tl.addr.String(),
+		MinConnections:         1,
+		IdleTimeout:            10 * time.Millisecond,
+		IdleExpirationInterval: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer node.stop()
+
+	conns := make([]*connection, 3)
+	for i := range conns {
+		if conns[i], err = node.cm.get(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, conn := range conns {
+		if err = node.cm.put(conn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := node.cm.count(), uint16(3); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	time.Sleep(250 * time.Millisecond)
+	if got, want := node.cm.count(), uint16(1); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNodeRetriesCommandOnNewConnectionAfterConnectionError(t *testing.T) {
+	connects := uint32(0)
+	var onConn = func(c net.Conn) bool {
+		if atomic.AddUint32(&connects, 1) == 1 {
+			// NB: the pooled connection fails mid-request
+			if _, err := readClientMessage(c); err == nil {
+				c.Close()
+			}
+			return true
+		}
+		readWriteResp(t, c, true)
+		return true
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:          tl.addr.String(),
+		MinConnections:         1,
+		ConnectionErrorRetries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer node.stop()
+
+	ping := &PingCommand{}
+	executed, err := node.execute(ping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !executed || !ping.Success() {
+		t.Errorf("expected ping to succeed, executed: %v", executed)
+	}
+	if got, want := atomic.LoadUint32(&connects), uint32(2); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if !node.isCurrentState(nodeRunning) {
+		t.Errorf("expected node to be running, got %v", node.stateData.String())
+	}
+}
+
+func TestNodeRetryPolicyRetriesWithBackoff(t *testing.T) {
+	connects := uint32(0)
+	var onConn = func(c net.Conn) bool {
+		if atomic.AddUint32(&connects, 1) <= 2 {
+			if _, err := readClientMessage(c); err == nil {
+				c.Close()
+			}
+			return true
+		}
+		readWriteResp(t, c, true)
+		return true
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:  tl.addr.String(),
+		MinConnections: 1,
+		RetryPolicy:    &ExponentialBackoff{MaxAttempts: 3, MinBackoff: 10 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer node.stop()
+
+	ping := &PingCommand{}
+	start := time.Now()
+	if _, err = node.execute(ping); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("expected backoff of at least 30ms between retries, took %v", elapsed)
+	}
+	if got, want := atomic.LoadUint32(&connects), uint32(3); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNodeDoesNotRetryCommandAfterRiakError(t *testing.T) {
+	requests := uint32(0)
+	var onConn = func(c net.Conn) bool {
+		if _, err := readClientMessage(c); err != nil {
+			return true
+		}
+		atomic.AddUint32(&requests, 1)
+		data, err := buildRiakError("this is an error")
+		if err != nil {
+			t.Error(err)
+			return true
+		}
+		if _, err = c.Write(data); err != nil {
+			return true
+		}
+		return false
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:          tl.addr.String(),
+		MinConnections:         1,
+		ConnectionErrorRetries: 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer node.stop()
+
+	if _, err = node.execute(&PingCommand{}); err == nil {
+		t.Fatal("expected non-nil error")
+	}
+	if _, ok := err.(RiakError); !ok {
+		t.Errorf("expected RiakError, got %v", err)
+	}
+	if got, want := atomic.LoadUint32(&requests), uint32(1); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNodeUsesDialer(t *testing.T) {
+	dialed := make(chan string, 1)
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:  "127.0.0.1:1",
+		MinConnections: 1,
+		MaxConnections: 1,
+		Dialer: func(network, address string) (net.Conn, error) {
+			dialed <- network + " " + address
+			client, server := net.Pipe()
+			go func() {
+				for {
+					if readWriteResp(t, server, false) == false {
+						return
+					}
+				}
+			}()
+			return client, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer node.stop()
+
+	if got, want := <-dialed, "tcp 127.0.0.1:1"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	ping := &PingCommand{}
+	if _, err = node.execute(ping); err != nil {
+		t.Fatal(err)
+	}
+	if !ping.Success() {
+		t.Error("expected ping over the dialed connection to succeed")
+	}
+}
+
+func TestNodeStatsCountBytesAndCommands(t *testing.T) {
+	errResp, err := buildRiakError("oops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := &testListenerOpts{
+		test: t,
+		onConn: func(c net.Conn) bool {
+			code, err := readClientMessage(c)
+			if err != nil {
+				return true
+			}
+			resp := errResp
+			if code == rpbCode_RpbPingReq {
+				resp = buildRiakMessage(rpbCode_RpbPingResp, nil)
+			}
+			if _, err := c.Write(resp); err != nil {
+				t.Error(err)
+				return true
+			}
+			return false
+		},
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:  tl.addr.String(),
+		MinConnections: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer node.stop()
+
+	// NB: a ping is a 4 byte length followed by the 1 byte message code, in both directions
+	if _, err = node.execute(&PingCommand{}); err != nil {
+		t.Fatal(err)
+	}
+	s := node.Stats()
+	if got, want := s.BytesSent, uint64(5); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := s.BytesReceived, uint64(5); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := s.CommandsExecuted, uint64(1); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := s.CommandErrors, uint64(0); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	cmd, err := NewFetchValueCommandBuilder().
+		WithBucket("bucket").
+		WithKey("key").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := getRiakMessage(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = node.execute(cmd); err == nil {
+		t.Fatal("expected non-nil error")
+	}
+	s = node.Stats()
+	if got, want := s.BytesSent, uint64(5+len(req)); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := s.BytesReceived, uint64(5+len(errResp)); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := s.CommandsExecuted, uint64(2); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := s.CommandErrors, uint64(1); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNodeStatsReflectsInFlightConnections(t *testing.T) {
+	o := &testListenerOpts{
+		test: t,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:  tl.addr.String(),
+		MinConnections: 2,
+		MaxConnections: 8,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer node.stop()
+
+	conn, err := node.cm.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := node.Stats()
+	if got, want := s.State, "nodeRunning"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := s.TotalConnections, uint16(2); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := s.Available, uint16(1); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := s.InFlight, uint16(1); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := s.MaxConnections, uint16(8); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// NB: Stats must not contend with execution
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := node.execute(&PingCommand{}); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			node.Stats()
+		}()
+	}
+	wg.Wait()
+
+	if err = node.cm.put(conn); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := node.Stats().InFlight, uint16(0); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNodeExecuteContextReturnsWhenCancelled(t *testing.T) {
+	doneChan := make(chan struct{})
+	defer close(doneChan)
+	var onConn = func(c net.Conn) bool {
+		if _, err := readClientMessage(c); err != nil {
+			return true
+		}
+		<-doneChan // NB: never respond
+		c.Close()
+		return true
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:  tl.addr.String(),
+		MinConnections: 1,
+		RequestTimeout: 30 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer node.stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	cmd := &PingCommand{}
+	start := time.Now()
+	executed, err := node.ExecuteContext(ctx, cmd)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected ExecuteContext to return promptly, took %v", elapsed)
+	}
+	if got, want := err, context.Canceled; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if !executed {
+		t.Error("expected command to have been sent to Riak")
+	}
+	if cmd.Success() {
+		t.Error("expected command to not be successful")
+	}
+	if got, want := node.cm.count(), uint16(0); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNodeExecuteContextReturnsAtDeadline(t *testing.T) {
+	doneChan := make(chan struct{})
+	defer close(doneChan)
+	var onConn = func(c net.Conn) bool {
+		if _, err := readClientMessage(c); err != nil {
+			return true
+		}
+		<-doneChan // NB: never respond
+		c.Close()
+		return true
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:  tl.addr.String(),
+		MinConnections: 1,
+		RequestTimeout: 30 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer node.stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err = node.ExecuteContext(ctx, &PingCommand{}); err != context.DeadlineExceeded {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected ExecuteContext to return at the deadline, took %v", elapsed)
+	}
+}
+
+func TestNodeKeepsConnectionUntilListBucketsStreamIsDone(t *testing.T) {
+	chunks := [][]string{{"b1", "b2"}, {"b3"}}
+	var onConn = func(c net.Conn) bool {
+		msgCode, data, err := readClientMessageWithData(c)
+		if err != nil {
+			return true
+		}
+		if msgCode != rpbCode_RpbListBucketsReq {
+			resp, _ := buildRiakError("unexpected message code")
+			c.Write(resp)
+			return false
+		}
+		req := &rpbRiakKV.RpbListBucketsReq{}
+		if err = proto.Unmarshal(data, req); err != nil {
+			t.Error(err)
+			return true
+		}
+		if got, want := string(req.GetType()), "btype"; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		resps := make([]*rpbRiakKV.RpbListBucketsResp, 0, len(chunks)+1)
+		for _, chunk := range chunks {
+			resp := &rpbRiakKV.RpbListBucketsResp{}
+			for _, b := range chunk {
+				resp.Buckets = append(resp.Buckets, []byte(b))
+			}
+			resps = append(resps, resp)
+		}
+		resps = append(resps, &rpbRiakKV.RpbListBucketsResp{Done: proto.Bool(true)})
+		for _, resp := range resps {
+			encoded, merr := proto.Marshal(resp)
+			if merr != nil {
+				t.Error(merr)
+				return true
+			}
+			if _, err = c.Write(buildRiakMessage(rpbCode_RpbListBucketsResp, encoded)); err != nil {
+				return true
+			}
+			// NB: give the client time to process each chunk separately
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:  tl.addr.String(),
+		MinConnections: 1,
+		MaxConnections: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer node.stop()
+
+	if _, err = NewListBucketsCommandBuilder().WithBucketType("btype").Build(); err != ErrListingDisabled {
+		t.Errorf("got %v, want %v", err, ErrListingDisabled)
+	}
+
+	var streamed [][]string
+	cmd, err := NewListBucketsCommandBuilder().
+		WithAllowListing().
+		WithBucketType("btype").
+		WithStreaming(true).
+		WithCallback(func(buckets []string) error {
+			streamed = append(streamed, buckets)
+			if got, want := node.Stats().InFlight, uint16(1); got != want {
+				t.Errorf("got %v connections in flight while streaming, want %v", got, want)
+			}
+			return nil
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = node.execute(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := streamed, chunks; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := node.Stats().InFlight, uint16(0); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNodeExecuteBatchUsesOneConnection(t *testing.T) {
+	var connects uint32
+	var onConn = func(c net.Conn) bool {
+		atomic.AddUint32(&connects, 1)
+		for readWriteResp(t, c, false) {
+		}
+		return true
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:  tl.addr.String(),
+		MinConnections: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer node.stop()
+
+	commands := []Command{&PingCommand{}, &PingCommand{}, &PingCommand{}}
+	results, err := node.ExecuteBatch(commands)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(results), len(commands); got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i, r := range results {
+		if r.Command != commands[i] || !r.Executed || r.Error != nil || !r.Command.Success() {
+			t.Errorf("command %d: got executed %v, error %v, success %v", i, r.Executed, r.Error, r.Command.Success())
+		}
+	}
+	if got, want := atomic.LoadUint32(&connects), uint32(1); got != want {
+		t.Errorf("got %v connections, want %v", got, want)
+	}
+}
+
+func TestNodeExecuteBatchAbortsAfterConnectionError(t *testing.T) {
+	var onConn = func(c net.Conn) bool {
+		// NB: answer the first command only, then close the connection
+		readWriteResp(t, c, false)
+		readClientMessage(c)
+		c.Close()
+		return true
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:       tl.addr.String(),
+		MinConnections:      1,
+		HealthCheckInterval: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer node.stop()
+
+	results, err := node.ExecuteBatch([]Command{&PingCommand{}, &PingCommand{}, &PingCommand{}})
+	if err == nil {
+		t.Fatal("expected the batch to be aborted")
+	}
+	want := []bool{true, true, false}
+	for i, r := range results {
+		if got := r.Executed; got != want[i] {
+			t.Errorf("command %d: got executed %v, want %v", i, got, want[i])
+		}
+	}
+	if results[0].Error != nil {
+		t.Errorf("expected first command to succeed, got %v", results[0].Error)
+	}
+	if results[1].Error != err {
+		t.Errorf("got %v, want %v", results[1].Error, err)
+	}
+}
+
+func TestNodeSessionExecutesOnOneConnection(t *testing.T) {
+	var connects uint32
+	var onConn = func(c net.Conn) bool {
+		atomic.AddUint32(&connects, 1)
+		for readWriteResp(t, c, false) {
+		}
+		return true
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:  tl.addr.String(),
+		MinConnections: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer node.stop()
+
+	session, err := node.Session()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := session.conn
+	// NB: the session holds the only pooled connection, so the pool creates another for this
+	if _, err = node.execute(&PingCommand{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err = session.Execute(&PingCommand{}); err != nil {
+			t.Fatal(err)
+		}
+		if session.conn != conn {
+			t.Errorf("command %d: expected the session to keep its connection", i)
+		}
+	}
+	if got, want := atomic.LoadUint32(&connects), uint32(2); got != want {
+		t.Errorf("got %v connections, want %v", got, want)
+	}
+
+	if err = session.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := session.Execute(&PingCommand{}), ErrSessionClosed; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := node.cm.q.count(), uint16(2); got != want {
+		t.Errorf("got %v pooled connections, want %v", got, want)
+	}
+}
+
+func TestNodeDoesNotReuseConnectionAfterUnexpectedResponse(t *testing.T) {
+	var accepted int32
+	var onConn = func(c net.Conn) bool {
+		msgCode, err := readClientMessage(c)
+		if err != nil {
+			return true
+		}
+		var data []byte
+		switch msgCode {
+		case rpbCode_RpbPingReq:
+			atomic.AddInt32(&accepted, 1)
+			// NB: an unexpected response followed by the expected one, which is left unread
+			data = append(buildRiakMessage(rpbCode_RpbGetServerInfoResp, nil), buildRiakMessage(rpbCode_RpbPingResp, nil)...)
+		case rpbCode_RpbGetServerInfoReq:
+			atomic.AddInt32(&accepted, 1)
+			if data, err = buildGetServerInfoResp(); err != nil {
+				t.Error(err)
+				return true
+			}
+		default:
+			if data, err = buildRiakError("unexpected message code"); err != nil {
+				t.Error(err)
+				return true
+			}
+		}
+		if _, err = c.Write(data); err != nil {
+			return true
+		}
+		if msgCode == rpbCode_RpbPingReq {
+			// NB: wait for the client to close this connection
+			readClientMessage(c)
+			return true
+		}
+		return false
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:  tl.addr.String(),
+		MinConnections: 1,
+		MaxConnections: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer node.stop()
+
+	if _, err = node.execute(&PingCommand{}); err == nil {
+		t.Fatal("expected an error for the unexpected response")
+	}
+	if _, ok := err.(ClientError); !ok {
+		t.Errorf("expected a ClientError, got %v (%v)", err, reflect.TypeOf(err))
+	}
+
+	cmd := &GetServerInfoCommand{}
+	if _, err = node.execute(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cmd.Response.ServerVersion, "9.9.9"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := atomic.LoadInt32(&accepted), int32(2); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNodeReplacesConnectionClosedByRiak(t *testing.T) {
+	var connects uint32
+	closed := make(chan struct{})
+	var onConn = func(c net.Conn) bool {
+		if atomic.AddUint32(&connects, 1) == 1 {
+			// NB: as after an idle timeout, Riak closes the first connection after one Command
+			readWriteResp(t, c, false)
+			c.Close()
+			close(closed)
+			return true
+		}
+		for readWriteResp(t, c, false) {
+		}
+		return true
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:  tl.addr.String(),
+		MinConnections: 1,
+		MaxConnections: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer node.stop()
+
+	if _, err = node.execute(&PingCommand{}); err != nil {
+		t.Fatal(err)
+	}
+	<-closed
+	cmd := &PingCommand{}
+	if _, err = node.execute(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if !cmd.Success() {
+		t.Error("expected the command to succeed on a new connection")
+	}
+	if got, want := atomic.LoadUint32(&connects), uint32(2); got != want {
+		t.Errorf("got %v connections, want %v", got, want)
+	}
+	if got, want := node.cm.connectionCounter.count(), uint16(1); got != want {
+		t.Errorf("got %v open connections, want %v", got, want)
+	}
+	if !node.isCurrentState(nodeRunning) {
+		t.Errorf("expected the node to keep running, got %v", node.describe(node.getState()))
+	}
+}
+
+func TestNodeWaitsForConnectionAtMaxConnections(t *testing.T) {
+	var accepted int32
+	var onConn = func(c net.Conn) bool {
+		if _, err := readClientMessage(c); err != nil {
+			return true
+		}
+		atomic.AddInt32(&accepted, 1)
+		time.Sleep(100 * time.Millisecond)
+		if _, err := c.Write(buildRiakMessage(rpbCode_RpbPingResp, nil)); err != nil {
+			return true
+		}
+		return false
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:     tl.addr.String(),
+		MinConnections:    1,
+		MaxConnections:    1,
+		MaxConnectionWait: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer node.stop()
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			executed, err := node.execute(&PingCommand{})
+			if err != nil {
+				t.Error(err)
+			}
+			if !executed {
+				t.Error("expected Ping to be executed")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got, want := atomic.LoadInt32(&accepted), int32(2); got != want {
+		t.Errorf("got %v pings, want %v", got, want)
+	}
+	if got, want := node.Stats().TotalConnections, uint16(1); got != want {
+		t.Errorf("got %v connections, want %v", got, want)
+	}
+}
+
+func TestNodeExecuteAtMaxConnectionsConcurrently(t *testing.T) {
+	var onConn = func(c net.Conn) bool {
+		if _, err := readClientMessage(c); err != nil {
+			return true
+		}
+		if _, err := c.Write(buildRiakMessage(rpbCode_RpbPingResp, nil)); err != nil {
+			return true
+		}
+		return false
+	}
+	o := &testListenerOpts{
+		test:   t,
+		onConn: onConn,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	maxConnections := uint16(2)
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:  tl.addr.String(),
+		MinConnections: 1,
+		MaxConnections: maxConnections,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	defer node.stop()
+
+	var executed int32
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				// NB: at the limit, execute fails fast
+				if ok, err := node.execute(&PingCommand{}); ok && err == nil {
+					atomic.AddInt32(&executed, 1)
+				}
+				if got := node.cm.count(); got > maxConnections {
+					t.Errorf("got %v connections, want at most %v", got, maxConnections)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if atomic.LoadInt32(&executed) == 0 {
+		t.Error("expected at least one Ping to be executed")
+	}
+}
+
+func TestNodeStartsOneHealthCheckForConcurrentConnectionFailures(t *testing.T) {
+	o := &testListenerOpts{
+		test: t,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	addr := tl.addr.String()
+	tl.stop() // NB: nothing is listening, so connections cannot be created
+
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:       addr,
+		MinConnections:      1,
+		MaxConnections:      4,
+		HealthCheckInterval: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var healthChecks int32
+	origSetStateFunc := node.setStateFunc
+	node.setStateFunc = func(sd *stateData, st state) {
+		origSetStateFunc(sd, st)
+		if st == nodeHealthChecking {
+			atomic.AddInt32(&healthChecks, 1)
+		}
+	}
+	if err = node.start(); err != ErrNodeNoConnections {
+		t.Fatalf("got %v, want %v", err, ErrNodeNoConnections)
+	}
+	defer node.stop()
+
+	start := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			node.execute(&PingCommand{})
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if got, want := node.getState(), nodeHealthChecking; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := atomic.LoadInt32(&healthChecks), int32(1); got != want {
+		t.Errorf("got %v health checks started, want %v", got, want)
+	}
+}
+
+func TestNodeSendsStateChanges(t *testing.T) {
+	o := &testListenerOpts{
+		test: t,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	stateChanges := make(chan NodeStateChange, 8)
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress: tl.addr.String(),
+		StateChanges:  stateChanges,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	if err = node.stop(); err != nil {
+		t.Fatal(err)
+	}
+	close(stateChanges)
+
+	var got []NodeStateChange
+	for change := range stateChanges {
+		got = append(got, change)
+	}
+	addr := tl.addr.String()
+	want := []NodeStateChange{
+		{Address: addr, OldState: "nodeCreated", NewState: "nodeRunning"},
+		{Address: addr, OldState: "nodeRunning", NewState: "nodeShuttingDown"},
+		{Address: addr, OldState: "nodeShuttingDown", NewState: "nodeShutdown"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNodeDropsStateChangesWhenChannelIsFull(t *testing.T) {
+	o := &testListenerOpts{
+		test: t,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	stateChanges := make(chan NodeStateChange) // NB: never received from
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress: tl.addr.String(),
+		StateChanges:  stateChanges,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	if err = node.stop(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := node.getState(), nodeShutdown; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNodeLogsToLogger(t *testing.T) {
+	o := &testListenerOpts{
+		test: t,
+	}
+	tl := newTestListener(o)
+	tl.start()
+	defer tl.stop()
+
+	logger := &testLogger{}
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress: tl.addr.String(),
+		Logger:        logger,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.start(); err != nil {
+		t.Fatal(err)
+	}
+	if err = node.stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := strings.Join(logger.recorded(), "\n")
+	for _, want := range []string{"starting", "started", "shutting down.", "shut down."} {
+		if !strings.Contains(messages, "debug [Node] ") || !strings.Contains(messages, ") "+want) {
+			t.Errorf("expected a debug message ending '%s', got:\n%s", want, messages)
+		}
+	}
+}
+
+func TestNodeStopWaitsForCommandsInFlight(t *testing.T) {
+	tests := []struct {
+		delay        time.Duration
+		drainTimeout time.Duration
+		wantErr      bool
+	}{
+		{200 * time.Millisecond, 5 * time.Second, false},
+		{time.Second, 50 * time.Millisecond, true},
+	}
+	for _, tt := range tests {
+		delay := tt.delay
+		var onConn = func(c net.Conn) bool {
+			if _, err := readClientMessage(c); err != nil {
+				return true
+			}
+			time.Sleep(delay)
+			if _, err := c.Write(buildRiakMessage(rpbCode_RpbPingResp, nil)); err != nil {
+				return true
+			}
+			return false
+		}
+		o := &testListenerOpts{
+			test:   t,
+			onConn: onConn,
+		}
+		tl := newTestListener(o)
+		tl.start()
+
+		node, err := NewNode(&NodeOptions{
+			RemoteAddress:  tl.addr.String(),
+			RequestTimeout: 5 * time.Second,
+			DrainTimeout:   tt.drainTimeout,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = node.start(); err != nil {
+			t.Fatal(err)
+		}
+
+		pingErr := make(chan error, 1)
+		go func() {
+			_, err := node.execute(&PingCommand{})
+			pingErr <- err
+		}()
+		for node.Stats().InFlight == 0 {
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		start := time.Now()
+		err = node.stop()
+		elapsed := time.Since(start)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("drain timeout %v: expected an error for the Command still in flight", tt.drainTimeout)
+			}
+			if elapsed > tt.delay {
+				t.Errorf("drain timeout %v: expected stop to give up waiting, took %v", tt.drainTimeout, elapsed)
+			}
+		} else {
+			if err != nil {
+				t.Errorf("drain timeout %v: expected no error, got %v", tt.drainTimeout, err)
+			}
+			if elapsed > tt.drainTimeout {
+				t.Errorf("drain timeout %v: took %v to stop", tt.drainTimeout, elapsed)
+			}
+		}
+		if got, want := node.getState(), nodeShutdown; got != want {
+			t.Errorf("drain timeout %v: got %v, want %v", tt.drainTimeout, got, want)
+		}
+		if err = <-pingErr; err != nil {
+			t.Errorf("drain timeout %v: expected Ping to complete, got %v", tt.drainTimeout, err)
+		}
+		if got, want := node.cm.count(), uint16(0); got != want {
+			t.Errorf("drain timeout %v: got %v connections, want %v", tt.drainTimeout, got, want)
+		}
+		tl.stop()
+	}
+}
@@ -15,7 +15,10 @@
 package riak
 
 import (
+	"context"
+	"sort"
 	"sync"
+	"sync/atomic"
 )
 
 // NodeManager enforces the structure needed to if going to implement your own NodeManager
@@ -23,7 +26,16 @@ type NodeManager interface {
 	ExecuteOnNode(nodes []*Node, command Command, previousNode *Node) (bool, error)
 }
 
+// ContextNodeManager is implemented by a NodeManager that can pass a context.Context along to the
+// Node executing the Command. Cluster.ExecuteContext uses it when available, otherwise the context
+// is only checked between execution attempts
+type ContextNodeManager interface {
+	NodeManager
+	ExecuteOnNodeContext(ctx context.Context, nodes []*Node, command Command, previousNode *Node) (bool, error)
+}
+
 var ErrDefaultNodeManagerRequiresNode = newClientError("Must pass at least one node to default node manager", nil)
+var ErrLeastConnectionsNodeManagerRequiresNode = newClientError("Must pass at least one node to least connections node manager", nil)
 
 type defaultNodeManager struct {
 	nodeIndex int
@@ -33,6 +45,11 @@ type defaultNodeManager struct {
 // ExecuteOnNode selects a Node from the pool and executes the provided Command on that Node. The
 // defaultNodeManager uses a simple round robin approach to distributing load
 func (nm *defaultNodeManager) ExecuteOnNode(nodes []*Node, command Command, previous *Node) (bool, error) {
+	return nm.ExecuteOnNodeContext(context.Background(), nodes, command, previous)
+}
+
+// ExecuteOnNodeContext is the same as ExecuteOnNode, but will abandon the Command if ctx is cancelled
+func (nm *defaultNodeManager) ExecuteOnNodeContext(ctx context.Context, nodes []*Node, command Command, previous *Node) (bool, error) {
 	if nodes == nil {
 		panic("[defaultNodeManager] nil nodes argument")
 	}
@@ -61,11 +78,14 @@ func (nm *defaultNodeManager) ExecuteOnNode(nodes []*Node, command Command, prev
 			continue
 		}
 
-		executed, err = node.execute(command)
+		executed, err = node.executeContext(ctx, command)
 		if executed == true {
 			logDebug("[DefaultNodeManager]", "executed '%s' on node '%s', err '%v'", command.Name(), node, err)
 			break
 		}
+		if ctx.Err() != nil {
+			break
+		}
 
 		nm.RLock()
 		if startingIndex == nm.nodeIndex {
@@ -78,3 +98,61 @@ func (nm *defaultNodeManager) ExecuteOnNode(nodes []*Node, command Command, prev
 
 	return executed, err
 }
+
+// LeastConnectionsNodeManager executes each Command on the running Node with the fewest connections
+// currently executing Commands, as reported by Node.Stats, which favours Nodes that are responding
+// quickly. Nodes with equal load are used in turn. Should the chosen Node not execute the Command,
+// the next least loaded Node is tried
+type LeastConnectionsNodeManager struct {
+	offset uint32
+}
+
+// ExecuteOnNode selects the least loaded Node and executes the provided Command on that Node
+func (nm *LeastConnectionsNodeManager) ExecuteOnNode(nodes []*Node, command Command, previous *Node) (bool, error) {
+	return nm.ExecuteOnNodeContext(context.Background(), nodes, command, previous)
+}
+
+// ExecuteOnNodeContext is the same as ExecuteOnNode, but will abandon the Command if ctx is cancelled
+func (nm *LeastConnectionsNodeManager) ExecuteOnNodeContext(ctx context.Context, nodes []*Node, command Command, previous *Node) (bool, error) {
+	if len(nodes) == 0 || nodes[0] == nil {
+		return false, ErrLeastConnectionsNodeManagerRequiresNode
+	}
+
+	var err error
+	executed := false
+	for _, node := range nm.candidates(nodes, previous) {
+		executed, err = node.executeContext(ctx, command)
+		if executed {
+			logDebug("[LeastConnectionsNodeManager]", "executed '%s' on node '%s', err '%v'", command.Name(), node, err)
+			break
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return executed, err
+}
+
+// candidates returns the running Nodes ordered from least to most loaded. The previous Node is
+// excluded if there are others
+func (nm *LeastConnectionsNodeManager) candidates(nodes []*Node, previous *Node) []*Node {
+	// NB: rotating the starting point spreads Commands across equally loaded Nodes
+	offset := int(atomic.AddUint32(&nm.offset, 1)) % len(nodes)
+	candidates := make([]*Node, 0, len(nodes))
+	inFlight := make(map[*Node]uint16, len(nodes))
+	for i := range nodes {
+		node := nodes[(offset+i)%len(nodes)]
+		if node == previous && len(nodes) > 1 {
+			continue
+		}
+		if !node.isCurrentState(nodeRunning) {
+			continue
+		}
+		inFlight[node] = node.Stats().InFlight
+		candidates = append(candidates, node)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return inFlight[candidates[i]] < inFlight[candidates[j]]
+	})
+	return candidates
+}
@@ -0,0 +1,87 @@
+// Copyright 2015-present Basho Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package riak
+
+import (
+	"testing"
+)
+
+func newNodeWithInFlight(t *testing.T, inFlight int) *Node {
+	node, err := NewNode(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < inFlight; i++ {
+		node.cm.connectionCounter.increment()
+	}
+	node.setState(nodeRunning)
+	return node
+}
+
+func TestLeastConnectionsNodeManagerPrefersLeastLoadedNode(t *testing.T) {
+	busy := newNodeWithInFlight(t, 5)
+	idle := newNodeWithInFlight(t, 1)
+	moderate := newNodeWithInFlight(t, 3)
+	nodes := []*Node{busy, idle, moderate}
+
+	nm := &LeastConnectionsNodeManager{}
+	for i := 0; i < len(nodes); i++ {
+		candidates := nm.candidates(nodes, nil)
+		if got, want := len(candidates), 3; got != want {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		if candidates[0] != idle || candidates[1] != moderate || candidates[2] != busy {
+			t.Errorf("expected nodes ordered by load, got %v", candidates)
+		}
+	}
+
+	// the previous node is skipped
+	candidates := nm.candidates(nodes, idle)
+	if got, want := len(candidates), 2; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if candidates[0] != moderate {
+		t.Errorf("got %v, want %v", candidates[0], moderate)
+	}
+
+	// nodes that are not running are skipped
+	moderate.setState(nodeHealthChecking)
+	candidates = nm.candidates(nodes, idle)
+	if got, want := len(candidates), 1; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if candidates[0] != busy {
+		t.Errorf("got %v, want %v", candidates[0], busy)
+	}
+}
+
+func TestLeastConnectionsNodeManagerRotatesEquallyLoadedNodes(t *testing.T) {
+	nodes := []*Node{newNodeWithInFlight(t, 0), newNodeWithInFlight(t, 0), newNodeWithInFlight(t, 0)}
+	nm := &LeastConnectionsNodeManager{}
+	seen := make(map[*Node]bool)
+	for i := 0; i < len(nodes); i++ {
+		seen[nm.candidates(nodes, nil)[0]] = true
+	}
+	if got, want := len(seen), len(nodes); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestLeastConnectionsNodeManagerRequiresNodes(t *testing.T) {
+	nm := &LeastConnectionsNodeManager{}
+	if _, err := nm.ExecuteOnNode(nil, &PingCommand{}, nil); err != ErrLeastConnectionsNodeManagerRequiresNode {
+		t.Errorf("got %v, want %v", err, ErrLeastConnectionsNodeManagerRequiresNode)
+	}
+}
@@ -15,9 +15,13 @@
 package riak
 
 import (
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestCreateNodeWithOptions(t *testing.T) {
@@ -118,6 +122,133 @@ func TestEnsureNodeValuesWithZeroValOptions(t *testing.T) {
 	if expected, actual := node.healthCheckInterval, defaultHealthCheckInterval; expected != actual {
 		t.Errorf("expected %v, got: %v", expected, actual)
 	}
+	if got, want := node.maxHealthCheckInterval, defaultMaxHealthCheckInterval; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestMaxHealthCheckIntervalIsAtLeastHealthCheckInterval(t *testing.T) {
+	node, err := NewNode(&NodeOptions{
+		HealthCheckInterval:    tenSeconds,
+		MaxHealthCheckInterval: fiveSeconds,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := node.maxHealthCheckInterval, tenSeconds; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestHealthCheckBackoffGrowsThenCaps(t *testing.T) {
+	min, max := 250*time.Millisecond, 4*time.Second
+	node, err := NewNode(&NodeOptions{
+		HealthCheckInterval:    min,
+		MaxHealthCheckInterval: max,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := node.newHealthCheckBackoff()
+	var delays []time.Duration
+	for i := 0; i < 64; i++ {
+		delays = append(delays, b.Duration())
+	}
+	ceiling := min
+	for i, d := range delays {
+		if d < min || d > ceiling {
+			t.Errorf("delay %d: got %v, want between %v and %v", i, d, min, ceiling)
+		}
+		if ceiling *= 2; ceiling > max {
+			ceiling = max
+		}
+	}
+	// NB: with jitter, only the first delay is fixed and only the last are certain to be capped
+	if got, want := delays[0], min; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := delays[len(delays)-1], max; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// each health check run starts again from the minimum
+	if got, want := node.newHealthCheckBackoff().Duration(), min; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNewNodeDoesNotModifyOptions(t *testing.T) {
+	opts := &NodeOptions{
+		MaxConnections: 8,
+	}
+	want := *opts
+	if _, err := NewNode(opts); err != nil {
+		t.Fatal(err)
+	}
+	if got := *opts; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestNewNodeWithMalformedRemoteAddress(t *testing.T) {
+	_, err := NewNode(&NodeOptions{
+		RemoteAddress: "riak-test:not-a-port",
+	})
+	if err == nil {
+		t.Fatal("expected non-nil err")
+	}
+	cerr, ok := err.(ClientError)
+	if !ok {
+		t.Fatalf("expected a ClientError, got %v (%v)", err, reflect.TypeOf(err))
+	}
+	if got, want := cerr.Errmsg, fmt.Sprintf(ErrNodeCannotResolveAddress, "riak-test:not-a-port"); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if cerr.InnerError == nil {
+		t.Error("expected the resolution error to be wrapped")
+	}
+}
+
+func TestNewNodeRemoteAddressForms(t *testing.T) {
+	tests := []struct {
+		remoteAddress string
+		want          string
+	}{
+		{"localhost", "127.0.0.1:8087"},
+		{"127.0.0.1", "127.0.0.1:8087"},
+		{"127.0.0.1:1234", "127.0.0.1:1234"},
+		{"10.0.0.1", "10.0.0.1:8087"},
+		{"10.0.0.1:9999", "10.0.0.1:9999"},
+		{"::1", "[::1]:8087"},
+		{"[::1]", "[::1]:8087"},
+		{"[::1]:1234", "[::1]:1234"},
+	}
+	for _, tt := range tests {
+		n, err := NewNode(&NodeOptions{
+			RemoteAddress: tt.remoteAddress,
+		})
+		if err != nil {
+			t.Errorf("%s: %v", tt.remoteAddress, err)
+			continue
+		}
+		if got, want := n.addr.String(), tt.want; got != want {
+			t.Errorf("%s: got %v, want %v", tt.remoteAddress, got, want)
+		}
+	}
+}
+
+func TestNewNodeWithMinConnectionsGreaterThanMax(t *testing.T) {
+	_, err := NewNode(&NodeOptions{
+		MinConnections: 10,
+		MaxConnections: 5,
+	})
+	if err == nil {
+		t.Fatal("expected non-nil err")
+	}
+	if got, want := err.Error(), newClientError(fmt.Sprintf(ErrNodeMinConnectionsExceedsMax, 10, 5), nil).Error(); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
 }
 
 func TestEnsureDefaultNodeValues(t *testing.T) {
@@ -165,3 +296,126 @@ func TestEnsureDefaultNodeValues(t *testing.T) {
 		t.Errorf("expected %v, got: %v", expected, actual)
 	}
 }
+
+func TestNodeTlsServerNameDefaultsToRemoteAddressHost(t *testing.T) {
+	tlsConfig := &tls.Config{}
+	opts := &NodeOptions{
+		RemoteAddress: "localhost:8087",
+		AuthOptions: &AuthOptions{
+			User:      "riakuser",
+			TlsConfig: tlsConfig,
+		},
+	}
+	node, err := NewNode(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := node.cm.authOptions.TlsConfig.ServerName, "localhost"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := node.cm.authOptions.ServerName, "localhost"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if tlsConfig.ServerName != "" {
+		t.Errorf("expected caller's tls.Config to be unchanged, got ServerName %v", tlsConfig.ServerName)
+	}
+	if opts.AuthOptions.ServerName != "" {
+		t.Errorf("expected caller's AuthOptions to be unchanged, got ServerName %v", opts.AuthOptions.ServerName)
+	}
+}
+
+func TestNodeTlsServerNameOverrides(t *testing.T) {
+	tests := []struct {
+		remoteAddress    string
+		serverName       string
+		tlsConfigName    string
+		wantServerName   string
+		wantCallerConfig string
+	}{
+		{"127.0.0.1:8087", "", "", "127.0.0.1", ""},
+		{"127.0.0.1:8087", "riak-test", "", "riak-test", ""},
+		{"127.0.0.1:8087", "", "riak-test", "riak-test", "riak-test"},
+		{"localhost:8087", "riak-override", "riak-test", "riak-override", "riak-test"},
+	}
+	for _, tt := range tests {
+		tlsConfig := &tls.Config{ServerName: tt.tlsConfigName}
+		opts := &NodeOptions{
+			RemoteAddress: tt.remoteAddress,
+			AuthOptions: &AuthOptions{
+				ServerName: tt.serverName,
+				TlsConfig:  tlsConfig,
+			},
+		}
+		node, err := NewNode(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := node.cm.authOptions.TlsConfig.ServerName, tt.wantServerName; got != want {
+			t.Errorf("%v: got %v, want %v", tt, got, want)
+		}
+		if got, want := tlsConfig.ServerName, tt.wantCallerConfig; got != want {
+			t.Errorf("%v: got %v, want %v", tt, got, want)
+		}
+	}
+}
+
+func TestStopNodeThatWasNeverStarted(t *testing.T) {
+	node, err := NewNode(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = node.stop(); err != nil {
+		t.Fatal(err)
+	}
+	if !node.isCurrentState(nodeShutdown) {
+		t.Errorf("expected node to be shut down, got %v", node.stateData.String())
+	}
+	if !node.cm.isCurrentState(cmShutdown) {
+		t.Errorf("expected connection manager to be shut down, got %v", node.cm.stateData.String())
+	}
+}
+
+func TestIdleExpirationIntervalIsPassedToConnectionManager(t *testing.T) {
+	node, err := NewNode(&NodeOptions{
+		IdleExpirationInterval: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := node.cm.idleExpirationInterval, 50*time.Millisecond; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if node, err = NewNode(nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := node.cm.idleExpirationInterval, defaultIdleExpirationInterval; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNodeStartHealthChecksWhenNoConnectionCanBeEstablished(t *testing.T) {
+	node, err := NewNode(&NodeOptions{
+		RemoteAddress:       "127.0.0.1:8087",
+		MinConnections:      2,
+		HealthCheckInterval: time.Minute,
+		Dialer: func(network, address string) (net.Conn, error) {
+			return nil, errors.New("dial failed")
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := node.start(), ErrNodeNoConnections; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := node.getState(), nodeHealthChecking; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if executed, _ := node.execute(&PingCommand{}); executed {
+		t.Error("expected a health checking node not to execute commands")
+	}
+	if err = node.stop(); err != nil {
+		t.Fatal(err)
+	}
+}
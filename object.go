@@ -16,6 +16,8 @@ package riak
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	rpbRiak "github.com/basho/riak-go-client/rpb/riak"
@@ -36,6 +38,13 @@ type Pair struct {
 	Value string
 }
 
+const (
+	intIndexSuffix = "_int"
+	binIndexSuffix = "_bin"
+)
+
+const ErrInvalidIntIndexValue = "[Object] invalid integer value '%s' for index '%s'"
+
 // Object structure used for representing a KV Riak object
 type Object struct {
 	BucketType      string
@@ -89,6 +98,69 @@ func (o *Object) AddToIndex(indexName string, indexValue string) {
 	}
 }
 
+// IntIndexes returns the object's integer secondary index entries, i.e. those whose name ends in
+// "_int", with their values decoded. Index names are returned unchanged
+func (o *Object) IntIndexes() (map[string][]int64, error) {
+	idx := make(map[string][]int64)
+	for indexName, indexValues := range o.Indexes {
+		if !strings.HasSuffix(indexName, intIndexSuffix) {
+			continue
+		}
+		values := make([]int64, len(indexValues))
+		for i, v := range indexValues {
+			iv, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, newClientError(fmt.Sprintf(ErrInvalidIntIndexValue, v, indexName), err)
+			}
+			values[i] = iv
+		}
+		idx[indexName] = values
+	}
+	return idx, nil
+}
+
+// BinIndexes returns the object's binary secondary index entries, i.e. those whose name ends in
+// "_bin". Index names are returned unchanged
+func (o *Object) BinIndexes() map[string][]string {
+	idx := make(map[string][]string)
+	for indexName, indexValues := range o.Indexes {
+		if strings.HasSuffix(indexName, binIndexSuffix) {
+			idx[indexName] = indexValues
+		}
+	}
+	return idx
+}
+
+// newTombstone returns an Object representing a deleted value. The vclock returned by Riak is
+// retained so that the tombstone may be used as causal context for subsequent operations
+func newTombstone(vclock []byte, bucketType, bucket, key string) *Object {
+	return &Object{
+		IsTombstone: true,
+		BucketType:  bucketType,
+		Bucket:      bucket,
+		Key:         key,
+		VClock:      vclock,
+	}
+}
+
+// fromRpbContents converts all RpbContent in a response into Objects located at the provided
+// bucket type, bucket and key, each carrying the response's vclock
+func fromRpbContents(rpbContents []*rpbRiakKV.RpbContent, vclock []byte, bucketType, bucket, key string) ([]*Object, error) {
+	objects := make([]*Object, len(rpbContents))
+	for i, content := range rpbContents {
+		ro, err := fromRpbContent(content)
+		if err != nil {
+			return nil, err
+		}
+		ro.VClock = vclock
+		ro.BucketType = bucketType
+		ro.Bucket = bucket
+		ro.Key = key
+		objects[i] = ro
+	}
+	return objects, nil
+}
+
 func fromRpbContent(rpbContent *rpbRiakKV.RpbContent) (ro *Object, err error) {
 	// NB: ro = "Riak Object"
 	ro = &Object{
@@ -0,0 +1,89 @@
+// Copyright 2015-present Basho Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package riak
+
+import (
+	"context"
+	"time"
+)
+
+// RetryPolicy decides whether a retryable Command that failed is executed again. attempt is the
+// number of times the Command has been executed so far, so is 1 after the first failure. When
+// retry is true, the Command is executed again once backoff has elapsed. err may be nil when a
+// Cluster could find no Node able to execute the Command
+type RetryPolicy interface {
+	ShouldRetry(err error, attempt int) (retry bool, backoff time.Duration)
+}
+
+// NoRetry is a RetryPolicy that never retries
+type NoRetry struct {
+}
+
+// ShouldRetry always returns false
+func (p *NoRetry) ShouldRetry(err error, attempt int) (bool, time.Duration) {
+	return false, 0
+}
+
+// ExponentialBackoff is a RetryPolicy that retries until a Command has been executed MaxAttempts
+// times. The delay before each retry starts at MinBackoff and doubles, up to MaxBackoff if set.
+// Errors returned by Riak, such as failing to meet a quorum, are not retried, nor are
+// cancelled or expired contexts
+type ExponentialBackoff struct {
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+}
+
+// ShouldRetry returns true while fewer than MaxAttempts have been made and err is not permanent
+func (p *ExponentialBackoff) ShouldRetry(err error, attempt int) (bool, time.Duration) {
+	if attempt >= p.MaxAttempts || isPermanentError(err) {
+		return false, 0
+	}
+	backoff := p.MinBackoff
+	for i := 1; i < attempt; i++ {
+		backoff *= 2
+		if p.MaxBackoff > 0 && backoff >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	return true, backoff
+}
+
+// isPermanentError returns true for errors that executing the Command again will not resolve
+func isPermanentError(err error) bool {
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return true
+	}
+	_, ok := AsRiakError(err)
+	return ok
+}
+
+// sleepContext waits for d to elapse, returning early with ctx.Err() should ctx be done first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2015-present Basho Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package riak
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoffShouldRetry(t *testing.T) {
+	p := &ExponentialBackoff{
+		MaxAttempts: 5,
+		MinBackoff:  10 * time.Millisecond,
+		MaxBackoff:  30 * time.Millisecond,
+	}
+	transient := newClientError("transient", nil)
+	want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond, 30 * time.Millisecond}
+	for i, w := range want {
+		retry, backoff := p.ShouldRetry(transient, i+1)
+		if !retry {
+			t.Errorf("attempt %d: expected retry", i+1)
+		}
+		if got := backoff; got != w {
+			t.Errorf("attempt %d: got %v, want %v", i+1, got, w)
+		}
+	}
+	if retry, _ := p.ShouldRetry(transient, 5); retry {
+		t.Error("expected no retry once MaxAttempts is reached")
+	}
+	for _, err := range []error{RiakError{Errcode: 1, Errmsg: "{w_val_unsatisfied,2,1}"}, context.Canceled, context.DeadlineExceeded} {
+		if retry, _ := p.ShouldRetry(err, 1); retry {
+			t.Errorf("expected no retry after %v", err)
+		}
+	}
+	if retry, _ := (&NoRetry{}).ShouldRetry(transient, 1); retry {
+		t.Error("expected NoRetry to never retry")
+	}
+}
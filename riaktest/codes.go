@@ -0,0 +1,84 @@
+// Copyright 2015-present Basho Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package riaktest
+
+// Message codes of the Riak protocol buffers API, used to register and build responses
+const (
+	RpbErrorResp                byte = 0
+	RpbPingReq                  byte = 1
+	RpbPingResp                 byte = 2
+	RpbGetClientIdReq           byte = 3
+	RpbGetClientIdResp          byte = 4
+	RpbSetClientIdReq           byte = 5
+	RpbSetClientIdResp          byte = 6
+	RpbGetServerInfoReq         byte = 7
+	RpbGetServerInfoResp        byte = 8
+	RpbGetReq                   byte = 9
+	RpbGetResp                  byte = 10
+	RpbPutReq                   byte = 11
+	RpbPutResp                  byte = 12
+	RpbDelReq                   byte = 13
+	RpbDelResp                  byte = 14
+	RpbListBucketsReq           byte = 15
+	RpbListBucketsResp          byte = 16
+	RpbListKeysReq              byte = 17
+	RpbListKeysResp             byte = 18
+	RpbGetBucketReq             byte = 19
+	RpbGetBucketResp            byte = 20
+	RpbSetBucketReq             byte = 21
+	RpbSetBucketResp            byte = 22
+	RpbMapRedReq                byte = 23
+	RpbMapRedResp               byte = 24
+	RpbIndexReq                 byte = 25
+	RpbIndexResp                byte = 26
+	RpbSearchQueryReq           byte = 27
+	RpbSearchQueryResp          byte = 28
+	RpbResetBucketReq           byte = 29
+	RpbResetBucketResp          byte = 30
+	RpbGetBucketTypeReq         byte = 31
+	RpbSetBucketTypeReq         byte = 32
+	RpbGetBucketKeyPreflistReq  byte = 33
+	RpbGetBucketKeyPreflistResp byte = 34
+	RpbCSBucketReq              byte = 40
+	RpbCSBucketResp             byte = 41
+	RpbCounterUpdateReq         byte = 50
+	RpbCounterUpdateResp        byte = 51
+	RpbCounterGetReq            byte = 52
+	RpbCounterGetResp           byte = 53
+	RpbYokozunaIndexGetReq      byte = 54
+	RpbYokozunaIndexGetResp     byte = 55
+	RpbYokozunaIndexPutReq      byte = 56
+	RpbYokozunaIndexDeleteReq   byte = 57
+	RpbYokozunaSchemaGetReq     byte = 58
+	RpbYokozunaSchemaGetResp    byte = 59
+	RpbYokozunaSchemaPutReq     byte = 60
+	DtFetchReq                  byte = 80
+	DtFetchResp                 byte = 81
+	DtUpdateReq                 byte = 82
+	DtUpdateResp                byte = 83
+	TsQueryReq                  byte = 90
+	TsQueryResp                 byte = 91
+	TsPutReq                    byte = 92
+	TsPutResp                   byte = 93
+	TsDelReq                    byte = 94
+	TsDelResp                   byte = 95
+	TsGetReq                    byte = 96
+	TsGetResp                   byte = 97
+	TsListKeysReq               byte = 98
+	TsListKeysResp              byte = 99
+	RpbAuthReq                  byte = 253
+	RpbAuthResp                 byte = 254
+	RpbStartTls                 byte = 255
+)
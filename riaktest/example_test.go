@@ -0,0 +1,85 @@
+// Copyright 2015-present Basho Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package riaktest_test
+
+import (
+	"fmt"
+
+	riak "github.com/basho/riak-go-client"
+	"github.com/basho/riak-go-client/riaktest"
+	rpbRiakKV "github.com/basho/riak-go-client/rpb/riak_kv"
+)
+
+func ExampleServer() {
+	srv := riaktest.NewServer()
+	if err := srv.Start(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer srv.Stop()
+
+	getResp, err := riaktest.ProtoMessage(riaktest.RpbGetResp, &rpbRiakKV.RpbGetResp{
+		Vclock: []byte("vclock"),
+		Content: []*rpbRiakKV.RpbContent{
+			{
+				Value:       []byte("hello"),
+				ContentType: []byte("text/plain"),
+			},
+		},
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	srv.Handle(riaktest.RpbGetReq, getResp)
+
+	node, err := riak.NewNode(&riak.NodeOptions{
+		RemoteAddress:  srv.Addr(),
+		MinConnections: 1,
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	cluster, err := riak.NewCluster(&riak.ClusterOptions{
+		Nodes: []*riak.Node{node},
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := cluster.Start(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer cluster.Stop()
+
+	cmd, err := riak.NewFetchValueCommandBuilder().
+		WithBucket("bucket").
+		WithKey("key").
+		Build()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := cluster.Execute(cmd); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fvc := cmd.(*riak.FetchValueCommand)
+	obj := fvc.Response.Values[0]
+	fmt.Println(string(obj.Value), obj.ContentType)
+	// Output: hello text/plain
+}
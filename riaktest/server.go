@@ -0,0 +1,209 @@
+// Copyright 2015-present Basho Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package riaktest provides a fake Riak server that listens on a local port and answers protocol
+// buffers requests with canned responses, so that code using the Riak Go client can be tested
+// without a running Riak node
+package riaktest
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	rpbRiak "github.com/basho/riak-go-client/rpb/riak"
+	proto "github.com/golang/protobuf/proto"
+)
+
+// ErrServerNotStarted is returned by Stop when the Server has not been started
+var ErrServerNotStarted = errors.New("[riaktest] server has not been started")
+
+// Server is a fake Riak server. Responses are registered with Handle by request message code.
+// Every request received with that code is answered by writing the registered frames, in order,
+// so that streaming operations such as ListKeys can be answered with several frames. Ping and
+// set client id requests are answered with RpbPingResp and RpbSetClientIdResp unless other
+// responses are registered, and requests without a registered response are answered with
+// RpbErrorResp
+type Server struct {
+	listener  net.Listener
+	responses map[byte][][]byte
+	requests  map[byte]int
+	conns     map[net.Conn]bool
+	wg        sync.WaitGroup
+	sync.Mutex
+}
+
+// NewServer returns a Server that has not yet been started
+func NewServer() *Server {
+	s := &Server{
+		responses: make(map[byte][][]byte),
+		requests:  make(map[byte]int),
+		conns:     make(map[net.Conn]bool),
+	}
+	s.Handle(RpbPingReq, Message(RpbPingResp, nil))
+	s.Handle(RpbSetClientIdReq, Message(RpbSetClientIdResp, nil))
+	return s
+}
+
+// Start listens on a free port of 127.0.0.1 and accepts connections until Stop is called
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	s.listener = listener
+	s.Unlock()
+	s.wg.Add(1)
+	go s.accept(listener)
+	return nil
+}
+
+// Addr returns the address the Server listens on, in the form host:port, suitable for
+// NodeOptions.RemoteAddress
+func (s *Server) Addr() string {
+	s.Lock()
+	defer s.Unlock()
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Handle registers the frames written in response to each request with message code reqCode,
+// replacing any registered before. Frames are built with Message, ProtoMessage or ErrorMessage
+func (s *Server) Handle(reqCode byte, frames ...[]byte) {
+	s.Lock()
+	defer s.Unlock()
+	s.responses[reqCode] = frames
+}
+
+// Requests returns the number of requests with message code reqCode received so far
+func (s *Server) Requests(reqCode byte) int {
+	s.Lock()
+	defer s.Unlock()
+	return s.requests[reqCode]
+}
+
+// Stop closes the listener and all accepted connections, and waits for them to be released
+func (s *Server) Stop() error {
+	s.Lock()
+	listener := s.listener
+	s.listener = nil
+	if listener == nil {
+		s.Unlock()
+		return ErrServerNotStarted
+	}
+	err := listener.Close()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) accept(listener net.Listener) {
+	defer s.wg.Done()
+	for {
+		c, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		s.Lock()
+		if s.listener != listener {
+			s.Unlock()
+			c.Close()
+			return
+		}
+		s.conns[c] = true
+		s.wg.Add(1)
+		s.Unlock()
+		go s.serve(c)
+	}
+}
+
+func (s *Server) serve(c net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.Lock()
+		delete(s.conns, c)
+		s.Unlock()
+		c.Close()
+	}()
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(c, header); err != nil {
+			return
+		}
+		size := binary.BigEndian.Uint32(header)
+		if size == 0 {
+			return
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(c, data); err != nil {
+			return
+		}
+		reqCode := data[0]
+		s.Lock()
+		s.requests[reqCode]++
+		frames, ok := s.responses[reqCode]
+		s.Unlock()
+		if !ok {
+			frames = [][]byte{ErrorMessage(0, fmt.Sprintf("riaktest: no response registered for message code %d", reqCode))}
+		}
+		for _, frame := range frames {
+			if _, err := c.Write(frame); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Message frames an already encoded protocol buffers body with the message code, as sent by Riak.
+// body may be nil for responses without content, such as RpbPingResp
+func Message(code byte, body []byte) []byte {
+	frame := make([]byte, 5+len(body))
+	binary.BigEndian.PutUint32(frame, uint32(1+len(body)))
+	frame[4] = code
+	copy(frame[5:], body)
+	return frame
+}
+
+// ProtoMessage encodes msg and frames it with the message code, for example an RpbGetResp with
+// RpbGetResp
+func ProtoMessage(code byte, msg proto.Message) ([]byte, error) {
+	body, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return Message(code, body), nil
+}
+
+// ErrorMessage returns an RpbErrorResp frame with the given error code and message, which the
+// client returns as a RiakError
+func ErrorMessage(errcode uint32, errmsg string) []byte {
+	body, err := proto.Marshal(&rpbRiak.RpbErrorResp{
+		Errcode: &errcode,
+		Errmsg:  []byte(errmsg),
+	})
+	if err != nil {
+		// RpbErrorResp has only required scalar fields, which are always set
+		panic(err)
+	}
+	return Message(RpbErrorResp, body)
+}
@@ -0,0 +1,127 @@
+// Copyright 2015-present Basho Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package riaktest_test
+
+import (
+	"reflect"
+	"testing"
+
+	riak "github.com/basho/riak-go-client"
+	"github.com/basho/riak-go-client/riaktest"
+	rpbRiakKV "github.com/basho/riak-go-client/rpb/riak_kv"
+)
+
+func startCluster(t *testing.T, srv *riaktest.Server) *riak.Cluster {
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	node, err := riak.NewNode(&riak.NodeOptions{
+		RemoteAddress:  srv.Addr(),
+		MinConnections: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster, err := riak.NewCluster(&riak.ClusterOptions{
+		Nodes:             []*riak.Node{node},
+		ExecutionAttempts: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cluster.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return cluster
+}
+
+func TestServerStreamsMultipleFrames(t *testing.T) {
+	srv := riaktest.NewServer()
+	var frames [][]byte
+	for _, resp := range []*rpbRiakKV.RpbListKeysResp{
+		{Keys: [][]byte{[]byte("k1"), []byte("k2")}},
+		{Keys: [][]byte{[]byte("k3")}},
+		{Done: &[]bool{true}[0]},
+	} {
+		frame, err := riaktest.ProtoMessage(riaktest.RpbListKeysResp, resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, frame)
+	}
+	srv.Handle(riaktest.RpbListKeysReq, frames...)
+	cluster := startCluster(t, srv)
+	defer srv.Stop()
+	defer cluster.Stop()
+
+	cmd, err := riak.NewListKeysCommandBuilder().
+		WithAllowListing().
+		WithBucket("bucket").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cluster.Execute(cmd); err != nil {
+		t.Fatal(err)
+	}
+	lkc := cmd.(*riak.ListKeysCommand)
+	if got, want := lkc.Response.Keys, []string{"k1", "k2", "k3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := srv.Requests(riaktest.RpbListKeysReq), 1; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestServerRespondsWithRiakError(t *testing.T) {
+	srv := riaktest.NewServer()
+	srv.Handle(riaktest.RpbGetReq, riaktest.ErrorMessage(1, "overload"))
+	cluster := startCluster(t, srv)
+	defer srv.Stop()
+	defer cluster.Stop()
+
+	cmd, err := riak.NewFetchValueCommandBuilder().
+		WithBucket("bucket").
+		WithKey("key").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rerr, ok := riak.AsRiakError(cluster.Execute(cmd))
+	if !ok {
+		t.Fatalf("expected RiakError, got %v", cmd.Error())
+	}
+	if got, want := rerr.Errmsg, "overload"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestServerRespondsToUnregisteredCodeWithError(t *testing.T) {
+	srv := riaktest.NewServer()
+	cluster := startCluster(t, srv)
+	defer srv.Stop()
+	defer cluster.Stop()
+
+	cmd, err := riak.NewStoreValueCommandBuilder().
+		WithBucket("bucket").
+		WithContent(&riak.Object{Value: []byte("v")}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := riak.AsRiakError(cluster.Execute(cmd)); !ok {
+		t.Errorf("expected RiakError, got %v", cmd.Error())
+	}
+}
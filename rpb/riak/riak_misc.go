@@ -28,6 +28,10 @@ func (m *RpbGetBucketTypeReq) BucketIsRequired() bool {
 	return false
 }
 
+func (m *RpbGetBucketTypeReq) BucketTypeIsRequired() bool {
+	return true
+}
+
 func (m *RpbGetBucketTypeReq) GetBucket() []byte {
 	return nil
 }
@@ -50,6 +54,10 @@ func (m *RpbGetBucketReq) BucketIsRequired() bool {
 	return true
 }
 
+func (m *RpbGetBucketReq) BucketTypeIsRequired() bool {
+	return false
+}
+
 func (m *RpbGetBucketReq) KeyIsRequired() bool {
 	return false
 }
@@ -68,6 +76,10 @@ func (m *RpbSetBucketTypeReq) BucketIsRequired() bool {
 	return false
 }
 
+func (m *RpbSetBucketTypeReq) BucketTypeIsRequired() bool {
+	return true
+}
+
 func (m *RpbSetBucketTypeReq) GetBucket() []byte {
 	return nil
 }
@@ -90,6 +102,10 @@ func (m *RpbSetBucketReq) BucketIsRequired() bool {
 	return true
 }
 
+func (m *RpbSetBucketReq) BucketTypeIsRequired() bool {
+	return false
+}
+
 func (m *RpbSetBucketReq) KeyIsRequired() bool {
 	return false
 }
@@ -108,6 +124,10 @@ func (m *RpbResetBucketReq) BucketIsRequired() bool {
 	return true
 }
 
+func (m *RpbResetBucketReq) BucketTypeIsRequired() bool {
+	return false
+}
+
 func (m *RpbResetBucketReq) KeyIsRequired() bool {
 	return false
 }
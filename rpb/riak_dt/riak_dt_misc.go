@@ -24,6 +24,10 @@ func (m *DtUpdateReq) BucketIsRequired() bool {
 	return true
 }
 
+func (m *DtUpdateReq) BucketTypeIsRequired() bool {
+	return true
+}
+
 func (m *DtUpdateReq) KeyIsRequired() bool {
 	return false
 }
@@ -38,6 +42,10 @@ func (m *DtFetchReq) BucketIsRequired() bool {
 	return true
 }
 
+func (m *DtFetchReq) BucketTypeIsRequired() bool {
+	return true
+}
+
 func (m *DtFetchReq) KeyIsRequired() bool {
 	return true
 }
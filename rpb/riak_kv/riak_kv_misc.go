@@ -24,6 +24,10 @@ func (m *RpbGetReq) BucketIsRequired() bool {
 	return true
 }
 
+func (m *RpbGetReq) BucketTypeIsRequired() bool {
+	return false
+}
+
 func (m *RpbGetReq) KeyIsRequired() bool {
 	return true
 }
@@ -38,6 +42,10 @@ func (m *RpbPutReq) BucketIsRequired() bool {
 	return true
 }
 
+func (m *RpbPutReq) BucketTypeIsRequired() bool {
+	return false
+}
+
 func (m *RpbPutReq) KeyIsRequired() bool {
 	return false
 }
@@ -52,6 +60,10 @@ func (m *RpbDelReq) BucketIsRequired() bool {
 	return true
 }
 
+func (m *RpbDelReq) BucketTypeIsRequired() bool {
+	return false
+}
+
 func (m *RpbDelReq) KeyIsRequired() bool {
 	return true
 }
@@ -66,6 +78,10 @@ func (m *RpbListBucketsReq) BucketIsRequired() bool {
 	return false
 }
 
+func (m *RpbListBucketsReq) BucketTypeIsRequired() bool {
+	return false
+}
+
 func (m *RpbListBucketsReq) GetBucket() []byte {
 	return nil
 }
@@ -88,6 +104,10 @@ func (m *RpbListKeysReq) BucketIsRequired() bool {
 	return true
 }
 
+func (m *RpbListKeysReq) BucketTypeIsRequired() bool {
+	return false
+}
+
 func (m *RpbListKeysReq) KeyIsRequired() bool {
 	return false
 }
@@ -106,6 +126,10 @@ func (m *RpbGetBucketKeyPreflistReq) BucketIsRequired() bool {
 	return true
 }
 
+func (m *RpbGetBucketKeyPreflistReq) BucketTypeIsRequired() bool {
+	return false
+}
+
 func (m *RpbGetBucketKeyPreflistReq) KeyIsRequired() bool {
 	return true
 }
@@ -120,6 +144,10 @@ func (m *RpbIndexReq) BucketIsRequired() bool {
 	return true
 }
 
+func (m *RpbIndexReq) BucketTypeIsRequired() bool {
+	return false
+}
+
 func (m *RpbIndexReq) KeyIsRequired() bool {
 	return false
 }
@@ -137,6 +165,10 @@ func (m *RpbCounterUpdateReq) BucketIsRequired() bool {
 	return true
 }
 
+func (m *RpbCounterUpdateReq) BucketTypeIsRequired() bool {
+	return false
+}
+
 func (m *RpbCounterUpdateReq) KeyIsRequired() bool {
 	return true
 }
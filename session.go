@@ -0,0 +1,100 @@
+// Copyright 2015-present Basho Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package riak
+
+import (
+	"context"
+	"sync"
+)
+
+// ErrSessionClosed is returned when executing a Command via a Session that has been closed, or
+// whose connection has failed
+var ErrSessionClosed = newClientError("[Session] session is closed", nil)
+
+// Session holds one connection from a Node's pool so that Commands executed via the Session are
+// sent to Riak in order on the same connection, for example to fetch a value and then store it
+// with the returned vclock. Should the connection fail, the Session is closed and later Commands
+// return ErrSessionClosed. The connection is returned to the pool by Close, which must be called
+type Session struct {
+	node *Node
+	conn *connection
+	sync.Mutex
+}
+
+// Session retrieves a connection from the pool and returns a Session holding it
+func (n *Node) Session() (*Session, error) {
+	if err := n.stateCheck(nodeRunning); err != nil {
+		return nil, err
+	}
+	conn, err := n.cm.get()
+	if err != nil {
+		n.log.err("[Node]", err)
+		if err != ErrConnMgrAllConnectionsInUse {
+			n.doHealthCheck(err)
+		}
+		return nil, err
+	}
+	return &Session{
+		node: n,
+		conn: conn,
+	}, nil
+}
+
+// Execute executes the Command against Riak on the connection held by the Session
+func (s *Session) Execute(cmd Command) error {
+	return s.ExecuteContext(context.Background(), cmd)
+}
+
+// ExecuteContext is the same as Execute, but will abandon the Command if ctx is cancelled or
+// reaches its deadline, which closes the Session
+func (s *Session) ExecuteContext(ctx context.Context, cmd Command) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.conn == nil {
+		return ErrSessionClosed
+	}
+	if rc, ok := cmd.(retryableCommand); ok {
+		rc.setLastNode(s.node)
+	}
+	if nc, ok := cmd.(nodeCommand); ok {
+		nc.setNode(s.node)
+	}
+	n := s.node
+	n.log.debug("[Node]", "(%v) - executing command '%v' in session", n, cmd.Name())
+	err := s.conn.executeContext(ctx, cmd)
+	if err != nil && (isConnectionError(ctx, err) || !s.conn.available()) {
+		if cmErr := n.cm.remove(s.conn); cmErr != nil {
+			n.log.err("[Node]", cmErr)
+		}
+		s.conn = nil
+		if isConnectionError(ctx, err) && !isTemporaryNetError(err) {
+			n.doHealthCheck(err)
+		}
+	}
+	return err
+}
+
+// Close returns the connection held by the Session to the pool. Closing a closed Session has no
+// effect
+func (s *Session) Close() error {
+	s.Lock()
+	defer s.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	conn := s.conn
+	s.conn = nil
+	return s.node.cm.put(conn)
+}
@@ -27,6 +27,7 @@ type stateful interface {
 	isCurrentState(st state) (rv bool)
 	isStateLessThan(st state) (rv bool)
 	setState(st state)
+	setStateIfLessThan(st state) (rv bool)
 	getState() (st state)
 	stateCheck(allowed ...state) (err error)
 }
@@ -48,7 +49,14 @@ func (s *stateData) initStateData(desc ...string) {
 }
 
 func (s *stateData) String() string {
-	stateIdx := int(s.stateVal)
+	s.RLock()
+	defer s.RUnlock()
+	return s.describe(s.stateVal)
+}
+
+// describe returns the description of st. It does not lock, so may be called from setStateFunc
+func (s *stateData) describe(st state) string {
+	stateIdx := int(st)
 	if len(s.stateDesc) > stateIdx {
 		return s.stateDesc[stateIdx]
 	} else {
@@ -80,6 +88,18 @@ func (s *stateData) setState(st state) {
 	s.setStateFunc(s, st)
 }
 
+// setStateIfLessThan sets the state to st only if the current state is less than st. The check
+// and set are atomic, so exactly one of several concurrent callers will set the state
+func (s *stateData) setStateIfLessThan(st state) bool {
+	s.Lock()
+	defer s.Unlock()
+	if s.stateVal < st {
+		s.setStateFunc(s, st)
+		return true
+	}
+	return false
+}
+
 func (s *stateData) stateCheck(allowed ...state) error {
 	s.RLock()
 	defer s.RUnlock()
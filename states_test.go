@@ -100,3 +100,19 @@ func TestStateDescUnknown(t *testing.T) {
 		t.Errorf("expected %v, got %v", expected, actual)
 	}
 }
+
+func TestSetStateIfLessThan(t *testing.T) {
+	data := &testStateData{}
+	data.initStateData("STATE_ONE", "STATE_TWO", "STATE_THREE")
+	data.setState(STATE_ONE)
+
+	if expected, actual := true, data.setStateIfLessThan(STATE_TWO); expected != actual {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+	if expected, actual := false, data.setStateIfLessThan(STATE_TWO); expected != actual {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+	if expected, actual := STATE_TWO, data.getState(); expected != actual {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
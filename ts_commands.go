@@ -30,9 +30,13 @@ type TsCell struct {
 	cell       *riak_ts.TsCell
 }
 
-// GetDataType returns the data type of the value stored within the cell
+// GetDataType returns the data type of the value stored within the cell, or an empty string
+// if the cell is null
 func (c *TsCell) GetDataType() string {
 	var dType string
+	if c.IsNull() {
+		return dType
+	}
 	switch {
 	case c.cell.VarcharValue != nil:
 		switch c.columnType {
@@ -56,6 +60,16 @@ func (c *TsCell) GetDataType() string {
 	return dType
 }
 
+// IsNull returns true if the cell has no value
+func (c *TsCell) IsNull() bool {
+	return c.cell == nil ||
+		(c.cell.VarcharValue == nil &&
+			c.cell.Sint64Value == nil &&
+			c.cell.TimestampValue == nil &&
+			c.cell.BooleanValue == nil &&
+			c.cell.DoubleValue == nil)
+}
+
 // GetStringValue returns the string value stored within the cell
 func (c *TsCell) GetStringValue() string {
 	return string(c.cell.GetVarcharValue())
@@ -99,6 +113,12 @@ func (c *TsCell) setCell(tsc *riak_ts.TsCell, tsct riak_ts.TsColumnType) {
 	c.columnType = tsct
 }
 
+// NewNullTsCell creates a TsCell with no value, for a nullable column. The zero value of TsCell
+// is also null
+func NewNullTsCell() TsCell {
+	return TsCell{cell: &riak_ts.TsCell{}}
+}
+
 // NewStringTsCell creates a TsCell from a string
 func NewStringTsCell(v string) TsCell {
 	tsc := riak_ts.TsCell{VarcharValue: []byte(v)}
@@ -285,25 +305,20 @@ func (cmd *TsFetchRowCommand) onSuccess(msg proto.Message) error {
 		if tsTsFetchRowResp, ok := msg.(*riak_ts.TsGetResp); ok {
 			tsCols := tsTsFetchRowResp.GetColumns()
 			tsRows := tsTsFetchRowResp.GetRows()
-			if tsCols != nil && tsRows != nil {
-				cmd.Response = &TsFetchRowResponse{
-					IsNotFound: false,
-					Columns:    make([]TsColumnDescription, 0),
-					Row:        make([]TsCell, 0),
-				}
+			cmd.Response = &TsFetchRowResponse{
+				IsNotFound: len(tsRows) == 0,
+				Columns:    make([]TsColumnDescription, 0, len(tsCols)),
+				Row:        make([]TsCell, 0),
+			}
 
-				for _, tsCol := range tsCols {
-					col.setColumn(tsCol)
-					cmd.Response.Columns = append(cmd.Response.Columns, col)
-				}
+			for _, tsCol := range tsCols {
+				col.setColumn(tsCol)
+				cmd.Response.Columns = append(cmd.Response.Columns, col)
+			}
 
-				// grab only the first row if any
-				rows := convertFromPbTsRows(tsRows, tsCols)
-				if len(rows) > 0 {
-					cmd.Response.Row = rows[0]
-				} else {
-					return fmt.Errorf("[TsFetchRowCommand] could not retrieve row from %v to TsGetResp", reflect.TypeOf(msg))
-				}
+			// grab only the first row if any
+			if rows := convertFromPbTsRows(tsRows, tsCols); len(rows) > 0 {
+				cmd.Response.Row = rows[0]
 			}
 		} else {
 			return fmt.Errorf("[TsFetchRowCommand] could not convert %v to TsGetResp", reflect.TypeOf(msg))
@@ -512,6 +527,7 @@ type TsQueryCommand struct {
 	protobuf *riak_ts.TsQueryReq
 	callback func([][]TsCell) error
 	done     bool
+	columns  []*riak_ts.TsColumnDescription
 }
 
 // Name identifies this command
@@ -523,6 +539,11 @@ func (cmd *TsQueryCommand) constructPbRequest() (proto.Message, error) {
 	return cmd.protobuf, nil
 }
 
+func (cmd *TsQueryCommand) isDone() bool {
+	// NB: a non-streaming query is answered by a single TsQueryResp
+	return !cmd.protobuf.GetStream() || cmd.done
+}
+
 func (cmd *TsQueryCommand) onSuccess(msg proto.Message) error {
 	cmd.success = true
 	var col TsColumnDescription
@@ -539,22 +560,19 @@ func (cmd *TsQueryCommand) onSuccess(msg proto.Message) error {
 
 			cmd.done = queryResp.GetDone()
 
-			tsCols := queryResp.GetColumns()
-			tsRows := queryResp.GetRows()
-			if tsCols != nil && tsRows != nil {
-				if tsCols != nil && response.Columns == nil {
-					response.Columns = make([]TsColumnDescription, 0)
-				}
-				if tsRows != nil && response.Rows == nil {
-					response.Rows = make([][]TsCell, 0)
-				}
-
+			// NB: when streaming, columns may only be sent with the first response, yet are
+			// needed to type the cells of every subsequent response
+			if tsCols := queryResp.GetColumns(); len(tsCols) > 0 && cmd.columns == nil {
+				cmd.columns = tsCols
+				response.Columns = make([]TsColumnDescription, 0, len(tsCols))
 				for _, tsCol := range tsCols {
 					col.setColumn(tsCol)
 					response.Columns = append(response.Columns, col)
 				}
+			}
 
-				rows := convertFromPbTsRows(tsRows, tsCols)
+			if tsRows := queryResp.GetRows(); len(tsRows) > 0 {
+				rows := convertFromPbTsRows(tsRows, cmd.columns)
 
 				if cmd.protobuf.GetStream() {
 					if cmd.callback == nil {
@@ -566,6 +584,9 @@ func (cmd *TsQueryCommand) onSuccess(msg proto.Message) error {
 						}
 					}
 				} else {
+					if response.Rows == nil {
+						response.Rows = make([][]TsCell, 0, len(rows))
+					}
 					response.Rows = append(response.Rows, rows...)
 				}
 			}
@@ -859,7 +880,12 @@ func convertFromTsRows(tsRows [][]TsCell) []*riak_ts.TsRow {
 		cells = make([]*riak_ts.TsCell, 0)
 
 		for _, tsCell := range tsRow {
-			cells = append(cells, tsCell.cell)
+			if tsCell.cell == nil {
+				// NB: Riak TS represents null as a cell with no value set
+				cells = append(cells, &riak_ts.TsCell{})
+			} else {
+				cells = append(cells, tsCell.cell)
+			}
 		}
 
 		if len(rows) < 1 {
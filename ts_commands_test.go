@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/basho/riak-go-client/rpb/riak_ts"
+	proto "github.com/golang/protobuf/proto"
 )
 
 func TestBuildTsGetReqCorrectlyViaBuilder(t *testing.T) {
@@ -105,6 +106,122 @@ func TestBuildTsDelReqCorrectlyViaBuilder(t *testing.T) {
 	}
 }
 
+func TestTsGetAndDelReqEncodeKeyCells(t *testing.T) {
+	key := []TsCell{
+		NewStringTsCell("South Atlantic"),
+		NewSint64TsCell(7),
+		NewTimestampTsCellFromInt64(1420113600000),
+	}
+	wantKey := []*riak_ts.TsCell{
+		{VarcharValue: []byte("South Atlantic")},
+		{Sint64Value: proto.Int64(7)},
+		{TimestampValue: proto.Int64(1420113600000)},
+	}
+
+	get, err := NewTsFetchRowCommandBuilder().WithTable("table_name").WithKey(key).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := getRiakMessage(get)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := data[4], rpbCode_TsGetReq; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	getReq := &riak_ts.TsGetReq{}
+	if err = proto.Unmarshal(data[5:], getReq); err != nil {
+		t.Fatal(err)
+	}
+	if want := (&riak_ts.TsGetReq{Table: []byte("table_name"), Key: wantKey}); !proto.Equal(getReq, want) {
+		t.Errorf("got %v, want %v", getReq, want)
+	}
+
+	del, err := NewTsDeleteRowCommandBuilder().WithTable("table_name").WithKey(key).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err = getRiakMessage(del); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := data[4], rpbCode_TsDelReq; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	delReq := &riak_ts.TsDelReq{}
+	if err = proto.Unmarshal(data[5:], delReq); err != nil {
+		t.Fatal(err)
+	}
+	if want := (&riak_ts.TsDelReq{Table: []byte("table_name"), Key: wantKey}); !proto.Equal(delReq, want) {
+		t.Errorf("got %v, want %v", delReq, want)
+	}
+	if err = del.onSuccess(nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := del.(*TsDeleteRowCommand).Response, true; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err = NewTsFetchRowCommandBuilder().WithTable("table_name").WithKey([]TsCell{}).Build(); err != ErrKeyRequired {
+		t.Errorf("got %v, want %v", err, ErrKeyRequired)
+	}
+	if _, err = NewTsDeleteRowCommandBuilder().WithTable("table_name").Build(); err != ErrKeyRequired {
+		t.Errorf("got %v, want %v", err, ErrKeyRequired)
+	}
+}
+
+func TestParseTsGetRespRowAndNotFound(t *testing.T) {
+	key := []TsCell{NewStringTsCell("South Atlantic")}
+	cols := []*riak_ts.TsColumnDescription{
+		{Name: []byte("region"), Type: riak_ts.TsColumnType_VARCHAR.Enum()},
+		{Name: []byte("temperature"), Type: riak_ts.TsColumnType_DOUBLE.Enum()},
+	}
+
+	cmd, err := NewTsFetchRowCommandBuilder().WithTable("table_name").WithKey(key).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmd.onSuccess(&riak_ts.TsGetResp{
+		Columns: cols,
+		Rows: []*riak_ts.TsRow{
+			{Cells: []*riak_ts.TsCell{{VarcharValue: []byte("South Atlantic")}, {DoubleValue: proto.Float64(23.5)}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp := cmd.(*TsFetchRowCommand).Response
+	if rsp.IsNotFound {
+		t.Error("expected row to be found")
+	}
+	if got, want := len(rsp.Columns), 2; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := len(rsp.Row), 2; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := rsp.Row[1].GetDoubleValue(), 23.5; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// NB: Riak TS may answer with the columns but no rows, or an empty message
+	for _, msg := range []proto.Message{&riak_ts.TsGetResp{Columns: cols}, nil} {
+		cmd, err = NewTsFetchRowCommandBuilder().WithTable("table_name").WithKey(key).Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = cmd.onSuccess(msg); err != nil {
+			t.Fatal(err)
+		}
+		rsp = cmd.(*TsFetchRowCommand).Response
+		if !rsp.IsNotFound {
+			t.Errorf("%v: expected not found", msg)
+		}
+		if got, want := len(rsp.Row), 0; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
+
 func TestBuildTsPutReqCorrectlyViaBuilder(t *testing.T) {
 	row := make([]TsCell, 5)
 
@@ -152,6 +269,72 @@ func TestBuildTsPutReqCorrectlyViaBuilder(t *testing.T) {
 	}
 }
 
+func TestTsPutReqEncodesMixedRowsIncludingNulls(t *testing.T) {
+	rows := [][]TsCell{
+		{NewStringTsCell("a"), NewSint64TsCell(-1), NewDoubleTsCell(0.5), NewBooleanTsCell(true), NewTimestampTsCellFromInt64(1000)},
+		{NewStringTsCell("b"), NewNullTsCell(), TsCell{}, NewBooleanTsCell(false), NewTimestampTsCellFromInt64(2000)},
+		{NewBlobTsCell([]byte{0, 1}), NewSint64TsCell(3), NewDoubleTsCell(-2.5), NewNullTsCell(), NewTimestampTsCellFromInt64(3000)},
+	}
+	cmd, err := NewTsStoreRowsCommandBuilder().
+		WithTable("table_name").
+		WithRows(rows).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := getRiakMessage(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := data[4], rpbCode_TsPutReq; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	req := &riak_ts.TsPutReq{}
+	if err = proto.Unmarshal(data[5:], req); err != nil {
+		t.Fatal(err)
+	}
+	want := &riak_ts.TsPutReq{
+		Table: []byte("table_name"),
+		Rows: []*riak_ts.TsRow{
+			{Cells: []*riak_ts.TsCell{
+				{VarcharValue: []byte("a")},
+				{Sint64Value: proto.Int64(-1)},
+				{DoubleValue: proto.Float64(0.5)},
+				{BooleanValue: proto.Bool(true)},
+				{TimestampValue: proto.Int64(1000)},
+			}},
+			{Cells: []*riak_ts.TsCell{
+				{VarcharValue: []byte("b")},
+				{},
+				{},
+				{BooleanValue: proto.Bool(false)},
+				{TimestampValue: proto.Int64(2000)},
+			}},
+			{Cells: []*riak_ts.TsCell{
+				{VarcharValue: []byte{0, 1}},
+				{Sint64Value: proto.Int64(3)},
+				{DoubleValue: proto.Float64(-2.5)},
+				{},
+				{TimestampValue: proto.Int64(3000)},
+			}},
+		},
+	}
+	if !proto.Equal(req, want) {
+		t.Errorf("got %v, want %v", req, want)
+	}
+
+	null := NewNullTsCell()
+	if !null.IsNull() || !(&TsCell{}).IsNull() {
+		t.Error("expected null cells")
+	}
+	if got, want := null.GetDataType(), ""; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if rows[0][0].IsNull() {
+		t.Error("expected non-null cell")
+	}
+}
+
 func TestBuildTsQueryReqCorrectlyViaBuilder(t *testing.T) {
 
 	builder := NewTsQueryCommandBuilder().
@@ -210,6 +393,127 @@ func TestBuildTsQueryReqCorrectlyViaBuilder(t *testing.T) {
 }
 
 // ListKeys
+func TestParseTsQueryRespColumnsAndRows(t *testing.T) {
+	resp := &riak_ts.TsQueryResp{
+		Columns: []*riak_ts.TsColumnDescription{
+			{Name: []byte("time"), Type: riak_ts.TsColumnType_TIMESTAMP.Enum()},
+			{Name: []byte("data"), Type: riak_ts.TsColumnType_BLOB.Enum()},
+		},
+		Rows: []*riak_ts.TsRow{
+			{Cells: []*riak_ts.TsCell{{TimestampValue: proto.Int64(1000)}, {VarcharValue: []byte{1}}}},
+			{Cells: []*riak_ts.TsCell{{TimestampValue: proto.Int64(2000)}, {}}},
+			{Cells: []*riak_ts.TsCell{{TimestampValue: proto.Int64(3000)}, {VarcharValue: []byte{3}}}},
+		},
+	}
+	encoded, err := proto.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd, err := NewTsQueryCommandBuilder().
+		WithQuery("select time, data from table_name").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := decodeRiakMessage(cmd, append([]byte{rpbCode_TsQueryResp}, encoded...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cmd.onSuccess(msg); err != nil {
+		t.Fatal(err)
+	}
+	qc := cmd.(*TsQueryCommand)
+	if !qc.isDone() {
+		t.Error("expected non-streaming query to be done")
+	}
+
+	cols := qc.Response.Columns
+	if got, want := len(cols), 2; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := cols[0].GetName()+" "+cols[0].GetType(), "time TIMESTAMP"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := cols[1].GetName()+" "+cols[1].GetType(), "data BLOB"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	rows := qc.Response.Rows
+	if got, want := len(rows), 3; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i, row := range rows {
+		if got, want := row[0].GetTimestampValue(), int64(1000*(i+1)); got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+	if got, want := rows[0][1].GetDataType(), "BLOB"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if !rows[1][1].IsNull() {
+		t.Error("expected null cell")
+	}
+}
+
+func TestParseStreamingTsQueryRespUsesColumnsFromFirstResponse(t *testing.T) {
+	var streamed [][]TsCell
+	cmd, err := NewTsQueryCommandBuilder().
+		WithQuery("select time, data from table_name").
+		WithStreaming(true).
+		WithCallback(func(rows [][]TsCell) error {
+			streamed = append(streamed, rows...)
+			return nil
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	qc := cmd.(*TsQueryCommand)
+
+	first := &riak_ts.TsQueryResp{
+		Columns: []*riak_ts.TsColumnDescription{
+			{Name: []byte("time"), Type: riak_ts.TsColumnType_TIMESTAMP.Enum()},
+			{Name: []byte("data"), Type: riak_ts.TsColumnType_BLOB.Enum()},
+		},
+		Rows: []*riak_ts.TsRow{
+			{Cells: []*riak_ts.TsCell{{TimestampValue: proto.Int64(1000)}, {VarcharValue: []byte{1}}}},
+		},
+		Done: proto.Bool(false),
+	}
+	last := &riak_ts.TsQueryResp{
+		Rows: []*riak_ts.TsRow{
+			{Cells: []*riak_ts.TsCell{{TimestampValue: proto.Int64(2000)}, {VarcharValue: []byte{2}}}},
+			{Cells: []*riak_ts.TsCell{{TimestampValue: proto.Int64(3000)}, {VarcharValue: []byte{3}}}},
+		},
+		Done: proto.Bool(true),
+	}
+	if err = cmd.onSuccess(first); err != nil {
+		t.Fatal(err)
+	}
+	if qc.isDone() {
+		t.Error("expected streaming query not to be done")
+	}
+	if err = cmd.onSuccess(last); err != nil {
+		t.Fatal(err)
+	}
+	if !qc.isDone() {
+		t.Error("expected streaming query to be done")
+	}
+
+	if got, want := len(qc.Response.Columns), 2; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := len(streamed), 3; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for _, row := range streamed {
+		if got, want := row[1].GetDataType(), "BLOB"; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
+
 func TestTsListKeysErrorsViaBuilder(t *testing.T) {
 	cb := func(keys [][]TsCell) error {
 		return nil
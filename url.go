@@ -0,0 +1,195 @@
+// Copyright 2015-present Basho Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package riak
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ErrURLInvalidScheme   = "[URL] unsupported scheme '%s', expected 'riak' or 'riaks'"
+	ErrURLUnknownOption   = "[URL] unknown option '%s'"
+	ErrURLInvalidOption   = "[URL] invalid value '%s' for option '%s'"
+	ErrURLDuplicateOption = "[URL] option '%s' specified more than once"
+)
+
+var (
+	ErrURLHostRequired        = newClientError("[URL] host is required", nil)
+	ErrURLCredentialsNeedTLS  = newClientError("[URL] credentials require the 'riaks' scheme, as Riak only authenticates TLS connections", nil)
+	ErrURLTLSNeedsCredentials = newClientError("[URL] the 'riaks' scheme requires user credentials", nil)
+	ErrURLNodeRequiresOneHost = newClientError("[URL] a Node URL must have exactly one host, use NewClusterFromURL for several", nil)
+)
+
+// NewNodeFromURL returns a Node configured from a URL of the form
+//
+//	riak://[user:password@]host[:port][?option=value&...]
+//
+// The 'riaks' scheme enables TLS, which Riak requires for authentication. Supported options are
+// min_connections, max_connections, temp_net_error_retries, idle_timeout,
+// idle_expiration_interval, connect_timeout, request_timeout, health_check_interval and
+// max_health_check_interval. Durations are in the
+// form accepted by time.ParseDuration, e.g. 30s
+func NewNodeFromURL(rawurl string) (*Node, error) {
+	opts, err := parseNodeURL(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts) != 1 {
+		return nil, ErrURLNodeRequiresOneHost
+	}
+	return NewNode(opts[0])
+}
+
+// NewClusterFromURL returns a Cluster with a Node for each of the comma-separated hosts of a URL
+// in the form accepted by NewNodeFromURL, e.g.
+//
+//	riak://riak1:8087,riak2:8087,riak3?max_connections=50
+func NewClusterFromURL(rawurl string) (*Cluster, error) {
+	opts, err := parseNodeURL(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]*Node, len(opts))
+	for i, o := range opts {
+		if nodes[i], err = NewNode(o); err != nil {
+			return nil, err
+		}
+	}
+	return NewCluster(&ClusterOptions{
+		Nodes: nodes,
+	})
+}
+
+// parseNodeURL returns the NodeOptions for each host of the URL
+func parseNodeURL(rawurl string) ([]*NodeOptions, error) {
+	// NB: url.Parse does not accept several hosts, so they are split out beforehand
+	hosts, rawurl := splitURLHosts(rawurl)
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	var authOptions *AuthOptions
+	switch u.Scheme {
+	case "riak":
+		if u.User != nil {
+			return nil, ErrURLCredentialsNeedTLS
+		}
+	case "riaks":
+		if u.User == nil || u.User.Username() == "" {
+			return nil, ErrURLTLSNeedsCredentials
+		}
+		password, _ := u.User.Password()
+		authOptions = &AuthOptions{
+			User:      u.User.Username(),
+			Password:  password,
+			TlsConfig: &tls.Config{},
+		}
+	default:
+		return nil, newClientError(fmt.Sprintf(ErrURLInvalidScheme, u.Scheme), nil)
+	}
+
+	template := &NodeOptions{
+		AuthOptions: authOptions,
+	}
+	if err = parseNodeURLOptions(u.RawQuery, template); err != nil {
+		return nil, err
+	}
+
+	opts := make([]*NodeOptions, len(hosts))
+	for i, host := range hosts {
+		if host == "" {
+			return nil, ErrURLHostRequired
+		}
+		// NB: NewNode appends the default port when the host has none
+		o := *template
+		o.RemoteAddress = host
+		opts[i] = &o
+	}
+	return opts, nil
+}
+
+// splitURLHosts returns the comma-separated hosts of the URL, and the URL with only the first
+// of them
+func splitURLHosts(rawurl string) ([]string, string) {
+	i := strings.Index(rawurl, "://")
+	if i < 0 {
+		return []string{""}, rawurl
+	}
+	start := i + len("://")
+	authority := rawurl[start:]
+	if j := strings.IndexAny(authority, "/?#"); j >= 0 {
+		authority = authority[:j]
+	}
+	userinfo, hostList := "", authority
+	if j := strings.LastIndex(authority, "@"); j >= 0 {
+		userinfo, hostList = authority[:j+1], authority[j+1:]
+	}
+	hosts := strings.Split(hostList, ",")
+	return hosts, rawurl[:start] + userinfo + hosts[0] + rawurl[start+len(authority):]
+}
+
+func parseNodeURLOptions(rawQuery string, o *NodeOptions) error {
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return err
+	}
+	for name, values := range query {
+		if len(values) > 1 {
+			return newClientError(fmt.Sprintf(ErrURLDuplicateOption, name), nil)
+		}
+		value := values[0]
+		switch name {
+		case "min_connections":
+			err = parseURLUint16(value, &o.MinConnections)
+		case "max_connections":
+			err = parseURLUint16(value, &o.MaxConnections)
+		case "temp_net_error_retries":
+			err = parseURLUint16(value, &o.TempNetErrorRetries)
+		case "idle_timeout":
+			o.IdleTimeout, err = time.ParseDuration(value)
+		case "idle_expiration_interval":
+			o.IdleExpirationInterval, err = time.ParseDuration(value)
+		case "connect_timeout":
+			o.ConnectTimeout, err = time.ParseDuration(value)
+		case "request_timeout":
+			o.RequestTimeout, err = time.ParseDuration(value)
+		case "health_check_interval":
+			o.HealthCheckInterval, err = time.ParseDuration(value)
+		case "max_health_check_interval":
+			o.MaxHealthCheckInterval, err = time.ParseDuration(value)
+		default:
+			return newClientError(fmt.Sprintf(ErrURLUnknownOption, name), nil)
+		}
+		if err != nil {
+			return newClientError(fmt.Sprintf(ErrURLInvalidOption, value, name), err)
+		}
+	}
+	return nil
+}
+
+func parseURLUint16(value string, v *uint16) error {
+	i, err := strconv.ParseUint(value, 10, 16)
+	if err != nil {
+		return err
+	}
+	*v = uint16(i)
+	return nil
+}
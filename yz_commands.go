@@ -331,6 +331,12 @@ func (cmd *StoreSchemaCommand) getResponseProtobufMessage() proto.Message {
 	return nil
 }
 
+// StoreSchemaCommandBuilder errors
+var (
+	ErrStoreSchemaNameRequired    = newClientError("[StoreSchemaCommand] schema name is required", nil)
+	ErrStoreSchemaContentRequired = newClientError("[StoreSchemaCommand] schema content is required", nil)
+)
+
 // StoreSchemaCommandBuilder type is required for creating new instances of StoreSchemaCommand
 //
 //	command, err := NewStoreSchemaCommandBuilder().
@@ -367,6 +373,12 @@ func (builder *StoreSchemaCommandBuilder) Build() (Command, error) {
 	if builder.protobuf == nil {
 		panic("builder.protobuf must not be nil")
 	}
+	if len(builder.protobuf.Schema.GetName()) == 0 {
+		return nil, ErrStoreSchemaNameRequired
+	}
+	if len(builder.protobuf.Schema.GetContent()) == 0 {
+		return nil, ErrStoreSchemaContentRequired
+	}
 	return &StoreSchemaCommand{protobuf: builder.protobuf}, nil
 }
 
@@ -650,3 +662,11 @@ func (builder *SearchCommandBuilder) Build() (Command, error) {
 	}
 	return &SearchCommand{protobuf: builder.protobuf}, nil
 }
+
+// buildPage builds a command for numRows documents starting at start, leaving the builder unchanged
+func (builder *SearchCommandBuilder) buildPage(start, numRows uint32) *SearchCommand {
+	protobuf := proto.Clone(builder.protobuf).(*rpbRiakSCH.RpbSearchQueryReq)
+	protobuf.Start = &start
+	protobuf.Rows = &numRows
+	return &SearchCommand{protobuf: protobuf}
+}
@@ -15,6 +15,7 @@
 package riak
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
 	"time"
@@ -22,6 +23,7 @@ import (
 	rpbRiak "github.com/basho/riak-go-client/rpb/riak"
 	rpbRiakSCH "github.com/basho/riak-go-client/rpb/riak_search"
 	rpbRiakYZ "github.com/basho/riak-go-client/rpb/riak_yokozuna"
+	proto "github.com/golang/protobuf/proto"
 )
 
 // StoreIndex
@@ -166,6 +168,92 @@ func TestBuildRpbYokozunaIndexDeleteReqCorrectlyViaBuilder(t *testing.T) {
 	}
 }
 
+func TestIndexCommandRequestBytes(t *testing.T) {
+	store, err := NewStoreIndexCommandBuilder().
+		WithIndexName("indexName").
+		WithSchemaName("schemaName").
+		WithNVal(5).
+		WithTimeout(time.Second * 30).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetchAll, err := NewFetchIndexCommandBuilder().Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	del, err := NewDeleteIndexCommandBuilder().WithIndexName("indexName").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decode := func(cmd Command, code byte, msg proto.Message) {
+		data, err := getRiakMessage(cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// NB: skip the length and message code
+		if got, want := data[4], code; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if err = proto.Unmarshal(data[5:], msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	putReq := &rpbRiakYZ.RpbYokozunaIndexPutReq{}
+	decode(store, rpbCode_RpbYokozunaIndexPutReq, putReq)
+	if got, want := string(putReq.GetIndex().GetName()), "indexName"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := string(putReq.GetIndex().GetSchema()), "schemaName"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := putReq.GetIndex().GetNVal(), uint32(5); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := putReq.GetTimeout(), uint32(30000); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	getReq := &rpbRiakYZ.RpbYokozunaIndexGetReq{}
+	decode(fetchAll, rpbCode_RpbYokozunaIndexGetReq, getReq)
+	if getReq.Name != nil {
+		t.Errorf("expected no name when fetching all indexes, got %q", getReq.Name)
+	}
+
+	delReq := &rpbRiakYZ.RpbYokozunaIndexDeleteReq{}
+	decode(del, rpbCode_RpbYokozunaIndexDeleteReq, delReq)
+	if got, want := string(delReq.GetName()), "indexName"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// all indexes are returned when no name is given
+	encoded, err := proto.Marshal(&rpbRiakYZ.RpbYokozunaIndexGetResp{
+		Index: []*rpbRiakYZ.RpbYokozunaIndex{
+			{Name: []byte("index_1"), Schema: []byte("_yz_default"), NVal: proto.Uint32(3)},
+			{Name: []byte("index_2"), Schema: []byte("schema_2"), NVal: proto.Uint32(5)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := decodeRiakMessage(fetchAll, append([]byte{rpbCode_RpbYokozunaIndexGetResp}, encoded...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = fetchAll.onSuccess(msg); err != nil {
+		t.Fatal(err)
+	}
+	var indexes []string
+	for _, idx := range fetchAll.(*FetchIndexCommand).Response {
+		indexes = append(indexes, fmt.Sprintf("%s/%s/%d", idx.Name, idx.Schema, idx.NVal))
+	}
+	if got, want := fmt.Sprint(indexes), "[index_1/_yz_default/3 index_2/schema_2/5]"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
 // StoreSchema
 // RpbYokozunaSchemaPutReq
 
@@ -206,6 +294,76 @@ func TestBuildRpbYokozunaSchemaPutReqCorrectlyViaBuilder(t *testing.T) {
 // RpbYokozunaSchemaGetReq
 // RpbYokozunaSchemaGetResp
 
+func TestValidationOfStoreSchemaViaBuilder(t *testing.T) {
+	if _, err := NewStoreSchemaCommandBuilder().WithSchema("schema_xml").Build(); err != ErrStoreSchemaNameRequired {
+		t.Errorf("got %v, want %v", err, ErrStoreSchemaNameRequired)
+	}
+	if _, err := NewStoreSchemaCommandBuilder().WithSchemaName("schemaName").Build(); err != ErrStoreSchemaContentRequired {
+		t.Errorf("got %v, want %v", err, ErrStoreSchemaContentRequired)
+	}
+}
+
+func TestSchemaContentRoundTrip(t *testing.T) {
+	schemaXML := `<?xml version="1.0" encoding="UTF-8" ?>
+<schema name="small" version="1.5">
+ <fields>
+   <field name="name_s" type="string" indexed="true" stored="true" />
+   <field name="_yz_id" type="_yz_str" indexed="true" stored="true" required="true" />
+ </fields>
+ <uniqueKey>_yz_id</uniqueKey>
+ <types>
+   <fieldType name="string" class="solr.StrField" sortMissingLast="true" />
+   <fieldType name="_yz_str" class="solr.StrField" sortMissingLast="true" />
+ </types>
+</schema>`
+
+	store, err := NewStoreSchemaCommandBuilder().
+		WithSchemaName("small").
+		WithSchema(schemaXML).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := getRiakMessage(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// NB: skip the length and message code
+	if got, want := data[4], rpbCode_RpbYokozunaSchemaPutReq; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	req := &rpbRiakYZ.RpbYokozunaSchemaPutReq{}
+	if err = proto.Unmarshal(data[5:], req); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(req.GetSchema().GetContent()), schemaXML; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	fetch, err := NewFetchSchemaCommandBuilder().WithSchemaName("small").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := proto.Marshal(&rpbRiakYZ.RpbYokozunaSchemaGetResp{Schema: req.GetSchema()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := decodeRiakMessage(fetch, append([]byte{rpbCode_RpbYokozunaSchemaGetResp}, encoded...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = fetch.onSuccess(msg); err != nil {
+		t.Fatal(err)
+	}
+	schema := fetch.(*FetchSchemaCommand).Response
+	if got, want := schema.Name, "small"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := schema.Content, schemaXML; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
 func TestBuildRpbYokozunaSchemaGetReqCorrectlyViaBuilder(t *testing.T) {
 	builder := NewFetchSchemaCommandBuilder().
 		WithSchemaName("schemaName")
@@ -337,6 +495,30 @@ func TestBuildRpbSearchQueryReqCorrectlyViaBuilder(t *testing.T) {
 	}
 }
 
+func TestBuildSearchPageLeavesBuilderUnchanged(t *testing.T) {
+	builder := NewSearchCommandBuilder().
+		WithIndexName("indexName").
+		WithQuery("*:*").
+		WithNumRows(10).
+		WithSortField("sortField")
+	page := builder.buildPage(20, 5)
+	if expected, actual := uint32(20), page.protobuf.GetStart(); expected != actual {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+	if expected, actual := uint32(5), page.protobuf.GetRows(); expected != actual {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+	if expected, actual := "sortField", string(page.protobuf.GetSort()); expected != actual {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+	if builder.protobuf.Start != nil {
+		t.Errorf("expected nil start, got %v", builder.protobuf.GetStart())
+	}
+	if expected, actual := uint32(10), builder.protobuf.GetRows(); expected != actual {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
 func TestParseRpbSearchQueryRespCorrectly(t *testing.T) {
 	resp := &rpbRiakSCH.RpbSearchQueryResp{
 		Docs: make([]*rpbRiakSCH.RpbSearchDoc, 1),